@@ -0,0 +1,143 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/capucinoxx/jdis-games-2024/consts"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+)
+
+// nearestCoin returns the closest coin to the given position, or nil if there is none.
+func nearestCoin(pos *model.Point, state *model.GameState) *model.Scorer {
+	var nearest *model.Scorer
+	best := math.MaxFloat64
+
+	for _, coin := range state.Coins().List() {
+		if !coin.IsAlive() {
+			continue
+		}
+
+		if d := distance(pos, coin.Position); d < best {
+			best = d
+			nearest = coin
+		}
+	}
+	return nearest
+}
+
+// nearestEnemy returns the closest living enemy of the player, or nil if there is none.
+func nearestEnemy(self *model.Player, state *model.GameState) *model.Player {
+	var nearest *model.Player
+	best := math.MaxFloat64
+
+	for _, p := range state.Players() {
+		if p.Nickname == self.Nickname || !p.IsAlive() {
+			continue
+		}
+
+		if d := distance(self.Position, p.Position); d < best {
+			best = d
+			nearest = p
+		}
+	}
+	return nearest
+}
+
+func distance(a, b *model.Point) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
+
+// switchTo returns the controls to equip the given weapon if it is not already equipped.
+func switchTo(self *model.Player, weapon model.PlayerWeapon) *model.PlayerWeapon {
+	if self.CurrentWeapon() == weapon {
+		return nil
+	}
+	return &weapon
+}
+
+// pathFollower follows the paths found by the map toward a destination.
+type pathFollower struct {
+	dest model.Point
+	path []model.Point
+}
+
+// next returns the next waypoint toward dest, finding a new path when the destination
+// changed. It returns dest itself when the map cannot find a path.
+func (f *pathFollower) next(self *model.Player, state *model.GameState, dest model.Point) *model.Point {
+	if dest != f.dest || len(f.path) == 0 {
+		f.dest, f.path = dest, nil
+		if pathfinder, ok := state.Map.(model.Pathfinder); ok {
+			f.path, _ = pathfinder.Path(*self.Position, dest)
+		}
+	}
+
+	for len(f.path) > 1 && distance(self.Position, &f.path[0]) < 0.1 {
+		f.path = f.path[1:]
+	}
+
+	if len(f.path) == 0 {
+		return &model.Point{X: dest.X, Y: dest.Y}
+	}
+	return &model.Point{X: f.path[0].X, Y: f.path[0].Y}
+}
+
+// gunnerBot walks toward the nearest coin along the paths found by the map and shoots
+// at the nearest enemy.
+type gunnerBot struct {
+	ticks int
+	paths pathFollower
+}
+
+func (b *gunnerBot) Controls(self *model.Player, state *model.GameState) model.Controls {
+	b.ticks++
+	controls := model.Controls{SwitchWeapon: switchTo(self, model.PlayerWeaponCanon)}
+
+	if coin := nearestCoin(self.Position, state); coin != nil {
+		controls.Dest = b.paths.next(self, state, *coin.Position)
+	}
+
+	if enemy := nearestEnemy(self, state); enemy != nil && b.ticks%5 == 0 {
+		controls.Shoot = &model.Point{X: enemy.Position.X, Y: enemy.Position.Y}
+	}
+
+	return controls
+}
+
+// bladeBot chases the nearest enemy while spinning its blade.
+type bladeBot struct{}
+
+func (b *bladeBot) Controls(self *model.Player, state *model.GameState) model.Controls {
+	// The blade turns toward its target by the shortest way, aiming a quarter turn ahead
+	// keeps it spinning.
+	rotation := math.Pi / 2
+	if blade, ok := self.Weapon(model.PlayerWeaponBlade).(*model.Blade); ok {
+		rotation += blade.Rotation()
+	}
+
+	controls := model.Controls{
+		SwitchWeapon: switchTo(self, model.PlayerWeaponBlade),
+		RotateBlade:  &rotation,
+	}
+
+	if enemy := nearestEnemy(self, state); enemy != nil {
+		controls.Dest = &model.Point{X: enemy.Position.X, Y: enemy.Position.Y}
+	}
+
+	return controls
+}
+
+// wandererBot walks to random destinations.
+type wandererBot struct {
+	rng  *rand.Rand
+	dest *model.Point
+}
+
+func (b *wandererBot) Controls(self *model.Player, state *model.GameState) model.Controls {
+	if b.dest == nil || distance(self.Position, b.dest) < 1 || b.rng.Intn(100) == 0 {
+		size := float64(state.Map.Size() * consts.CellWidth)
+		b.dest = &model.Point{X: b.rng.Float64() * size, Y: b.rng.Float64() * size}
+	}
+
+	return model.Controls{Dest: b.dest}
+}
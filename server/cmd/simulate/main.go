@@ -0,0 +1,122 @@
+package main
+
+// simulate runs games headlessly with in-process bots, as fast as possible, and prints
+// the final scores. It requires neither the network nor the databases and is meant to
+// tune bot strategies and game rules.
+//
+// Usage:
+//
+//	go run ./cmd/simulate -bots 8 -games 10 -seed 42 -rules rules.json
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	iManager "github.com/capucinoxx/jdis-games-2024/internal/manager"
+	iModel "github.com/capucinoxx/jdis-games-2024/internal/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/manager"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/utils"
+)
+
+// newBot creates the i-th bot of a game, strategies are assigned in turn.
+func newBot(i int, r *rand.Rand) (string, manager.Bot) {
+	switch i % 3 {
+	case 0:
+		return fmt.Sprintf("gunner-%d", i), &gunnerBot{}
+	case 1:
+		return fmt.Sprintf("blade-%d", i), &bladeBot{}
+	default:
+		return fmt.Sprintf("wanderer-%d", i), &wandererBot{rng: rand.New(rand.NewSource(r.Int63()))}
+	}
+}
+
+func main() {
+	bots := flag.Int("bots", 8, "number of bots")
+	games := flag.Int("games", 1, "number of games to simulate")
+	ticks := flag.Int("ticks", 0, "maximum number of ticks per game (default: a full round)")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "seed of the first game, the following games use seed+1, seed+2, ...")
+	rulesFile := flag.String("rules", "", "JSON file holding the game rules (default: standard rules)")
+	verbose := flag.Bool("v", false, "print the game logs")
+	flag.Parse()
+
+	rules := model.DefaultGameRules()
+	if *rulesFile != "" {
+		var err error
+		if rules, err = model.LoadGameRules(*rulesFile); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to load rules %s: %s\n", *rulesFile, err)
+			os.Exit(1)
+		}
+	}
+
+	if *ticks <= 0 {
+		*ticks = rules.TicksPerRound
+	}
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	totals := make(map[string]int)
+	kills := make(map[string]int)
+	start := time.Now()
+	simulated := 0
+
+	for g := 0; g < *games; g++ {
+		gameSeed := *seed + int64(g)
+		r := rand.New(rand.NewSource(gameSeed))
+
+		rm := iManager.NewRoundManager()
+		rm.AddChangeStageHandler(&iManager.DiscoveryStage{})
+		rm.AddChangeStageHandler(&iManager.PointRushStage{})
+
+		sim := manager.NewSimulator(rm, &iModel.Map{}, gameSeed)
+		sim.SetRules(rules)
+		sim.State().Events().Subscribe(func(e model.Event) {
+			if e.Type == model.EventKill {
+				kills[e.Player]++
+			}
+		})
+		for i := 0; i < *bots; i++ {
+			name, bot := newBot(i, r)
+			sim.AddBot(name, int(utils.NameColor(name)), bot)
+		}
+
+		n := sim.Run(*ticks)
+		simulated += n
+
+		fmt.Printf("game %d (seed %d): %d ticks\n", g+1, gameSeed, n)
+		for _, s := range sim.Scores() {
+			totals[s.Name] += s.Score
+		}
+	}
+
+	names := make([]string, 0, len(totals))
+	for name := range totals {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if totals[names[i]] == totals[names[j]] {
+			return names[i] < names[j]
+		}
+		return totals[names[i]] > totals[names[j]]
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nRANK\tBOT\tSCORE\tAVERAGE\tKILLS")
+	for i, name := range names {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%.1f\t%d\n", i+1, name, totals[name], float64(totals[name])/float64(*games), kills[name])
+	}
+	w.Flush()
+
+	elapsed := time.Since(start)
+	fmt.Printf("\n%d ticks simulated in %s (%.0fx real time)\n", simulated, elapsed.Round(time.Millisecond),
+		float64(simulated)/float64(rules.Tickrate)/elapsed.Seconds())
+}
@@ -61,6 +61,20 @@ const (
 	// ProjectileTTL defines the time to live of a projectile (in seconds).
 	ProjectileTTL = 5.0
 
+	// MaxProjectiles defines the number of projectiles, pellets, mines and grenades
+	// included, a player can have in the game at the same time.
+	MaxProjectiles = 10
+
+	// CannonCooldown defines the time (in seconds) between two shots of a cannon.
+	CannonCooldown = 0.3
+
+	// CannonMagazine defines the number of shots of a cannon before it reloads, 0 for
+	// no magazine.
+	CannonMagazine = 0
+
+	// CannonReloadTime defines the time (in seconds) a cannon takes to reload.
+	CannonReloadTime = 2
+
 	// --- BLADE CONSTANTS
 	// ================================
 
@@ -70,9 +84,68 @@ const (
 	// BladeDmg defines the damage suffered by a player when hit by a blade.
 	BladeDmg = 4
 
-	// BladeRotationSpeed defines the speed of rotation of a blade (in degrees).
+	// BladeRotationSpeed defines the speed of rotation of a blade (in degrees per second).
 	BladeRotationSpeed = 230
 
+	// --- SHOTGUN CONSTANTS
+	// ================================
+
+	// ShotgunPellets defines the number of pellets fired by a shotgun.
+	ShotgunPellets = 5
+
+	// ShotgunSpread defines the angle (in degrees) over which the pellets are spread.
+	ShotgunSpread = 30
+
+	// ShotgunRange defines the distance traveled by a pellet.
+	ShotgunRange = 6
+
+	// ShotgunDmg defines the damage suffered by a player when hit by a pellet.
+	ShotgunDmg = 5
+
+	// ShotgunCooldown defines the time (in seconds) between two shots of a shotgun.
+	ShotgunCooldown = 1
+
+	// ShotgunMagazine defines the number of shots of a shotgun before it reloads, 0 for
+	// no magazine.
+	ShotgunMagazine = 0
+
+	// ShotgunReloadTime defines the time (in seconds) a shotgun takes to reload.
+	ShotgunReloadTime = 3
+
+	// --- MINE CONSTANTS
+	// ================================
+
+	// MineRadius defines the distance at which an enemy triggers a mine, and the radius
+	// of its explosion.
+	MineRadius = 1.5
+
+	// MineDmg defines the damage suffered by a player caught in the explosion of a mine.
+	MineDmg = 30
+
+	// MineTTL defines the time to live of a mine (in seconds).
+	MineTTL = 30
+
+	// MaxMines defines the number of mines a player can have at the same time.
+	MaxMines = 3
+
+	// MineCooldown defines the time (in seconds) between two mines dropped.
+	MineCooldown = 1
+
+	// --- GRENADE CONSTANTS
+	// ================================
+
+	// GrenadeRange defines the farthest a grenade can be thrown.
+	GrenadeRange = 10
+
+	// GrenadeRadius defines the radius of the explosion of a grenade.
+	GrenadeRadius = 2.5
+
+	// GrenadeDmg defines the damage suffered by a player caught in the explosion of a grenade.
+	GrenadeDmg = 25
+
+	// GrenadeCooldown defines the time (in seconds) between two grenades thrown.
+	GrenadeCooldown = 1.5
+
 	// --- SCORER CONSTANTS
 	// ================================
 
@@ -91,6 +164,33 @@ const (
 	// BigCoinValue defines the value when a player collects a big coin.
 	BigCoinValue int32 = NumCoins * CoinValue
 
+	// PowerUpChance defines the probability that a collected coin is replaced by a
+	// power-up rather than another coin. The power-ups are disabled by default: they
+	// change the coins drawn for a seed and clients older than ProtocolVersion2 cannot
+	// see them.
+	PowerUpChance = 0
+
+	// PowerUpDuration defines the time (in seconds) the effect of a power-up lasts.
+	PowerUpDuration = 5
+
+	// SpeedBoost defines the factor applied to the speed of a player under a speed boost.
+	SpeedBoost = 1.5
+
+	// HealthPack defines the health restored by a health pack.
+	HealthPack = 30
+
+	// RapidFire defines the factor applied to the rate of fire of a player under rapid fire.
+	RapidFire = 2
+
+	// --- ZONE CONSTANTS
+	// ================================
+
+	// ZoneEndRadius defines the radius of the safe zone at the end of the point rush stage.
+	ZoneEndRadius = 5
+
+	// ZoneDmg defines the damage suffered every tick by a player outside the safe zone.
+	ZoneDmg = 1
+
 	// --- SCORE CONSTANTS
 	// ================================
 
@@ -99,4 +199,14 @@ const (
 
 	// ScoreOnHitWithBlade defines the score awarded when hitting an opponent with a blade.
 	ScoreOnHitWithBlade = 4
+
+	// ScoreOnHitWithShotgun defines the score awarded when hitting an opponent with a pellet.
+	ScoreOnHitWithShotgun = 5
+
+	// ScoreOnHitWithExplosion defines the score awarded when an opponent is caught in the
+	// explosion of a mine or a grenade.
+	ScoreOnHitWithExplosion = 15
+
+	// ScoreOnKill defines the score awarded when eliminating an opponent.
+	ScoreOnKill = 50
 )
@@ -70,6 +70,7 @@ class GameManager {
       if (health > 0)
         payload_players.push({ name, pos, color, dest, blade, current_weapon });
       payload_bullets.push(...data.projectiles);
+      data.weapons?.forEach((weapon) => payload_bullets.push(...weapon.projectiles));
     });
 
     this.progress.current_value = payload.tick;
@@ -38,6 +38,36 @@ type PlayerData = {
   current_weapon: number;
   blade: BladeObject;
   projectiles: Array<Projectile>
+  weapons?: Array<WeaponData>
+  triggers?: Array<TriggerData>
+  effects?: Array<EffectData>
+};
+
+type WeaponData = {
+  type: number;
+  projectiles: Array<Projectile>
+};
+
+type TriggerData = {
+  weapon: number;
+  cooldown: number;
+  ammo: number;
+};
+
+type EffectData = {
+  effect: number;
+  remaining: number;
+};
+
+type PowerUpData = {
+  id: string;
+  pos: Position;
+  effect: number;
+};
+
+type ZoneData = {
+  center: Position;
+  radius: number;
 };
 
 type ServerMapState = {
@@ -56,6 +86,8 @@ type ServerGameState = {
   round: number;
   players: Array<PlayerData>;
   coins: Array<ScorerObject>;
+  power_ups?: Array<PowerUpData>;
+  zone?: ZoneData;
 };
 
 type Empty = Record<string, never>;
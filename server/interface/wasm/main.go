@@ -78,6 +78,7 @@ func getInformations(this js.Value, args []js.Value) interface{} {
 			player := js.Global().Get("Object").New()
 			player.Set("name", data.Nickname)
 			player.Set("color", int(data.Color))
+			player.Set("team", data.Team)
 			player.Set("health", int(data.Health))
 			player.Set("score", int(data.Score))
 			player.Set("pos", position(data.Pos))
@@ -89,16 +90,35 @@ func getInformations(this js.Value, args []js.Value) interface{} {
 			}
 			player.Set("current_weapon", int(data.CurrentWeapon))
 
-			projectiles := js.Global().Get("Array").New()
-			for _, projectile := range data.Projectiles {
-				p := js.Global().Get("Object").New()
-				p.Set("id", format_id(projectile.Uuid))
-				p.Set("pos", position(projectile.Pos))
-				p.Set("dest", position(projectile.Dest))
+			player.Set("projectiles", projectiles(data.Projectiles))
 
-				projectiles.Call("push", p)
+			weapons := js.Global().Get("Array").New()
+			for _, weapon := range data.Weapons {
+				w := js.Global().Get("Object").New()
+				w.Set("type", int(weapon.Type))
+				w.Set("projectiles", projectiles(weapon.Projectiles))
+				weapons.Call("push", w)
 			}
-			player.Set("projectiles", projectiles)
+			player.Set("weapons", weapons)
+
+			triggers := js.Global().Get("Array").New()
+			for _, trigger := range data.Triggers {
+				t := js.Global().Get("Object").New()
+				t.Set("weapon", int(trigger.Weapon))
+				t.Set("cooldown", int(trigger.Cooldown))
+				t.Set("ammo", int(trigger.Ammo))
+				triggers.Call("push", t)
+			}
+			player.Set("triggers", triggers)
+
+			effects := js.Global().Get("Array").New()
+			for _, effect := range data.Effects {
+				e := js.Global().Get("Object").New()
+				e.Set("effect", int(effect.Effect))
+				e.Set("remaining", int(effect.Remaining))
+				effects.Call("push", e)
+			}
+			player.Set("effects", effects)
 			players.Call("push", player)
 
 			blade := js.Global().Get("Object").New()
@@ -120,6 +140,43 @@ func getInformations(this js.Value, args []js.Value) interface{} {
 		}
 
 		obj.Set("coins", coins)
+
+		powerUps := js.Global().Get("Array").New()
+		for _, powerUp := range body.PowerUps {
+			p := js.Global().Get("Object").New()
+			p.Set("id", format_id(powerUp.Uuid))
+			p.Set("pos", position(powerUp.Pos))
+			p.Set("effect", int(powerUp.Effect))
+			powerUps.Call("push", p)
+		}
+
+		obj.Set("power_ups", powerUps)
+
+		if body.Zone != nil {
+			zone := js.Global().Get("Object").New()
+			zone.Set("center", position(body.Zone.Center))
+			zone.Set("radius", body.Zone.Radius)
+			obj.Set("zone", zone)
+		}
+	}
+
+	if msg.MessageType == model.MessageGameEvents {
+		body := msg.Body.(model.MessageGameEventsToDecode)
+
+		events := js.Global().Get("Array").New()
+		for _, e := range body.Events {
+			event := js.Global().Get("Object").New()
+			event.Set("type", int(e.Type))
+			event.Set("tick", e.Tick)
+			event.Set("player", e.Player)
+			event.Set("target", e.Target)
+			event.Set("weapon", int(e.Weapon))
+			event.Set("value", e.Value)
+			event.Set("pos", position(e.Pos))
+			events.Call("push", event)
+		}
+
+		obj.Set("events", events)
 	}
 
 	return obj
@@ -132,6 +189,19 @@ func position(pos model.Point) interface{} {
 	return obj
 }
 
+func projectiles(infos []model.ProjectileInfo) js.Value {
+	projectiles := js.Global().Get("Array").New()
+	for _, projectile := range infos {
+		p := js.Global().Get("Object").New()
+		p.Set("id", format_id(projectile.Uuid))
+		p.Set("pos", position(projectile.Pos))
+		p.Set("dest", position(projectile.Dest))
+
+		projectiles.Call("push", p)
+	}
+	return projectiles
+}
+
 func format_id(uuid [16]byte) string {
 	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x",
 		uuid[0:4],
@@ -7,18 +7,22 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/capucinoxx/jdis-games-2024/pkg/manager"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
 	"github.com/capucinoxx/jdis-games-2024/pkg/network"
 )
 
-// HttpHandler is a structure that holds references to the game manager,
-// authentication manager, and score manager.
+// HttpHandler is a structure that holds references to the room manager and the
+// authentication manager. The requests about a game apply to the room given by the
+// room query parameter, or to the default room.
 type HttpHandler struct {
-	gm *manager.GameManager
-	am *manager.AuthManager
-	sm *manager.ScoreManager
+	rooms *manager.RoomManager
+	am    *manager.AuthManager
 }
 
 // HttpResponse is a structure used for formatting JSON responses.
@@ -29,14 +33,23 @@ type HttpResponse struct {
 }
 
 // NewHttpHandler creates a new instance of HttpHandler.
-func NewHttpHandler(gm *manager.GameManager, am *manager.AuthManager, sm *manager.ScoreManager) *HttpHandler {
+func NewHttpHandler(rooms *manager.RoomManager, am *manager.AuthManager) *HttpHandler {
 	return &HttpHandler{
-		gm: gm,
-		am: am,
-		sm: sm,
+		rooms: rooms,
+		am:    am,
 	}
 }
 
+// room returns the room of the request (?room=...), or writes an error when it does
+// not exist.
+func (h *HttpHandler) room(w http.ResponseWriter, r *http.Request) (*manager.Room, bool) {
+	room, ok := h.rooms.Room(r.URL.Query().Get("room"))
+	if !ok {
+		http.Error(w, manager.ErrUnknownRoom.Error(), http.StatusNotFound)
+	}
+	return room, ok
+}
+
 // Handle sets up the HTTP routes and handlers for the server.
 func (h *HttpHandler) Handle() {
 	fs := http.FileServer(http.Dir("./dist"))
@@ -51,12 +64,22 @@ func (h *HttpHandler) Handle() {
 
 	network.HandleFunc("/freeze", h.freeze, h.adminOnly)
 	network.HandleFunc("/unfreeze", h.unfreeze, h.adminOnly)
+
+	network.HandleFunc("/replays", h.replays, h.adminOnly)
+	network.HandleFunc("/replay", h.replay, h.adminOnly)
+
+	network.HandleFunc("/rules", h.rules, h.adminOnly)
+	network.HandleFunc("/map", h.mapLayout, h.adminOnly)
+	network.HandleFunc("/path", h.path, h.adminOnly)
+
+	network.HandleFunc("/rooms", h.roomsHandler, h.adminOnly)
 }
 
 // register handles user registration requests.
 func (h *HttpHandler) register(w http.ResponseWriter, r *http.Request) {
 	payload := struct {
 		Username string `json:"username"`
+		Team     string `json:"team"`
 	}{}
 
 	if r.Method != http.MethodPost {
@@ -69,7 +92,7 @@ func (h *HttpHandler) register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := h.am.Register(payload.Username, false)
+	token, err := h.am.Register(payload.Username, payload.Team, false)
 	var resp HttpResponse
 	resp.Subject = "Token generation"
 	w.Header().Set("Content-Type", "application/json")
@@ -83,10 +106,26 @@ func (h *HttpHandler) register(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-// startGame handles requests to start the game.
+// startGame handles requests to start the game. An optional seed can be given
+// to reproduce a previous match (?seed=...).
 // restrictions: admins only.
 func (h *HttpHandler) startGame(w http.ResponseWriter, r *http.Request) {
-	h.gm.Start()
+	room, ok := h.room(w, r)
+	if !ok {
+		return
+	}
+	gm := room.GameManager()
+
+	if v := r.URL.Query().Get("seed"); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid seed", http.StatusBadRequest)
+			return
+		}
+		gm.SetSeed(seed)
+	}
+
+	gm.Start()
 }
 
 // users handles requests to list all users.
@@ -107,7 +146,18 @@ func (h *HttpHandler) users(w http.ResponseWriter, r *http.Request) {
 // leaderboard handles requests to retrieve the leaderboard.
 // restrictions: only if leaderboard isn't freeze.
 func (h *HttpHandler) leaderboard(w http.ResponseWriter, r *http.Request) {
-	leaderboard, histories, err := h.sm.Rank()
+	room, ok := h.room(w, r)
+	if !ok {
+		return
+	}
+
+	leaderboard, histories, err := room.ScoreManager().Rank()
+	if err != nil {
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+
+	teams, err := room.ScoreManager().RankTeams()
 	if err != nil {
 		http.Error(w, "error", http.StatusInternalServerError)
 		return
@@ -115,9 +165,11 @@ func (h *HttpHandler) leaderboard(w http.ResponseWriter, r *http.Request) {
 
 	data := struct {
 		Leaderboard []manager.PlayerScore            `json:"leaderboard"`
+		Teams       []manager.TeamScore              `json:"teams"`
 		Histories   map[string][]manager.PlayerEntry `json:"histories"`
 	}{
 		Leaderboard: leaderboard,
+		Teams:       teams,
 		Histories:   histories,
 	}
 
@@ -128,7 +180,12 @@ func (h *HttpHandler) leaderboard(w http.ResponseWriter, r *http.Request) {
 // toggleLeaderboard handles requests to toggle the visibility of the leaderboard.
 // restrictions: admins only.
 func (h *HttpHandler) toggleLeaderboard(w http.ResponseWriter, r *http.Request) {
-	visible := h.sm.ToggleVisibility()
+	room, ok := h.room(w, r)
+	if !ok {
+		return
+	}
+
+	visible := room.ScoreManager().ToggleVisibility()
 
 	status := "disabled"
 	if visible {
@@ -142,21 +199,216 @@ func (h *HttpHandler) toggleLeaderboard(w http.ResponseWriter, r *http.Request)
 // kill handles requests to kill a player.
 // restrictions: admins only.
 func (h *HttpHandler) kill(w http.ResponseWriter, r *http.Request) {
+	room, ok := h.room(w, r)
+	if !ok {
+		return
+	}
+
 	name := r.URL.Query().Get("name")
 	if name != "" {
-		h.gm.Kill(name)
+		room.GameManager().Kill(name)
 	}
 }
 
 // freeze handles requests to freeze the game.
 // restrictions: admins only.
 func (h *HttpHandler) freeze(w http.ResponseWriter, r *http.Request) {
-	h.gm.Freeze(true)
+	if room, ok := h.room(w, r); ok {
+		room.GameManager().Freeze(true)
+	}
 }
 
 // // unfreeze handles requests to unfreeze and restart the game.
 // restrictions: admins only.
 func (h *HttpHandler) unfreeze(w http.ResponseWriter, r *http.Request) {
-	h.gm.Freeze(false)
-	h.gm.Start()
+	if room, ok := h.room(w, r); ok {
+		room.GameManager().Freeze(false)
+		room.GameManager().Start()
+	}
+}
+
+// replays handles requests to list the recorded games.
+// restrictions: admins only.
+func (h *HttpHandler) replays(w http.ResponseWriter, r *http.Request) {
+	room, ok := h.room(w, r)
+	if !ok {
+		return
+	}
+
+	replays, err := room.ReplayManager().List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"replays": replays})
+}
+
+// replay handles requests to play back a recorded game to spectators (?name=...).
+// The live game must be over and every player disconnected, otherwise the players would
+// receive the replay in place of their game.
+// restrictions: admins only.
+func (h *HttpHandler) replay(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "missing replay name", http.StatusBadRequest)
+		return
+	}
+
+	room, ok := h.room(w, r)
+	if !ok {
+		return
+	}
+
+	if room.GameManager().InProgress() {
+		http.Error(w, "a game is in progress", http.StatusConflict)
+		return
+	}
+
+	if room.ConnectedPlayers() > 0 {
+		http.Error(w, "players are connected", http.StatusConflict)
+		return
+	}
+
+	if err := room.ReplayManager().Play(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// rules handles requests to read (GET) or change (POST) the rules of the next game.
+// A POST body is a JSON object holding only the rules to change, the others keep
+// their current value.
+// restrictions: admins only.
+func (h *HttpHandler) rules(w http.ResponseWriter, r *http.Request) {
+	room, ok := h.room(w, r)
+	if !ok {
+		return
+	}
+	gm := room.GameManager()
+
+	if r.Method == http.MethodPost {
+		current := gm.Rules()
+		rules, err := model.DecodeGameRules(r.Body, &current)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		gm.SetRules(rules)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(gm.Rules())
+}
+
+// mapLayout handles requests to read (GET), upload (POST) or remove (DELETE) the
+// hand-authored map of the next game. A POST body is a JSON map layout, which replaces
+// the generated map until it is removed.
+// restrictions: admins only.
+func (h *HttpHandler) mapLayout(w http.ResponseWriter, r *http.Request) {
+	room, ok := h.room(w, r)
+	if !ok {
+		return
+	}
+	gm := room.GameManager()
+	rules := gm.Rules()
+
+	switch r.Method {
+	case http.MethodPost:
+		layout, err := model.DecodeMapLayout(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rules.MapLayout = layout
+		rules.MapWidth = layout.Size
+		gm.SetRules(&rules)
+
+	case http.MethodDelete:
+		rules.MapLayout = nil
+		gm.SetRules(&rules)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules.MapLayout)
+}
+
+// path handles requests to find a path between two positions of the map of the current
+// game (?from=x,y&to=x,y). The response holds the waypoints to go through.
+// restrictions: admins only.
+func (h *HttpHandler) path(w http.ResponseWriter, r *http.Request) {
+	room, ok := h.room(w, r)
+	if !ok {
+		return
+	}
+
+	from, err := parsePoint(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	to, err := parsePoint(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	waypoints, ok := room.GameManager().Path(from, to)
+	if !ok {
+		http.Error(w, "no path found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"path": waypoints})
+}
+
+// parsePoint parses a position written as x,y.
+func parsePoint(s string) (model.Point, error) {
+	x, y, ok := strings.Cut(s, ",")
+	if !ok {
+		return model.Point{}, fmt.Errorf("%q is not a position written as x,y", s)
+	}
+
+	px, err := strconv.ParseFloat(x, 64)
+	if err != nil {
+		return model.Point{}, err
+	}
+
+	py, err := strconv.ParseFloat(y, 64)
+	if err != nil {
+		return model.Point{}, err
+	}
+	return model.Point{X: px, Y: py}, nil
+}
+
+// roomsHandler handles requests to list (GET), create (POST) or destroy (DELETE) rooms.
+// A POST body is a JSON object with the name of the room and whether it is ranked, a
+// DELETE names the room to destroy (?name=...).
+// restrictions: admins only.
+func (h *HttpHandler) roomsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var config manager.RoomConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if _, err := h.rooms.Create(config); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+	case http.MethodDelete:
+		if err := h.rooms.Destroy(r.URL.Query().Get("name")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"rooms": h.rooms.List()})
 }
@@ -12,7 +12,8 @@ import "net/http"
 // status. Otherwise, it passes the request to the next handler.
 func (h *HttpHandler) checkLeaderboardAccess(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/leaderboard" && !h.sm.IsVisible() {
+		room, ok := h.rooms.Room(r.URL.Query().Get("room"))
+		if r.URL.Path == "/leaderboard" && ok && !room.ScoreManager().IsVisible() {
 			http.Error(w, "Leaderboard access is disabled.", http.StatusForbidden)
 			return
 		}
@@ -41,7 +42,7 @@ func (h *HttpHandler) adminOnly(next http.Handler) http.Handler {
 			return
 		}
 
-		_, _, isAdmin, _ := h.am.Authenticate(tkn)
+		_, _, _, isAdmin, _ := h.am.Authenticate(tkn)
 		if !isAdmin {
 			http.Error(w, "admin restricted", http.StatusForbidden)
 			return
@@ -15,6 +15,8 @@ type Stage uint8
 
 // StageHandler is an interface for handling changes in game stages.
 type StageHandler interface {
+	// StartTick returns the tick at which the stage starts under the given rules.
+	StartTick(rules *model.GameRules) int
 	ChangeStage(state *model.GameState)
 }
 
@@ -22,6 +24,7 @@ type StageHandler interface {
 type RoundManager struct {
 	ticks    int
 	state    *model.GameState
+	stages   []StageHandler
 	handlers map[int]StageHandler
 }
 
@@ -30,6 +33,7 @@ func NewRoundManager() *RoundManager {
 	return &RoundManager{
 		ticks:    0,
 		state:    nil,
+		stages:   []StageHandler{},
 		handlers: make(map[int]StageHandler),
 	}
 }
@@ -38,10 +42,16 @@ func (r *RoundManager) SetState(state *model.GameState) {
 	r.state = state
 }
 
-// Restart resets the ticks and triggers the stage handler for the initial tick.
+// Restart resets the ticks and triggers the stage handler for the initial tick. The
+// stages are scheduled from the rules of the game being started.
 func (r *RoundManager) Restart() {
 	r.ticks = 0
 
+	clear(r.handlers)
+	for _, stage := range r.stages {
+		r.handlers[stage.StartTick(r.state.Rules())] = stage
+	}
+
 	if handler, ok := r.handlers[r.ticks]; ok {
 		handler.ChangeStage(r.state)
 	}
@@ -62,41 +72,57 @@ func (r *RoundManager) CurrentTick() int {
 
 // CurrentRound returns the current round based on the tick count.
 func (r *RoundManager) CurrentRound() int8 {
-	if r.ticks < consts.TicksPointRushStage {
+	if r.ticks < r.state.Rules().TicksPointRushStage {
 		return 0
 	}
 	return 1
 }
 
-func (r *RoundManager) AddChangeStageHandler(tick int, cb StageHandler) {
-	r.handlers[tick] = cb
+func (r *RoundManager) AddChangeStageHandler(cb StageHandler) {
+	r.stages = append(r.stages, cb)
 }
 
 func (r *RoundManager) HasEnded() bool {
-	return r.ticks == consts.TicksPerRound
+	return r.ticks == r.state.Rules().TicksPerRound
 }
 
 // DiscoveryStage represents the discovery stage of the game. (first stage)
 type DiscoveryStage struct{}
 
+func (s DiscoveryStage) StartTick(rules *model.GameRules) int {
+	return 0
+}
+
 func (s DiscoveryStage) ChangeStage(state *model.GameState) {
 	spawns := state.Map.Spawns(0)
 	state.SetSpawns(spawns)
 
-	coins := make([]*model.Scorer, 0, consts.NumCoins)
-	for i := 0; i < consts.NumCoins; i++ {
-		coins = append(coins, model.NewCoin())
+	rules := state.Rules()
+	coins := make([]*model.Scorer, 0, rules.NumCoins)
+	for i := 0; i < rules.NumCoins; i++ {
+		coins = append(coins, model.NewRandomCoin(state.Rand(), rules))
 	}
 	state.Reset(coins)
+	state.Events().Emit(model.Event{Type: model.EventStageChange, Value: 0})
 }
 
-// // PointRushStage represents the point rush stage of the game.
+// PointRushStage represents the point rush stage of the game. When the rules enable the
+// closing zone, a safe zone closes on the big coin until the end of the round.
 type PointRushStage struct{}
 
+func (s PointRushStage) StartTick(rules *model.GameRules) int {
+	return rules.TicksPointRushStage
+}
+
 func (s PointRushStage) ChangeStage(state *model.GameState) {
 	state.SetSpawns(state.Map.Spawns(1))
 
 	centroid := state.Map.Centroid()
-	coins := []*model.Scorer{model.NewBigCoin(&centroid)}
+	coins := []*model.Scorer{model.NewBigCoin(&centroid, state.Rules())}
 	state.Reset(coins)
+
+	if rules := state.Rules(); rules.ClosingZone {
+		state.SetZone(model.NewClosingZone(centroid, float64(state.Map.Size()*consts.CellWidth), rules))
+	}
+	state.Events().Emit(model.Event{Type: model.EventStageChange, Value: 1})
 }
@@ -0,0 +1,211 @@
+package model
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/utils"
+)
+
+// DefaultGenerator is the name of the generator used when the rules do not name one.
+const DefaultGenerator = "prim"
+
+// generator carves the passages of a map whose cells are all closed. Every cell must be
+// reachable from start once the passages are carved.
+type generator interface {
+	generate(r *rand.Rand, m *Map, start point)
+}
+
+// generators holds the map generators by name.
+var generators = map[string]generator{
+	"prim":        primGenerator{},
+	"backtracker": backtrackerGenerator{},
+	"kruskal":     kruskalGenerator{},
+	"braid":       braidGenerator{},
+	"arena":       arenaGenerator{},
+}
+
+func init() {
+	for name := range generators {
+		model.RegisterMapGenerator(name)
+	}
+}
+
+// Generators returns the names of the available map generators.
+func Generators() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// primGenerator generates a maze with the randomized Prim's algorithm: passages are
+// carved from a random wall of the maze built so far, which gives many short dead ends.
+type primGenerator struct{}
+
+func (primGenerator) generate(r *rand.Rand, m *Map, start point) {
+	m.primGenerateMaze(r, start)
+}
+
+// backtrackerGenerator generates a maze with a randomized depth-first search, which
+// gives long winding corridors with few dead ends.
+type backtrackerGenerator struct{}
+
+func (backtrackerGenerator) generate(r *rand.Rand, m *Map, start point) {
+	visited := map[point]bool{start: true}
+	stack := []point{start}
+
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+
+		options := []int{}
+		for i, dir := range directions {
+			next := point{current.x + dir.x, current.y + dir.y}
+			if m.inside(next) && !visited[next] {
+				options = append(options, i)
+			}
+		}
+
+		if len(options) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		direction := options[r.Intn(len(options))]
+		next := point{current.x + directions[direction].x, current.y + directions[direction].y}
+		m.removeWall(current, next, direction)
+		visited[next] = true
+		stack = append(stack, next)
+	}
+}
+
+// kruskalGenerator generates a maze with the randomized Kruskal's algorithm: walls are
+// removed in a random order when they separate two cells not yet connected, which gives
+// a uniform texture of short corridors.
+type kruskalGenerator struct{}
+
+func (kruskalGenerator) generate(r *rand.Rand, m *Map, start point) {
+	type edge struct {
+		from      point
+		direction int
+	}
+
+	// Only the south (1) and east (2) walls, so that each wall is considered once.
+	edges := []edge{}
+	for x := 0; x < m.size; x++ {
+		for y := 0; y < m.size; y++ {
+			if x+1 < m.size {
+				edges = append(edges, edge{point{x, y}, 1})
+			}
+			if y+1 < m.size {
+				edges = append(edges, edge{point{x, y}, 2})
+			}
+		}
+	}
+	utils.Shuffle(r, edges)
+
+	parents := make([]int, m.size*m.size)
+	for i := range parents {
+		parents[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+
+	for _, e := range edges {
+		to := point{e.from.x + directions[e.direction].x, e.from.y + directions[e.direction].y}
+		a, b := find(e.from.x*m.size+e.from.y), find(to.x*m.size+to.y)
+		if a != b {
+			m.removeWall(e.from, to, e.direction)
+			parents[a] = b
+		}
+	}
+}
+
+// braidGenerator generates a maze with the recursive backtracker, then removes its dead
+// ends by opening one of their walls, which creates loops.
+type braidGenerator struct{}
+
+func (braidGenerator) generate(r *rand.Rand, m *Map, start point) {
+	backtrackerGenerator{}.generate(r, m, start)
+
+	for x := 0; x < m.size; x++ {
+		for y := 0; y < m.size; y++ {
+			current := point{x, y}
+			if m.wallCount(current) != 3 {
+				continue
+			}
+
+			// Joining two dead ends removes both of them at once.
+			options, deadEnds := []int{}, []int{}
+			for i, dir := range directions {
+				next := point{x + dir.x, y + dir.y}
+				if !m.inside(next) || !m.grid[x][y].isWall(i) {
+					continue
+				}
+
+				options = append(options, i)
+				if m.wallCount(next) == 3 {
+					deadEnds = append(deadEnds, i)
+				}
+			}
+
+			if len(deadEnds) > 0 {
+				options = deadEnds
+			}
+
+			direction := options[r.Intn(len(options))]
+			m.removeWall(current, point{x + directions[direction].x, y + directions[direction].y}, direction)
+		}
+	}
+}
+
+// arenaGenerator generates an open arena surrounded by walls, with cross-shaped pillars
+// scattered on the corners between the cells. Pillars never enclose a cell.
+type arenaGenerator struct{}
+
+func (arenaGenerator) generate(r *rand.Rand, m *Map, start point) {
+	for x := 0; x < m.size; x++ {
+		for y := 0; y < m.size; y++ {
+			if x+1 < m.size {
+				m.removeWall(point{x, y}, point{x + 1, y}, 1)
+			}
+			if y+1 < m.size {
+				m.removeWall(point{x, y}, point{x, y + 1}, 2)
+			}
+		}
+	}
+
+	// A pillar is placed on the corner shared by (x, y), (x+1, y), (x, y+1) and (x+1, y+1).
+	for x := 1; x+2 < m.size; x += 2 {
+		for y := 1; y+2 < m.size; y += 2 {
+			if r.Intn(2) == 0 {
+				continue
+			}
+
+			m.grid[x][y].s, m.grid[x+1][y].n = true, true
+			m.grid[x][y+1].s, m.grid[x+1][y+1].n = true, true
+			m.grid[x][y].e, m.grid[x][y+1].w = true, true
+			m.grid[x+1][y].e, m.grid[x+1][y+1].w = true, true
+		}
+	}
+}
+
+// inside returns true if the cell is in the map.
+func (m *Map) inside(p point) bool {
+	return p.x >= 0 && p.x < m.size && p.y >= 0 && p.y < m.size
+}
+
+// wallCount returns the number of walls of a cell.
+func (m *Map) wallCount(p point) int {
+	return utils.ToInt(m.grid[p.x][p.y].n) + utils.ToInt(m.grid[p.x][p.y].s) +
+		utils.ToInt(m.grid[p.x][p.y].e) + utils.ToInt(m.grid[p.x][p.y].w)
+}
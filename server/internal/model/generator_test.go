@@ -0,0 +1,47 @@
+package model
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+)
+
+func TestMapGenerators(t *testing.T) {
+	for _, name := range Generators() {
+		t.Run(name, func(t *testing.T) {
+			rules := model.DefaultGameRules()
+			rules.MapGenerator = name
+			if err := rules.Validate(); err != nil {
+				t.Fatalf("Validate() error %v", err)
+			}
+
+			m := &Map{}
+			m.Setup(rand.New(rand.NewSource(42)), rules)
+
+			if size := (rules.MapWidth + 1) / 2; len(m.DiscreteMap()) != size {
+				t.Errorf("Expected a discrete map of %d rows, got %d", size, len(m.DiscreteMap()))
+			}
+
+			if len(m.Colliders()) == 0 || len(m.Spawns(0)) == 0 || len(m.Spawns(1)) == 0 {
+				t.Errorf("Expected walls and spawns, got %d walls, %d and %d spawns",
+					len(m.Colliders()), len(m.Spawns(0)), len(m.Spawns(1)))
+			}
+
+			for i, row := range m.dijkstra(point{0, 0}, m.grid) {
+				for j, dist := range row {
+					if dist == math.MaxInt32 {
+						t.Errorf("Cell (%d, %d) cannot be reached", i, j)
+					}
+				}
+			}
+		})
+	}
+
+	rules := model.DefaultGameRules()
+	rules.MapGenerator = "unknown"
+	if err := rules.Validate(); err == nil {
+		t.Errorf("An unknown map generator should be rejected")
+	}
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"math/rand"
+
+	"github.com/capucinoxx/jdis-games-2024/consts"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/utils"
+)
+
+// load builds the map described by a hand-authored layout. The layout must be valid.
+func (m *Map) load(r *rand.Rand, layout *model.MapLayout) {
+	m.size = layout.Size
+	m.grid = make([][]cell, m.size)
+	for i := range m.grid {
+		m.grid[i] = make([]cell, m.size)
+		for j := range m.grid[i] {
+			m.grid[i][j] = cell{n: i == 0, s: i == m.size-1, e: j == m.size-1, w: j == 0}
+		}
+	}
+
+	for _, wall := range layout.Walls {
+		for _, unit := range wall.Units() {
+			x, y := int(unit.From.X), int(unit.From.Y)
+
+			if unit.From.Y == unit.To.Y {
+				if y > 0 && x < m.size {
+					m.grid[y-1][x].s = true
+				}
+				if y < m.size && x < m.size {
+					m.grid[y][x].n = true
+				}
+				continue
+			}
+
+			if x > 0 && y < m.size {
+				m.grid[y][x-1].e = true
+			}
+			if x < m.size && y < m.size {
+				m.grid[y][x].w = true
+			}
+		}
+	}
+
+	m.generateColliders()
+	m.countWallsInSubsquares(2)
+	m.subgrid = m.subdivise(consts.NumSubsquare)
+	m.start = layout.Coin
+
+	for phase, spawns := range layout.Spawns {
+		m.spawns[phase] = make([]*model.Point, 0, len(spawns))
+		for _, spawn := range spawns {
+			spawn := spawn
+			m.spawns[phase] = append(m.spawns[phase], &spawn)
+		}
+		utils.Shuffle(r, m.spawns[phase])
+	}
+}
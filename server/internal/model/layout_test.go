@@ -0,0 +1,65 @@
+package model
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+)
+
+func TestMapLayout(t *testing.T) {
+	rules := model.DefaultGameRules()
+	rules.MapWidth = 2
+	rules.MapLayout = &model.MapLayout{
+		Size:   2,
+		Walls:  []model.WallSegment{{From: model.Point{X: 0, Y: 1}, To: model.Point{X: 1, Y: 1}}},
+		Spawns: [2][]model.Point{{{X: 5, Y: 15}, {X: 15, Y: 15}}, {{X: 15, Y: 5}}},
+		Coin:   model.Point{X: 5, Y: 5},
+	}
+	if err := rules.Validate(); err != nil {
+		t.Fatalf("Validate() error %v", err)
+	}
+
+	m := &Map{}
+	m.Setup(rand.New(rand.NewSource(42)), rules)
+
+	if m.Size() != 2 || m.Centroid() != rules.MapLayout.Coin {
+		t.Errorf("Expected a map of size 2 with the coin at %v, got size %d and %v", rules.MapLayout.Coin, m.Size(), m.Centroid())
+	}
+
+	// The border is 8 walls long, each inner wall being shared by two cells.
+	if len(m.Colliders()) != 8+2 {
+		t.Errorf("Expected 10 walls, got %d", len(m.Colliders()))
+	}
+
+	if len(m.Spawns(0)) != 2 || len(m.Spawns(1)) != 1 {
+		t.Errorf("Expected 2 and 1 spawns, got %d and %d", len(m.Spawns(0)), len(m.Spawns(1)))
+	}
+
+	rules.MapWidth = 3
+	if err := rules.Validate(); err == nil {
+		t.Errorf("A layout of a different size than map_width should be rejected")
+	}
+}
+
+func TestLoadWalls(t *testing.T) {
+	m := &Map{}
+	m.load(rand.New(rand.NewSource(42)), &model.MapLayout{
+		Size:  2,
+		Walls: []model.WallSegment{{From: model.Point{X: 1, Y: 0}, To: model.Point{X: 1, Y: 1}}},
+	})
+
+	// The wall closes the east side of the top left cell and the west side of the top
+	// right one, the other cells only being closed by the border.
+	expected := [][]cell{
+		{{n: true, e: true, w: true}, {n: true, e: true, w: true}},
+		{{s: true, w: true}, {s: true, e: true}},
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if m.grid[i][j] != expected[i][j] {
+				t.Errorf("Cell (%d, %d) = %+v, want %+v", i, j, m.grid[i][j], expected[i][j])
+			}
+		}
+	}
+}
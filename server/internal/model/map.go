@@ -4,7 +4,6 @@ import (
 	"container/heap"
 	"math"
 	"math/rand"
-	"time"
 
 	"github.com/capucinoxx/jdis-games-2024/consts"
 	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
@@ -50,13 +49,16 @@ type Map struct {
 
 	spawns [2][]*model.Point
 	walls  []*model.Collider
+
+	// subgrid is the grid of subsquares in which the paths are searched.
+	subgrid [][]cell
 }
 
 func (m *Map) Centroid() model.Point {
 	return m.start
 }
 
-func (m *Map) primGenerateMaze(start point) {
+func (m *Map) primGenerateMaze(r *rand.Rand, start point) {
 	walls := [][5]int{}
 	visited := make(map[point]bool)
 	visited[start] = true
@@ -67,12 +69,12 @@ func (m *Map) primGenerateMaze(start point) {
 	}
 
 	for len(walls) > 0 {
-		idx := rand.Intn(len(walls))
+		idx := r.Intn(len(walls))
 		wall := walls[idx]
 		walls = append(walls[:idx], walls[idx+1:]...)
 		nx, ny, direction, px, py := wall[0], wall[1], wall[2], wall[3], wall[4]
 
-		if nx >= 0 && nx < consts.MapWidth && ny >= 0 && ny < consts.MapWidth && !visited[point{nx, ny}] {
+		if nx >= 0 && nx < m.size && ny >= 0 && ny < m.size && !visited[point{nx, ny}] {
 			m.removeWall(point{px, py}, point{nx, ny}, direction)
 			visited[point{nx, ny}] = true
 
@@ -154,9 +156,9 @@ func (m *Map) removeWall(p1, p2 point, direction int) {
 }
 
 func (m *Map) subdivise(n int) [][]cell {
-	nm := make([][]cell, consts.MapWidth*n)
+	nm := make([][]cell, m.size*n)
 	for i := range nm {
-		nm[i] = make([]cell, consts.MapWidth*n)
+		nm[i] = make([]cell, m.size*n)
 	}
 
 	for i, row := range m.grid {
@@ -178,16 +180,17 @@ func (m *Map) subdivise(n int) [][]cell {
 }
 
 func (m *Map) countWallsInSubsquares(n int) {
-	m.discreteGrid = make([][]uint8, (consts.MapWidth / n))
-	for i := 0; i < (consts.MapWidth / n); i++ {
-		m.discreteGrid[i] = make([]uint8, (consts.MapWidth / n))
+	size := (m.size + n - 1) / n
+	m.discreteGrid = make([][]uint8, size)
+	for i := 0; i < size; i++ {
+		m.discreteGrid[i] = make([]uint8, size)
 	}
 
-	for i := 0; i < consts.MapWidth; i += n {
-		for j := 0; j < consts.MapWidth; j += n {
+	for i := 0; i < m.size; i += n {
+		for j := 0; j < m.size; j += n {
 			count := uint8(0)
-			for k := i; k < n+i && k < consts.MapWidth; k++ {
-				for l := j; l < n+j && l < consts.MapWidth; l++ {
+			for k := i; k < n+i && k < m.size; k++ {
+				for l := j; l < n+j && l < m.size; l++ {
 					if m.grid[k][l].n && k == i {
 						count++
 					}
@@ -301,8 +304,8 @@ func (m *Map) getSpawnPoints(distances [][]int, min int, focusedRange int) {
 		return m == 0 || m == int(consts.NumSubsquare)-1
 	}
 
-	for i := 0; i < consts.MapWidth; i++ {
-		for j := 0; j < consts.MapWidth; j++ {
+	for i := 0; i < m.size; i++ {
+		for j := 0; j < m.size; j++ {
 			center := &model.Point{
 				X: float64(j*consts.CellWidth + consts.CellWidth/2),
 				Y: float64(i*consts.CellWidth + consts.CellWidth/2),
@@ -336,9 +339,20 @@ func (m *Map) getSpawnPoints(distances [][]int, min int, focusedRange int) {
 		}
 	}
 
+	// Small or open maps may not have any point as far as the focused range.
+	farthest := 0
+	for dist := range points {
+		if dist != math.MaxInt32 && dist > farthest {
+			farthest = dist
+		}
+	}
+	if focusedRange > farthest-1 {
+		focusedRange = farthest - 1
+	}
+
 	positions := make([]*model.Point, 0, min)
 
-	for i := focusedRange - 1; i <= focusedRange+1 || len(positions) < min; i++ {
+	for i := focusedRange - 1; (i <= focusedRange+1 || len(positions) < min) && i <= farthest; i++ {
 		if pts, ok := points[i]; ok {
 			positions = append(positions, pts...)
 		}
@@ -347,30 +361,43 @@ func (m *Map) getSpawnPoints(distances [][]int, min int, focusedRange int) {
 	m.spawns[1] = positions
 }
 
-func (m *Map) Setup() {
+// maxSetupAttempts is the number of maps generated at most to find one with enough
+// spawns far from the start.
+const maxSetupAttempts = 10
+
+func (m *Map) Setup(r *rand.Rand, rules *model.GameRules) {
+	if rules.MapLayout != nil {
+		m.load(r, rules.MapLayout)
+		return
+	}
+
 	spawns := 0
-	m.size = consts.MapWidth
+	m.size = rules.MapWidth
 
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	gen, ok := generators[rules.MapGenerator]
+	if !ok {
+		gen = generators[DefaultGenerator]
+	}
 
-	for spawns < 40 {
-		grid := make([][]cell, consts.MapWidth)
+	for attempt := 0; spawns < 40 && attempt < maxSetupAttempts; attempt++ {
+		grid := make([][]cell, m.size)
 		for i := range grid {
-			grid[i] = make([]cell, consts.MapWidth)
+			grid[i] = make([]cell, m.size)
 			for j := range grid[i] {
 				grid[i][j] = cell{true, true, true, true}
 			}
 		}
 		m.grid = grid
 
-		start := point{rand.Intn(m.size), rand.Intn(m.size)}
-		m.primGenerateMaze(start)
+		start := point{r.Intn(m.size), r.Intn(m.size)}
+		gen.generate(r, m, start)
 		m.generateColliders()
 
 		m.countWallsInSubsquares(2)
 
 		m.start = model.Point{X: float64(start.x*consts.CellWidth + consts.CellWidth/2), Y: float64(start.y*consts.CellWidth + consts.CellWidth/2)}
-		distances := m.dijkstra(point{x: start.x * consts.NumSubsquare, y: start.y * consts.NumSubsquare}, m.subdivise(consts.NumSubsquare))
+		m.subgrid = m.subdivise(consts.NumSubsquare)
+		distances := m.dijkstra(point{x: start.x * consts.NumSubsquare, y: start.y * consts.NumSubsquare}, m.subgrid)
 		m.getSpawnPoints(distances, 40, 40)
 		spawns = len(m.spawns[1])
 	}
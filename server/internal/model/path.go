@@ -0,0 +1,96 @@
+package model
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/capucinoxx/jdis-games-2024/consts"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+)
+
+// Path returns the waypoints of a shortest path from `from` to `to`, found with A* on the
+// grid of subsquares. The waypoints are the centers of the subsquares where the path
+// turns, followed by `to`, so that a player going from one to the next follows the
+// corridors. It returns false when a position is outside of the map or cannot be reached.
+func (m *Map) Path(from, to model.Point) ([]model.Point, bool) {
+	grid := m.subgrid
+	start, ok := m.subsquareOf(from)
+	if !ok {
+		return nil, false
+	}
+	goal, ok := m.subsquareOf(to)
+	if !ok {
+		return nil, false
+	}
+
+	height := func(p point) int {
+		return int(math.Abs(float64(p.x-goal.x)) + math.Abs(float64(p.y-goal.y)))
+	}
+
+	costs := map[point]int{start: 0}
+	previous := make(map[point]point)
+
+	pq := make(priorityQueue, 0)
+	heap.Push(&pq, &item{start, height(start), 0})
+
+	for pq.Len() > 0 {
+		pos := heap.Pop(&pq).(*item).pos
+		if pos == goal {
+			return m.waypoints(previous, start, goal, to), true
+		}
+
+		for i, dir := range directions {
+			next := point{pos.x + dir.x, pos.y + dir.y}
+			if next.x < 0 || next.x >= len(grid) || next.y < 0 || next.y >= len(grid) || grid[pos.x][pos.y].isWall(i) {
+				continue
+			}
+
+			cost := costs[pos] + 1
+			if known, ok := costs[next]; ok && known <= cost {
+				continue
+			}
+
+			costs[next] = cost
+			previous[next] = pos
+			heap.Push(&pq, &item{next, cost + height(next), 0})
+		}
+	}
+
+	return nil, false
+}
+
+// subsquareOf returns the subsquare holding the position p, as a (row, column) point.
+func (m *Map) subsquareOf(p model.Point) (point, bool) {
+	row, col := int(math.Floor(p.Y/consts.SubsquareWidth)), int(math.Floor(p.X/consts.SubsquareWidth))
+	if row < 0 || row >= len(m.subgrid) || col < 0 || col >= len(m.subgrid) {
+		return point{}, false
+	}
+	return point{row, col}, true
+}
+
+// waypoints walks back the path found from start to goal, keeping the subsquares where
+// it turns.
+func (m *Map) waypoints(previous map[point]point, start, goal point, to model.Point) []model.Point {
+	center := func(p point) model.Point {
+		return model.Point{
+			X: (float64(p.y) + 0.5) * consts.SubsquareWidth,
+			Y: (float64(p.x) + 0.5) * consts.SubsquareWidth,
+		}
+	}
+
+	path := []point{goal}
+	for current := goal; current != start; {
+		current = previous[current]
+		path = append(path, current)
+	}
+
+	waypoints := []model.Point{}
+	for i := len(path) - 2; i > 0; i-- {
+		before, after := path[i+1], path[i-1]
+		if before.x != after.x && before.y != after.y {
+			waypoints = append(waypoints, center(path[i]))
+		}
+	}
+
+	return append(waypoints, to)
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/capucinoxx/jdis-games-2024/consts"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+)
+
+func TestMapPath(t *testing.T) {
+	rules := model.DefaultGameRules()
+	rules.MapWidth = 2
+	rules.MapLayout = &model.MapLayout{
+		Size:   2,
+		Walls:  []model.WallSegment{{From: model.Point{X: 0, Y: 1}, To: model.Point{X: 1, Y: 1}}},
+		Spawns: [2][]model.Point{{{X: 5, Y: 15}}, {{X: 15, Y: 5}}},
+		Coin:   model.Point{X: 5, Y: 5},
+	}
+
+	m := &Map{}
+	m.Setup(rand.New(rand.NewSource(42)), rules)
+
+	space := model.NewSpatialGrid(20, 10)
+	space.SetWalls(m.Colliders())
+
+	from, to := model.Point{X: 5, Y: 5}, model.Point{X: 5, Y: 15}
+	path, ok := m.Path(from, to)
+	if !ok || len(path) < 2 || path[len(path)-1] != to {
+		t.Fatalf("Path() = (%v, %v), expected a path ending at %v", path, ok, to)
+	}
+
+	// The path goes around the wall between the two positions.
+	for i, waypoint := range path {
+		if _, hit := space.SweepWalls(from, waypoint, 0); hit {
+			t.Errorf("Leg %d from %v to %v goes through a wall", i, from, waypoint)
+		}
+		from = waypoint
+	}
+
+	if _, ok := m.Path(model.Point{X: 5, Y: 5}, model.Point{X: 25, Y: 5}); ok {
+		t.Errorf("Expected no path to a position outside of the map")
+	}
+}
+
+func TestSubsquareOf(t *testing.T) {
+	m := &Map{}
+	m.load(rand.New(rand.NewSource(42)), &model.MapLayout{Size: 2})
+
+	tests := map[string]struct {
+		pos      model.Point
+		expected point
+		ok       bool
+	}{
+		"Top left corner":     {pos: model.Point{X: 0, Y: 0}, expected: point{0, 0}, ok: true},
+		"Row from y, col x":   {pos: model.Point{X: 1.5 * consts.SubsquareWidth, Y: 3.5 * consts.SubsquareWidth}, expected: point{3, 1}, ok: true},
+		"Outside of the map":  {pos: model.Point{X: 20, Y: 5}},
+		"Negative coordinate": {pos: model.Point{X: -1, Y: 5}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := m.subsquareOf(tt.pos)
+			if ok != tt.ok || got != tt.expected {
+				t.Errorf("subsquareOf(%v) = (%v, %v), want (%v, %v)", tt.pos, got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestWaypoints(t *testing.T) {
+	// An L-shaped path from (0, 0) down to (2, 0) then right to (2, 2).
+	steps := []point{{0, 0}, {1, 0}, {2, 0}, {2, 1}, {2, 2}}
+	previous := make(map[point]point)
+	for i := 1; i < len(steps); i++ {
+		previous[steps[i]] = steps[i-1]
+	}
+
+	to := model.Point{X: 2.4 * consts.SubsquareWidth, Y: 2.6 * consts.SubsquareWidth}
+	got := (&Map{}).waypoints(previous, steps[0], steps[len(steps)-1], to)
+
+	corner := model.Point{X: 0.5 * consts.SubsquareWidth, Y: 2.5 * consts.SubsquareWidth}
+	if len(got) != 2 || got[0] != corner || got[1] != to {
+		t.Errorf("waypoints() = %v, want [%v %v]", got, corner, to)
+	}
+}
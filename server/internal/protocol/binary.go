@@ -13,17 +13,32 @@ func NewBinaryProtocol() *p.BinaryProtocol {
 	protocol := &p.BinaryProtocol{
 		EncodeHandlers: make(map[model.MessageType]func(w *codec.ByteWriter, message *model.ClientMessage)),
 		DecodeHandlers: make(map[model.MessageType]func(r *codec.ByteReader, message *model.ClientMessage)),
+
+		BinaryActionHandlers: make(map[model.MessageType]func(r *codec.ByteReader, message *model.ClientMessage)),
 	}
 
 	bp := BinaryProtocol{}
 	protocol.EncodeHandlers[model.MessageMapState] = bp.encodeMapState
 	protocol.EncodeHandlers[model.MessageGameEnd] = bp.encodeGameEnd
 	protocol.EncodeHandlers[model.MessageGameState] = bp.encodeGameState
+	protocol.EncodeHandlers[model.MessageGameStateDelta] = bp.encodeGameStateDelta
+	protocol.EncodeHandlers[model.MessageStateAck] = bp.encodeStateAck
+	protocol.EncodeHandlers[model.MessagePlayerAction] = bp.encodePlayerAction
+	protocol.EncodeHandlers[model.MessageActionEncoding] = bp.encodeActionEncoding
+	protocol.EncodeHandlers[model.MessageHello] = bp.encodeHello
+	protocol.EncodeHandlers[model.MessageGameEvents] = bp.encodeGameEvents
 
 	protocol.DecodeHandlers[model.MessageMapState] = bp.decodeMapState
 	protocol.DecodeHandlers[model.MessageGameEnd] = bp.decodeGameEnd
 	protocol.DecodeHandlers[model.MessageGameState] = bp.decodeGameState
+	protocol.DecodeHandlers[model.MessageGameStateDelta] = bp.decodeGameStateDelta
 	protocol.DecodeHandlers[model.MessagePlayerAction] = bp.decodePlayerAction
+	protocol.DecodeHandlers[model.MessageStateAck] = bp.decodeStateAck
+	protocol.DecodeHandlers[model.MessageActionEncoding] = bp.decodeActionEncoding
+	protocol.DecodeHandlers[model.MessageHello] = bp.decodeHello
+	protocol.DecodeHandlers[model.MessageGameEvents] = bp.decodeGameEvents
+
+	protocol.BinaryActionHandlers[model.MessagePlayerAction] = bp.decodeBinaryPlayerAction
 
 	return protocol
 }
@@ -40,6 +55,38 @@ func (b BinaryProtocol) encodeGameState(w *codec.ByteWriter, message *model.Clie
 	_ = data.Encode(w)
 }
 
+func (b BinaryProtocol) encodeGameStateDelta(w *codec.ByteWriter, message *model.ClientMessage) {
+	data := message.Body.(model.MessageGameStateDeltaToEncode)
+
+	_ = data.Encode(w)
+}
+
+func (b BinaryProtocol) encodeStateAck(w *codec.ByteWriter, message *model.ClientMessage) {
+	_ = w.WriteUint32(message.Body.(uint32))
+}
+
+func (b BinaryProtocol) encodePlayerAction(w *codec.ByteWriter, message *model.ClientMessage) {
+	action := message.Body.(model.PlayerAction)
+
+	_ = action.Encode(w)
+}
+
+func (b BinaryProtocol) encodeActionEncoding(w *codec.ByteWriter, message *model.ClientMessage) {
+	_ = w.WriteUint8(uint8(message.Body.(model.ActionEncoding)))
+}
+
+func (b BinaryProtocol) encodeHello(w *codec.ByteWriter, message *model.ClientMessage) {
+	hello := message.Body.(model.Hello)
+
+	_ = hello.Encode(w)
+}
+
+func (b BinaryProtocol) encodeGameEvents(w *codec.ByteWriter, message *model.ClientMessage) {
+	data := message.Body.(model.MessageGameEventsToEncode)
+
+	_ = data.Encode(w)
+}
+
 func (b BinaryProtocol) encodeGameEnd(w *codec.ByteWriter, message *model.ClientMessage) {}
 
 func (b BinaryProtocol) decodeGameEnd(r *codec.ByteReader, message *model.ClientMessage) {}
@@ -61,9 +108,64 @@ func (b BinaryProtocol) decodeGameState(r *codec.ByteReader, message *model.Clie
 }
 
 func (b BinaryProtocol) decodePlayerAction(r *codec.ByteReader, message *model.ClientMessage) {
-	var action model.Controls
+	var action model.PlayerAction
 
 	_ = r.ReadJSON(&action)
 
 	message.Body = action
 }
+
+func (b BinaryProtocol) decodeBinaryPlayerAction(r *codec.ByteReader, message *model.ClientMessage) {
+	var action model.PlayerAction
+
+	_ = action.Decode(r)
+
+	message.Body = action
+}
+
+func (b BinaryProtocol) decodeActionEncoding(r *codec.ByteReader, message *model.ClientMessage) {
+	encoding, err := r.ReadUint8()
+	if err != nil {
+		message.Body = nil
+		return
+	}
+
+	message.Body = model.ActionEncoding(encoding)
+}
+
+func (b BinaryProtocol) decodeHello(r *codec.ByteReader, message *model.ClientMessage) {
+	var hello model.Hello
+	if err := hello.Decode(r); err != nil {
+		message.Body = nil
+		return
+	}
+
+	message.Body = hello
+}
+
+func (b BinaryProtocol) decodeGameStateDelta(r *codec.ByteReader, message *model.ClientMessage) {
+	var delta model.MessageGameStateDeltaToDecode
+	delta.Decode(r)
+
+	message.Body = delta
+}
+
+func (b BinaryProtocol) decodeStateAck(r *codec.ByteReader, message *model.ClientMessage) {
+	sequence, err := r.ReadUint32()
+	if err != nil {
+		message.Body = nil
+		return
+	}
+
+	message.Body = sequence
+}
+
+func (b BinaryProtocol) decodeGameEvents(r *codec.ByteReader, message *model.ClientMessage) {
+	var events model.MessageGameEventsToDecode
+	if err := events.Decode(r); err != nil {
+		message.Body = nil
+		return
+	}
+
+	message.Body = events
+}
@@ -1,12 +1,12 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
-	"github.com/capucinoxx/jdis-games-2024/consts"
 	"github.com/capucinoxx/jdis-games-2024/internal/handler"
 	iManager "github.com/capucinoxx/jdis-games-2024/internal/manager"
 	iModel "github.com/capucinoxx/jdis-games-2024/internal/model"
@@ -14,6 +14,7 @@ import (
 	"github.com/capucinoxx/jdis-games-2024/pkg/config"
 	"github.com/capucinoxx/jdis-games-2024/pkg/connector"
 	"github.com/capucinoxx/jdis-games-2024/pkg/manager"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
 	"github.com/capucinoxx/jdis-games-2024/pkg/network"
 )
 
@@ -35,30 +36,68 @@ func init_redis() *connector.RedisService {
 	return service
 }
 
+// newRoundManager creates a round manager with the stages of the game.
+func newRoundManager() *iManager.RoundManager {
+	rm := iManager.NewRoundManager()
+	rm.AddChangeStageHandler(&iManager.DiscoveryStage{})
+	rm.AddChangeStageHandler(&iManager.PointRushStage{})
+	return rm
+}
+
+// init_stores creates the user and leaderboard stores for the given storage backend.
+func init_stores(storage string) (manager.UserStore, manager.LeaderboardStore) {
+	switch storage {
+	case "memory":
+		return manager.NewMemoryUserStore(), manager.NewMemoryLeaderboardStore()
+	case "database":
+		mongo := init_mongo()
+		redis := init_redis()
+		return manager.NewMongoUserStore(mongo), manager.NewDatabaseLeaderboardStore(redis, mongo)
+	}
+
+	log.Fatalf("unknown storage %q", storage)
+	return nil, nil
+}
+
 func main() {
-	mongo := init_mongo()
-	redis := init_redis()
+	storage := flag.String("storage", "database", "storage of users and scores (database, memory)")
+	flag.Parse()
 
-	transport := network.NewNetwork("0.0.0.0", config.Port())
+	users, leaderboard := init_stores(*storage)
 
-	sm := manager.NewScoreManager(redis, mongo)
+	transport := network.NewNetwork("0.0.0.0", config.Port())
+	// The memory storage is meant for local development, where there is no certificate.
+	transport.AllowPlainHTTP(*storage == "memory")
 
-	am := manager.NewAuthManager(mongo)
+	am := manager.NewAuthManager(users)
 	am.SetupAdmins(config.RequiredAdmins())
 
-	nm := manager.NewNetworkManager(transport, protocol.NewBinaryProtocol())
-	rm := iManager.NewRoundManager()
-	gm := manager.NewGameManager(am, nm, rm, sm, &iModel.Map{})
-
-	rm.AddChangeStageHandler(0, &iManager.DiscoveryStage{})
-	rm.AddChangeStageHandler(consts.TicksPointRushStage, &iManager.PointRushStage{})
+	rooms := manager.NewRoomManager(transport, protocol.NewBinaryProtocol(), am, leaderboard, users,
+		func() manager.RoundManager { return newRoundManager() },
+		func() model.Map { return &iModel.Map{} })
+	rooms.SetReplayDirectory(config.ReplayDirectory())
+
+	if path := config.RulesFile(); path != "" {
+		rules, err := model.LoadGameRules(path)
+		if err != nil {
+			log.Fatalf("unable to load rules %s: %s", path, err)
+		}
+		rooms.SetRules(rules)
+	}
 
-	transport.SetRegisterFunc(gm.RegisterConnection)
-	transport.SetUnregisterFunc(gm.RemoveConnection)
+	configs, err := config.Rooms()
+	if err != nil {
+		log.Fatalf("unable to load rooms: %s", err)
+	}
+	for _, room := range configs {
+		if _, err := rooms.Create(manager.RoomConfig{Name: room.Name, Ranked: room.Ranked}); err != nil {
+			log.Fatalf("unable to create room %s: %s", room.Name, err)
+		}
+	}
 
 	go func() {
-		handler.NewHttpHandler(gm, am, sm).Handle()
-		log.Fatal(gm.Initialize())
+		handler.NewHttpHandler(rooms, am).Handle()
+		log.Fatal(rooms.Start())
 	}()
 
 	sigs := make(chan os.Signal, 1)
@@ -27,6 +27,9 @@ type Reader interface {
 	ReadBytes(n int) ([]byte, error)
 	ReadString() (string, error)
 	ReadJSON(v interface{}) error
+
+	// Len returns the number of bytes left to read.
+	Len() int
 }
 
 type Writer interface {
@@ -83,6 +86,11 @@ func (r *ByteReader) ReadByte() (byte, error) {
 	return b, nil
 }
 
+// Len returns the number of bytes left to read.
+func (r *ByteReader) Len() int {
+	return max(0, len(r.data)-r.pos)
+}
+
 func (r *ByteReader) ResetPos() {
 	r.pos = 0
 }
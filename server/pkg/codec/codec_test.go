@@ -3,9 +3,11 @@ package codec_test
 import (
 	"encoding/binary"
 	"math"
+	"reflect"
 	"testing"
 
 	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
 )
 
 func floatEqual[T float32 | float64](a, b T) bool {
@@ -205,3 +207,57 @@ func TestEncodeDecode(t *testing.T) {
 		})
 	}
 }
+
+func TestPlayerActionEncodeDecode(t *testing.T) {
+	weapon := model.PlayerWeaponBlade
+	rotation := 12.5
+	save := "c2F2ZQ=="
+
+	tests := []struct {
+		name       string
+		action     model.PlayerAction
+		size       int
+		shouldFail bool
+	}{
+		{"empty action", model.PlayerAction{}, 5, false},
+		{"destination only", model.PlayerAction{Sequence: 42, Controls: model.Controls{Dest: &model.Point{X: 1.5, Y: -2}}}, 21, false},
+		{"every control", model.PlayerAction{Sequence: 7, Controls: model.Controls{
+			Dest:         &model.Point{X: 10, Y: 20},
+			Shoot:        &model.Point{X: 30.25, Y: 40.75},
+			SwitchWeapon: &weapon,
+			RotateBlade:  &rotation,
+			Save:         &save,
+		}}, 5 + 16 + 16 + 1 + 8 + len(save) + 1, false},
+		{"corrupted message", model.PlayerAction{Controls: model.Controls{Dest: &model.Point{X: 1, Y: 2}}}, 21, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := codec.NewByteWriter(binary.LittleEndian)
+			if err := tt.action.Encode(writer); err != nil {
+				t.Errorf("Unexpected error %v\n", err)
+			}
+
+			bytes := writer.Bytes()
+			if len(bytes) != tt.size {
+				t.Errorf("Expected %d bytes, got %d", tt.size, len(bytes))
+			}
+
+			if tt.shouldFail {
+				bytes = bytes[:len(bytes)-8]
+			}
+
+			var res model.PlayerAction
+			err := res.Decode(codec.NewByteReader(bytes, binary.LittleEndian))
+			if !tt.shouldFail && err != nil {
+				t.Errorf("Unexpected error %v\n", err)
+			} else if tt.shouldFail && err == nil {
+				t.Errorf("Expected error")
+			}
+
+			if !tt.shouldFail && !reflect.DeepEqual(tt.action, res) {
+				t.Errorf("Expect equals (%+v) != (%+v)", tt.action, res)
+			}
+		})
+	}
+}
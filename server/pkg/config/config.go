@@ -45,3 +45,39 @@ func Port() int {
 	}
 	return v
 }
+
+// ReplayDirectory returns the directory where games are recorded, in a subdirectory per
+// room. Games are not recorded when it is empty.
+func ReplayDirectory() string {
+	return os.Getenv("REPLAY_DIR")
+}
+
+// Room is a room created when the server starts.
+type Room struct {
+	Name   string `json:"name"`
+	Ranked bool   `json:"ranked"`
+}
+
+// Rooms returns the rooms created when the server starts, from the ROOMS environment
+// variable (e.g. [{"name": "rank", "ranked": true}, {"name": "unrank"}]). The first room
+// is the default one. Without it, a single room named "default" is created, ranked when
+// RANK is RANKED. An invalid ROOMS value is an error.
+func Rooms() ([]Room, error) {
+	var rooms []Room
+	if v := os.Getenv("ROOMS"); v != "" {
+		if err := json.Unmarshal([]byte(v), &rooms); err != nil {
+			return nil, fmt.Errorf("invalid ROOMS: %w", err)
+		}
+	}
+
+	if len(rooms) == 0 {
+		rooms = []Room{{Name: "default", Ranked: os.Getenv("RANK") == "RANKED"}}
+	}
+	return rooms, nil
+}
+
+// RulesFile returns the path of the JSON file holding the game rules. The default
+// rules are used when it is empty.
+func RulesFile() string {
+	return os.Getenv("RULES_FILE")
+}
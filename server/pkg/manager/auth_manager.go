@@ -1,67 +1,66 @@
 package manager
 
-// AuthManager handles user authentication and registration using a MongoDB service.
+// AuthManager handles user authentication and registration using a UserStore.
 // This manager provides functionality for registering new users, authenticating users
 // based on a token, and retrieving a list of registered users. It ensures that user
-// information is securely stored and efficiently retrieved from the database.
+// information is securely stored and efficiently retrieved from the store.
 
 import (
 	"errors"
 
-	"github.com/capucinoxx/jdis-games-2024/pkg/connector"
 	"github.com/capucinoxx/jdis-games-2024/pkg/utils"
 	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
-// TokenInfo represents the structure of a user's token information stored in MongoDB.
+// TokenInfo represents the structure of a user's token information stored in the user store.
 type TokenInfo struct {
 	Token    string `bson:"token" json:"token"`
 	Username string `bson:"username" json:"username"`
 	Color    int    `bson:"color" json:"color"`
+	Team     string `bson:"team" json:"team"`
 	IsAdmin  bool   `bson:"is_admin" json:"is_admin"`
 }
 
-// UserInfo represents the structure of a user's basic information retrieved from MongoDB.
+// UserInfo represents the structure of a user's basic information retrieved from the user store.
 type UserInfo struct {
 	Username string `bson:"username"`
 	Color    int    `bson:"color"`
+	Team     string `bson:"team"`
 	IsAdmin  bool   `bson:"is_admin"`
 }
 
 // AuthManager handles user authentication and registration.
 type AuthManager struct {
-	service    *connector.MongoService
-	collection string
+	store UserStore
 }
 
-// NewAuthManager creates a new AuthManager with the specified MongoDB service.
-func NewAuthManager(db *connector.MongoService) *AuthManager {
+// NewAuthManager creates a new AuthManager with the specified user store.
+func NewAuthManager(store UserStore) *AuthManager {
 	return &AuthManager{
-		service:    db,
-		collection: "users",
+		store: store,
 	}
 }
 
-// Register registers a new user with the specified username. It generates a unique token
-// for the user and stores their information in the MongoDB collection. If the user already
-// exists, an error is returned.
-func (am *AuthManager) Register(username string, isAdmin bool) (string, error) {
+// Register registers a new user with the specified username, in the given team (empty
+// for none). It generates a unique token for the user and stores their information in
+// the user store. If the user already exists, an error is returned.
+func (am *AuthManager) Register(username, team string, isAdmin bool) (string, error) {
 	if len(username) > 16 || len(username) < 3 {
 		return "", errors.New("username must be between 3 and 16 characters")
 	}
 
-	filter := bson.M{"username": username}
+	if len(team) > 16 {
+		return "", errors.New("team must be at most 16 characters")
+	}
 
-	if v, _ := am.service.FindOne(am.collection, filter); v != nil {
+	if v, _ := am.store.FindByUsername(username); v != nil {
 		return "", errors.New("user already exist")
 	}
 
 	token := am.uuid()
-	user := bson.M{"username": username, "token": token, "color": utils.NameColor(username), "is_admin": isAdmin}
+	user := TokenInfo{Username: username, Token: token, Color: int(utils.NameColor(username)), Team: team, IsAdmin: isAdmin}
 
-	_, err := am.service.Insert(am.collection, user)
-	if err != nil {
+	if err := am.store.Insert(user); err != nil {
 		return "", errors.New("error inserting user")
 	}
 
@@ -69,44 +68,19 @@ func (am *AuthManager) Register(username string, isAdmin bool) (string, error) {
 }
 
 func (am *AuthManager) List() ([]TokenInfo, error) {
-	v, err := am.service.Find(am.collection, bson.M{})
-	if err != nil {
-		return nil, err
-	}
-
-	result := make([]TokenInfo, 0, len(v))
-	for _, e := range v {
-		bytes, err := bson.Marshal(e)
-		if err != nil {
-			continue
-		}
-
-		var res TokenInfo
-		if err = bson.Unmarshal(bytes, &res); err != nil {
-			continue
-		}
-
-		result = append(result, res)
-	}
-
-	return result, nil
+	return am.store.List()
 }
 
 // Authenticate authenticates a user based on their token. It retrieves the user's information
-// from the MongoDB collection and returns their username, color, and a boolean indicating success.
-func (am *AuthManager) Authenticate(token string) (string, int, bool, bool) {
-	filter := bson.M{"token": token}
-	v, err := am.service.FindOne(am.collection, filter)
-	if v == nil || err != nil {
-		return "", 0, false, false
-	}
-
-	var result TokenInfo
-	if err = v.Decode(&result); err != nil {
-		return "", 0, false, false
+// from the user store and returns their username, color, team, whether they are an admin,
+// and a boolean indicating success.
+func (am *AuthManager) Authenticate(token string) (string, int, string, bool, bool) {
+	user, err := am.store.FindByToken(token)
+	if user == nil || err != nil {
+		return "", 0, "", false, false
 	}
 
-	return result.Username, result.Color, result.IsAdmin, true
+	return user.Username, user.Color, user.Team, user.IsAdmin, true
 }
 
 // uuid generates a new unique identifier for user tokens.
@@ -114,16 +88,16 @@ func (am *AuthManager) uuid() string {
 	return uuid.NewString()
 }
 
-// Users retrieves a list of all registered users with their username and color.
+// Users retrieves a list of all registered users with their username, color and team.
 func (am *AuthManager) Users() ([]UserInfo, error) {
-	bsonUsers, err := am.service.FindKeep(am.collection, bson.M{}, &bson.M{"username": 1, "color": 1})
+	tokens, err := am.store.List()
 	if err != nil {
 		return []UserInfo{}, err
 	}
 
-	users := make([]UserInfo, len(bsonUsers))
-	for i, user := range bsonUsers {
-		users[i] = UserInfo{Username: user["username"].(string), Color: user["color"].(int)}
+	users := make([]UserInfo, len(tokens))
+	for i, user := range tokens {
+		users[i] = UserInfo{Username: user.Username, Color: user.Color, Team: user.Team, IsAdmin: user.IsAdmin}
 	}
 
 	return users, nil
@@ -132,15 +106,12 @@ func (am *AuthManager) Users() ([]UserInfo, error) {
 func (am *AuthManager) SetupAdmins(admins []TokenInfo) {
 	count := 0
 	for _, admin := range admins {
-		filter := bson.M{"username": admin.Username}
-
-		if v, _ := am.service.FindOne(am.collection, filter); v != nil {
+		if v, _ := am.store.FindByUsername(admin.Username); v != nil {
 			continue
 		}
 
-		user := bson.M{"username": admin.Username, "token": admin.Token, "color": admin.Color, "is_admin": true}
-		_, err := am.service.Insert(am.collection, user)
-		if err == nil {
+		admin.IsAdmin = true
+		if err := am.store.Insert(admin); err == nil {
 			count++
 		}
 	}
@@ -0,0 +1,61 @@
+package manager
+
+import "testing"
+
+func TestAuthManagerRegisterAuthenticate(t *testing.T) {
+	am := NewAuthManager(NewMemoryUserStore())
+
+	tests := []struct {
+		name       string
+		username   string
+		team       string
+		shouldFail bool
+	}{
+		{"Valid username", "magellan", "", false},
+		{"Valid username in a team", "vespucci", "explorers", false},
+		{"Username too short", "ab", "", true},
+		{"Username too long", "abcdefghijklmnopq", "", true},
+		{"Team too long", "cabot", "abcdefghijklmnopq", true},
+		{"Duplicated user", "magellan", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := am.Register(tt.username, tt.team, false)
+			if tt.shouldFail {
+				if err == nil {
+					t.Errorf("Expected error when registering %q", tt.username)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+
+			username, _, team, isAdmin, ok := am.Authenticate(token)
+			if !ok || username != tt.username || team != tt.team || isAdmin {
+				t.Errorf("Authenticate(%q) = (%q, %q, %v, %v), want (%q, %q, false, true)",
+					token, username, team, isAdmin, ok, tt.username, tt.team)
+			}
+		})
+	}
+
+	if _, _, _, _, ok := am.Authenticate("unknown"); ok {
+		t.Errorf("Expected unknown token to be rejected")
+	}
+}
+
+func TestAuthManagerSetupAdmins(t *testing.T) {
+	am := NewAuthManager(NewMemoryUserStore())
+	am.SetupAdmins([]TokenInfo{{Username: "admin", Token: "secret", Color: 1}})
+	am.SetupAdmins([]TokenInfo{{Username: "admin", Token: "other", Color: 1}})
+
+	if _, _, _, isAdmin, ok := am.Authenticate("secret"); !ok || !isAdmin {
+		t.Errorf("Expected admin to be authenticated as admin")
+	}
+
+	if _, _, _, _, ok := am.Authenticate("other"); ok {
+		t.Errorf("Expected existing admin not to be overwritten")
+	}
+}
@@ -20,10 +20,13 @@ package manager
 
 import (
 	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/capucinoxx/jdis-games-2024/consts"
 	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/replay"
 	"github.com/capucinoxx/jdis-games-2024/pkg/utils"
 )
 
@@ -45,6 +48,28 @@ type GameManager struct {
 	rm        RoundManager
 	sm        *ScoreManager
 	state     *model.GameState
+
+	// events holds the events published during the last tick.
+	events []model.Event
+
+	// inputs returns the messages to process for a player during the current tick.
+	inputs func(p *model.Player) []model.ClientMessage
+
+	// replayDir is the directory where games are recorded. Recording is disabled
+	// when it is empty.
+	replayDir string
+	recorder  *replay.Recorder
+	recorded  map[string]bool
+
+	// commands holds the commands received during the game since the last tick. They
+	// are applied at the start of the next tick and recorded in its frame, so that a
+	// replay goes through the same changes as the game.
+	commands   []replay.Command
+	commandsMu sync.Mutex
+
+	// closed is set once the game manager is closed, its game loop then stops and no
+	// game can be started anymore.
+	closed atomic.Bool
 }
 
 // NewGameManager creates a new GameManager with the specified authentication, network, and round managers, and initial
@@ -54,14 +79,20 @@ func NewGameManager(am *AuthManager, nm *NetworkManager, rm RoundManager, sm *Sc
 	rm.SetState(state)
 
 	return &GameManager{
-		state: state,
-		am:    am,
-		nm:    nm,
-		sm:    sm,
-		rm:    rm,
+		state:  state,
+		am:     am,
+		nm:     nm,
+		sm:     sm,
+		rm:     rm,
+		inputs: clientInputs,
 	}
 }
 
+// SetReplayDirectory enables the recording of every game in the given directory.
+func (gm *GameManager) SetReplayDirectory(dir string) {
+	gm.replayDir = dir
+}
+
 // RegisterConnection registers a new connection, either as a player or a spectator.
 func (gm *GameManager) RegisterConnection(conn model.Connection, adminToken string) error {
 	if conn.Identifier() == "" {
@@ -82,99 +113,345 @@ func (gm *GameManager) addSpectator(conn model.Connection, token string) {
 
 	isAdmin := false
 	if token != "" {
-		_, _, isAdmin, _ = gm.am.Authenticate(token)
+		_, _, _, isAdmin, _ = gm.am.Authenticate(token)
 		conn.SetAdmin(isAdmin)
 	}
 
 	gm.nm.Register(client)
 	if gm.state.InProgess() {
-		gm.nm.Send(client, gm.nm.protocol.Encode(&model.ClientMessage{
-			MessageType: model.MessageMapState,
-			Body: model.MessageMapStateToEncode{
-				Map:     gm.state.Map,
-				IsAdmin: isAdmin,
-			},
-		}))
+		gm.nm.Send(client, gm.nm.encodeMapState(gm.state, isAdmin, [100]byte{}, client.ProtocolVersion()))
 	}
 }
 
 // addPlayer adds a new player to the game. A player is a client that is authenticated and can interact with the game.
 func (gm *GameManager) addPlayer(conn model.Connection) error {
-	username, color, isAdmin, ok := gm.am.Authenticate(conn.Identifier())
+	username, color, team, isAdmin, ok := gm.am.Authenticate(conn.Identifier())
 	if !ok {
 		return fmt.Errorf("unknown token")
 	}
+
+	if gm.state.TeamFull(team, username) {
+		return fmt.Errorf("team %s is full", team)
+	}
 	conn.SetAdmin(isAdmin)
 
+	if gm.state.Player(username) != nil {
+		gm.queue(replay.Command{Kind: replay.CommandReconnect, Nickname: username})
+	}
+
 	player := gm.state.AddPlayer(username, color, conn)
+	player.Team = team
 	gm.nm.Register(player.Client)
 
 	if gm.state.InProgess() {
-		gm.nm.Send(player.Client, gm.nm.protocol.Encode(&model.ClientMessage{
-			MessageType: model.MessageMapState,
-			Body: model.MessageMapStateToEncode{
-				Map:     gm.state.Map,
-				IsAdmin: isAdmin,
-				Storage: player.Storage(),
-			},
-		}))
+		gm.nm.Send(player.Client, gm.nm.encodeMapState(gm.state, isAdmin, player.Storage(), player.Client.ProtocolVersion()))
 	}
 
 	return nil
 }
 
-func (gm *GameManager) RemoveConnection(conn model.Connection) {}
+// resendMapState sends the map state again to a client which negotiated a newer protocol
+// during a game. The map state sent when it connected was encoded before its hello, for
+// ProtocolVersion1.
+func (gm *GameManager) resendMapState(client *model.Client) {
+	version := client.ProtocolVersion()
+	if version == model.ProtocolVersion1 || !gm.state.InProgess() {
+		return
+	}
 
-// Initialize starts the network manager and prepares the game for execution.
-func (gm *GameManager) Initialize() error {
-	return gm.nm.Start()
+	storage := [100]byte{}
+	for _, player := range gm.state.Players() {
+		if player.Client == client {
+			storage = player.Storage()
+		}
+	}
+	gm.nm.Reply(client, gm.nm.encodeMapState(gm.state, client.GetConnection().IsAdmin(), storage, version))
 }
 
+// RemoveConnection records the disconnection of a player during a game. The network
+// manager calls it with every connection it unregisters.
+func (gm *GameManager) RemoveConnection(conn model.Connection) {
+	if conn.Identifier() == "" {
+		return
+	}
+
+	for _, player := range gm.state.Players() {
+		if player.Client.GetConnection() == conn {
+			gm.queue(replay.Command{Kind: replay.CommandDisconnect, Nickname: player.Nickname})
+			return
+		}
+	}
+}
+
+// Close stops the game in progress at the end of its current tick. No game can be
+// started afterwards.
+func (gm *GameManager) Close() {
+	gm.closed.Store(true)
+}
+
+// Freeze prevents the next game from starting, or allows it again. It does not stop the
+// game in progress.
 func (gm *GameManager) Freeze(b bool) {
 	gm.state.SetFreeze(b)
+
+	kind := replay.CommandUnfreeze
+	if b {
+		kind = replay.CommandFreeze
+	}
+	gm.queue(replay.Command{Kind: kind})
+}
+
+// InProgress returns true if a game is currently being played.
+func (gm *GameManager) InProgress() bool {
+	return gm.state.InProgess()
 }
 
 // Start starts the game, initializing the game state and starting the game loop.
 func (gm *GameManager) Start() {
+	if gm.closed.Load() {
+		return
+	}
+
 	if !gm.state.IsFreeze() && !gm.state.InProgess() {
 		gm.state.Start()
+		gm.takeCommands()
+		gm.startRecording()
 
 		gm.rm.Restart()
 		go gm.gameLoop()
 	}
 }
 
-// Kill foribly removes a player from the game by setting their health to 0.
-// This is used for debugging purposes.
+// SetSeed sets the seed of the next game started, allowing a previous match to be
+// replayed with the exact same map and coin sequence.
+func (gm *GameManager) SetSeed(seed int64) {
+	gm.state.SetSeed(seed)
+}
+
+// SetRules sets the rules of the next game started. The game in progress is not affected.
+func (gm *GameManager) SetRules(rules *model.GameRules) {
+	gm.state.SetRules(rules)
+}
+
+// Rules returns the rules of the next game started.
+func (gm *GameManager) Rules() model.GameRules {
+	return gm.state.NextRules()
+}
+
+// Path returns the waypoints of a shortest path between two positions of the map of the
+// current game. It returns false when there is no such path or when the map cannot find
+// paths.
+func (gm *GameManager) Path(from, to model.Point) ([]model.Point, bool) {
+	return gm.state.Path(from, to)
+}
+
+// Subscribe calls fn with every event of the games played, at the end of the tick
+// during which it happened, until the returned function is called.
+func (gm *GameManager) Subscribe(fn func(model.Event)) (unsubscribe func()) {
+	return gm.state.Events().Subscribe(fn)
+}
+
+// Kill foribly removes a player from the game by setting their health to 0, without
+// crediting anyone with the kill. This is used for debugging purposes. The player is
+// killed at the start of the next tick.
 func (gm *GameManager) Kill(name string) {
-	for _, player := range gm.state.Players() {
-		if player.Nickname == name {
-			player.TakeDmg(1_000_000)
-			return
-		}
+	gm.queue(replay.Command{Kind: replay.CommandKill, Nickname: name})
+}
+
+// queue adds a command to apply at the start of the next tick. Commands received while
+// no game is in progress are dropped.
+func (gm *GameManager) queue(command replay.Command) {
+	if !gm.state.InProgess() {
+		return
 	}
+
+	gm.commandsMu.Lock()
+	defer gm.commandsMu.Unlock()
+	gm.commands = append(gm.commands, command)
 }
 
-// process processes player actions and updates the game state.
-func (gm *GameManager) process(p *model.Player, players []*model.Player, timestep float64, handleAction bool) {
+// takeCommands returns the commands queued since the last call.
+func (gm *GameManager) takeCommands() []replay.Command {
+	gm.commandsMu.Lock()
+	defer gm.commandsMu.Unlock()
+
+	commands := gm.commands
+	gm.commands = nil
+	return commands
+}
+
+// applyCommand applies a command to the game state. The freezes and the connection
+// changes already happened when they were received, they are only recorded.
+func applyCommand(state *model.GameState, command replay.Command) {
+	if command.Kind != replay.CommandKill {
+		return
+	}
+
+	if player := state.Player(command.Nickname); player != nil {
+		player.Kill()
+	}
+}
+
+// clientInputs drains the messages received from the player's client since the last tick.
+func clientInputs(p *model.Player) []model.ClientMessage {
+	messages := make([]model.ClientMessage, 0, len(p.Client.In))
 	for len(p.Client.In) != 0 {
-		message := <-p.Client.In
+		messages = append(messages, <-p.Client.In)
+	}
+	return messages
+}
 
+// process processes player actions and updates the game state.
+//
+// The actions received since the last tick are merged in the order received, the
+// controls set by an action overriding the ones of the previous actions, and the result
+// replaces the controls of the player. Actions older than the last one processed are
+// discarded.
+func (gm *GameManager) process(p *model.Player, timestep float64, handleAction bool) {
+	var merged *model.PlayerAction
+	last, _ := p.LastSequence()
+
+	for _, message := range gm.inputs(p) {
 		switch msgType := message.MessageType; msgType {
 		case model.MessagePlayerAction:
-			if handleAction {
-				p.Controls = message.Body.(model.Controls)
+			if !handleAction {
+				continue
 			}
+
+			action := message.Body.(model.PlayerAction)
+			if action.Sequence != 0 {
+				if action.Sequence <= last {
+					continue
+				}
+				last = action.Sequence
+			}
+
+			if merged == nil {
+				merged = &model.PlayerAction{}
+			}
+			merged.Merge(action.Controls)
+		}
+	}
+
+	if merged != nil {
+		p.Controls = merged.Controls
+		p.SetLastSequence(last, int32(gm.rm.CurrentTick()))
+
+		if gm.recorder != nil {
+			gm.recorder.Action(p.Nickname, p.Controls)
+		}
+	}
+
+	p.Update(gm.state, timestep)
+}
+
+// update advances the simulation by one tick. It returns true when every coin has been
+// collected, which ends the game.
+func (gm *GameManager) update(timestep float64) bool {
+	players := gm.state.Players()
+	gm.recordJoins(players)
+
+	for _, command := range gm.takeCommands() {
+		applyCommand(gm.state, command)
+		if gm.recorder != nil {
+			gm.recorder.Command(command.Kind, command.Nickname)
+		}
+	}
+
+	gm.rm.Tick()
+	gm.state.UpdateSpace(players, timestep)
+
+	for _, p := range players {
+		gm.process(p, timestep, true)
+		p.HandleRespawn(gm.state)
+	}
+	gm.state.UpdateZone(players)
+
+	over := gm.state.Coins().Update()
+	gm.events = gm.state.Events().Publish(int32(gm.rm.CurrentTick()))
+	gm.recordTick()
+	return over
+}
+
+// tickInterval returns the duration of a tick and the matching simulation timestep.
+func tickInterval(rules *model.GameRules) (time.Duration, float64) {
+	interval := time.Duration((int(1000 / rules.Tickrate))) * time.Millisecond
+	return interval, float64(interval/time.Millisecond) / 1000.0
+}
+
+// startRecording creates the replay file of the game about to start. It must be called
+// once the game state is started, before the first stage is triggered.
+func (gm *GameManager) startRecording() {
+	if gm.replayDir == "" {
+		return
+	}
+
+	players := gm.state.Players()
+	roster := make([]replay.Player, 0, len(players))
+	gm.recorded = make(map[string]bool, len(players))
+	for _, p := range players {
+		roster = append(roster, replay.Player{
+			Nickname: p.Nickname,
+			Color:    p.Color,
+			Team:     p.Team,
+			Weapon:   p.CurrentWeapon(),
+			Controls: p.Controls,
+		})
+		gm.recorded[p.Nickname] = true
+	}
+
+	name := fmt.Sprintf("%d-%d%s", time.Now().Unix(), gm.state.Seed(), replay.Extension)
+	recorder, err := replay.Create(filepath.Join(gm.replayDir, name), gm.state.Seed(), gm.state.Rules(), roster)
+	if err != nil {
+		utils.Log("error", "replay", "unable to create replay %s", err)
+		return
+	}
+
+	utils.Log("game", "replay", "recording game in %s", name)
+	gm.recorder = recorder
+}
+
+// recordJoins records the players who joined the game since the previous tick.
+func (gm *GameManager) recordJoins(players []*model.Player) {
+	if gm.recorder == nil {
+		return
+	}
+
+	for _, p := range players {
+		if !gm.recorded[p.Nickname] {
+			gm.recorder.Join(p.Nickname, p.Color, p.Team, *p.Position)
+			gm.recorded[p.Nickname] = true
 		}
 	}
+}
+
+// recordTick writes the inputs of the current tick in the replay file.
+func (gm *GameManager) recordTick() {
+	if gm.recorder == nil {
+		return
+	}
 
-	p.Update(players, gm.state, timestep)
+	if err := gm.recorder.EndTick(); err != nil {
+		utils.Log("error", "replay", "unable to record tick %s", err)
+		gm.stopRecording()
+	}
+}
+
+// stopRecording closes the replay file of the current game.
+func (gm *GameManager) stopRecording() {
+	if gm.recorder == nil {
+		return
+	}
+
+	if err := gm.recorder.Close(); err != nil {
+		utils.Log("error", "replay", "unable to close replay %s", err)
+	}
+	gm.recorder = nil
 }
 
 // gameLoop is the main game loop that handles game state updates and broadcasting game state to clients.
 func (gm *GameManager) gameLoop() {
-	interval := time.Duration((int(1000 / consts.Tickrate))) * time.Millisecond
-	timestep := float64(interval/time.Millisecond) / 1000.0
+	interval, timestep := tickInterval(gm.state.Rules())
 
 	for _, p := range gm.state.Players() {
 		p.ClearStorage()
@@ -185,18 +462,18 @@ func (gm *GameManager) gameLoop() {
 
 	count := 0
 	for range ticker.C {
+		if gm.closed.Load() {
+			gm.state.Stop()
+			break
+		}
 		gm.tickStart = time.Now()
-		players := gm.state.Players()
-
-		gm.rm.Tick()
 
-		for _, p := range players {
-			gm.process(p, players, timestep, true)
-			p.HandleRespawn(gm.state)
+		over := gm.update(timestep)
+		if len(gm.events) != 0 {
+			gm.nm.BroadcastEvents(gm.state, gm.events)
 		}
 
-		ok := gm.state.Coins().Update()
-		if ok {
+		if over {
 			gm.state.Stop()
 			break
 		}
@@ -215,6 +492,7 @@ func (gm *GameManager) gameLoop() {
 		}
 	}
 	ticker.Stop()
+	gm.stopRecording()
 
 	gm.nm.BroadcastGameEnd()
 	go func() {
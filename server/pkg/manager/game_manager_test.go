@@ -0,0 +1,231 @@
+package manager
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	iManager "github.com/capucinoxx/jdis-games-2024/internal/manager"
+	iModel "github.com/capucinoxx/jdis-games-2024/internal/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/replay"
+)
+
+func TestGameManagerProcessActions(t *testing.T) {
+	var queued []model.PlayerAction
+	gm := newHeadlessGameManager(iManager.NewRoundManager(), &iModel.Map{}, func(*model.Player) []model.ClientMessage {
+		messages := make([]model.ClientMessage, 0, len(queued))
+		for _, action := range queued {
+			messages = append(messages, model.ClientMessage{MessageType: model.MessagePlayerAction, Body: action})
+		}
+		queued = nil
+		return messages
+	})
+
+	p := gm.state.AddPlayer("alice", 0, &headlessConnection{name: "alice"})
+	gm.state.Start()
+	gm.rm.Restart()
+	defer gm.state.Stop()
+
+	weapon := model.PlayerWeaponBlade
+	rotation := 0.5
+	dest := model.Point{X: 1, Y: 1}
+	other := model.Point{X: 2, Y: 2}
+
+	tests := []struct {
+		name         string
+		actions      []model.PlayerAction
+		wantSequence uint32
+		wantDest     *model.Point
+	}{
+		{
+			name: "Actions merged in order",
+			actions: []model.PlayerAction{
+				{Sequence: 1, Controls: model.Controls{Dest: &dest, SwitchWeapon: &weapon}},
+				{Sequence: 2, Controls: model.Controls{Dest: &other}},
+				{Sequence: 3, Controls: model.Controls{RotateBlade: &rotation}},
+			},
+			wantSequence: 3,
+			wantDest:     &other,
+		},
+		{
+			name: "Stale actions discarded",
+			actions: []model.PlayerAction{
+				{Sequence: 5, Controls: model.Controls{Dest: &dest}},
+				{Sequence: 4, Controls: model.Controls{Dest: &other}},
+				{Sequence: 3, Controls: model.Controls{Dest: &other}},
+			},
+			wantSequence: 5,
+			wantDest:     &dest,
+		},
+		{
+			name:         "Unnumbered actions always applied",
+			actions:      []model.PlayerAction{{Controls: model.Controls{Dest: &other}}},
+			wantSequence: 5,
+			wantDest:     &other,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queued = tt.actions
+			gm.rm.Tick()
+			gm.process(p, 0, true)
+
+			sequence, tick := p.LastSequence()
+			if sequence != tt.wantSequence || tick != int32(gm.rm.CurrentTick()) {
+				t.Errorf("LastSequence() = (%d, %d), want (%d, %d)", sequence, tick, tt.wantSequence, gm.rm.CurrentTick())
+			}
+
+			if p.Controls.Dest == nil || *p.Controls.Dest != *tt.wantDest {
+				t.Errorf("Dest = %v, want %v", p.Controls.Dest, tt.wantDest)
+			}
+		})
+	}
+
+	if p.Controls.SwitchWeapon != nil || p.Controls.RotateBlade != nil {
+		t.Errorf("The merged action should replace the previous controls, got %+v", p.Controls)
+	}
+}
+
+func TestMapStateSeed(t *testing.T) {
+	m := &iModel.Map{}
+	m.Setup(rand.New(rand.NewSource(42)), model.DefaultGameRules())
+
+	tests := map[string]struct {
+		isAdmin  bool
+		version  uint16
+		expected int64
+	}{
+		"Seed sent to the admins":                        {isAdmin: true, version: model.ProtocolVersion2, expected: 42},
+		"Seed sent to the players":                       {isAdmin: false, version: model.ProtocolVersion2, expected: 42},
+		"Seed left out for the players before version 2": {isAdmin: false, version: model.ProtocolVersion1},
+		"Seed left out for the admins before version 2":  {isAdmin: true, version: model.ProtocolVersion1},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := codec.NewByteWriter(binary.LittleEndian)
+			message := model.MessageMapStateToEncode{Map: m, IsAdmin: tt.isAdmin, Seed: 42, Version: tt.version}
+			if err := message.Encode(w); err != nil {
+				t.Fatalf("Encode() error %v", err)
+			}
+
+			r := codec.NewByteReader(w.Bytes(), binary.LittleEndian)
+			decoded := model.MessageMapStateToDecode{Version: tt.version}
+			if err := decoded.Decode(r); err != nil {
+				t.Fatalf("Decode() error %v", err)
+			}
+
+			if decoded.Seed != tt.expected || r.Len() != 0 {
+				t.Errorf("Seed = %d with %d bytes left, want %d", decoded.Seed, r.Len(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestTeamFull(t *testing.T) {
+	gm := newHeadlessGameManager(iManager.NewRoundManager(), &iModel.Map{}, func(*model.Player) []model.ClientMessage { return nil })
+
+	rules := model.DefaultGameRules()
+	rules.TeamSize = 2
+	gm.state.SetRules(rules)
+	gm.state.Start()
+	defer gm.state.Stop()
+
+	for _, name := range []string{"alice", "bob"} {
+		gm.state.AddPlayer(name, 0, &headlessConnection{name: name}).Team = "red"
+	}
+
+	tests := map[string]struct {
+		team     string
+		username string
+		want     bool
+	}{
+		"Full team":           {team: "red", username: "carol", want: true},
+		"Member reconnecting": {team: "red", username: "alice", want: false},
+		"Other team":          {team: "blue", username: "carol", want: false},
+		"No team":             {team: "", username: "carol", want: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := gm.state.TeamFull(tt.team, tt.username); got != tt.want {
+				t.Errorf("TeamFull(%q, %q) = %v, want %v", tt.team, tt.username, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKillShieldedPlayer(t *testing.T) {
+	gm := newHeadlessGameManager(iManager.NewRoundManager(), &iModel.Map{}, clientInputs)
+	gm.state.Start()
+	defer gm.state.Stop()
+
+	p := gm.state.AddPlayer("alice", 0, &headlessConnection{name: "alice"})
+	p.SetPosition(model.Point{X: 10, Y: 10})
+	model.NewPowerUp(&model.Point{X: 10, Y: 10}, model.EffectShield, gm.state.Rules()).IsCollidingWithPlayer(p)
+	if !p.HasEffect(model.EffectShield) {
+		t.Fatalf("Expected the player to be shielded")
+	}
+
+	gm.Kill("alice")
+	gm.update(0)
+	if p.IsAlive() {
+		t.Errorf("Expected the admin to kill a shielded player")
+	}
+}
+
+func TestSimulateCommands(t *testing.T) {
+	rep := &replay.Replay{
+		Seed:    42,
+		Rules:   model.DefaultGameRules(),
+		Players: []replay.Player{{Nickname: "alice"}, {Nickname: "bob"}},
+		Frames: []replay.Frame{
+			{Tick: 1},
+			{Tick: 2, Commands: []replay.Command{{Kind: replay.CommandKill, Nickname: "alice"}, {Kind: replay.CommandFreeze}}},
+		},
+	}
+
+	var alive []bool
+	var frozen bool
+	Simulate(rep, iManager.NewRoundManager(), &iModel.Map{}, func(state *model.GameState, _ bool) {
+		alive = append(alive, state.Player("alice").IsAlive())
+		frozen = state.IsFreeze()
+	})
+
+	if len(alive) != 2 || !alive[0] || alive[1] {
+		t.Errorf("Expected alice to be killed on the second tick, alive %v", alive)
+	}
+
+	if !frozen {
+		t.Errorf("Expected the game to be frozen")
+	}
+}
+
+func TestPathWhileStarting(t *testing.T) {
+	gm := newHeadlessGameManager(iManager.NewRoundManager(), &iModel.Map{}, clientInputs)
+
+	started, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-started:
+				return
+			default:
+				gm.Path(model.Point{X: 5, Y: 5}, model.Point{X: 15, Y: 5})
+			}
+		}
+	}()
+
+	gm.state.Start()
+	defer gm.state.Stop()
+	close(started)
+	<-done
+
+	if _, ok := gm.Path(model.Point{X: 5, Y: 5}, model.Point{X: 15, Y: 5}); !ok {
+		t.Errorf("Expected a path once the map is set up")
+	}
+}
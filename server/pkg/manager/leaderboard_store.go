@@ -0,0 +1,201 @@
+package manager
+
+// LeaderboardStore abstracts the storage of the scores. Two implementations are provided:
+// one backed by Redis for the live leaderboard and MongoDB for the score histories, used
+// in production, and one kept in memory, which allows the server to run without any
+// database (local development, tests).
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/connector"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LeaderboardStore stores the cumulative score of the players and their history.
+type LeaderboardStore interface {
+	// Increment adds the given scores to the leaderboard.
+	Increment(scores []model.PlayerScore) error
+
+	// Ranking returns the leaderboard, from the highest score to the lowest.
+	Ranking() ([]model.PlayerScore, error)
+
+	// PushHistory appends the given scores, taken at the given time, to the history of
+	// the players.
+	PushHistory(scores []model.PlayerScore, at time.Time) error
+
+	// Histories returns the score history of the given players.
+	Histories(names []string) (map[string][]PlayerEntry, error)
+}
+
+// newPlayerEntry creates a history entry for a score taken at the given time.
+func newPlayerEntry(score int32, at time.Time) PlayerEntry {
+	return PlayerEntry{Score: score, Time: at.UnixMilli() / 10_000}
+}
+
+// DatabaseLeaderboardStore stores the live leaderboard in Redis and the score histories
+// in MongoDB.
+type DatabaseLeaderboardStore struct {
+	redis *connector.RedisService
+	mongo *connector.MongoService
+}
+
+// NewDatabaseLeaderboardStore creates a new DatabaseLeaderboardStore with the specified
+// Redis and MongoDB services.
+func NewDatabaseLeaderboardStore(redis *connector.RedisService, mongo *connector.MongoService) *DatabaseLeaderboardStore {
+	return &DatabaseLeaderboardStore{
+		redis: redis,
+		mongo: mongo,
+	}
+}
+
+func (s *DatabaseLeaderboardStore) Increment(scores []model.PlayerScore) error {
+	ctx := context.Background()
+	pipe := s.redis.Pipeline()
+
+	for _, player := range scores {
+		pipe.ZIncrBy(ctx, "leaderboard", float64(player.Score), player.Name)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *DatabaseLeaderboardStore) Ranking() ([]model.PlayerScore, error) {
+	val, err := s.redis.ZRevRangeWithScores(context.Background(), "leaderboard", 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make([]model.PlayerScore, len(val))
+	for i, v := range val {
+		scores[i] = model.PlayerScore{Name: v.Member.(string), Score: int(v.Score)}
+	}
+	return scores, nil
+}
+
+func (s *DatabaseLeaderboardStore) PushHistory(scores []model.PlayerScore, at time.Time) error {
+	var errs utils.Errors
+	for _, score := range scores {
+		if err := s.mongo.Push("scores", score.Name, "scores", bson.M{"score": int32(score.Score), "time": at}); err != nil {
+			errs.Append(err)
+		}
+	}
+	return errs.Error()
+}
+
+func (s *DatabaseLeaderboardStore) Histories(names []string) (map[string][]PlayerEntry, error) {
+	filter := bson.M{"_id": bson.M{"$in": names}}
+	res, err := s.mongo.Find("scores", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	histories := make(map[string][]PlayerEntry)
+	for _, history := range res {
+		name := history["_id"].(string)
+		entries, ok := history["scores"].(primitive.A)
+		if !ok {
+			utils.Log("error", "persist", "error retrieve scores")
+			continue
+		}
+
+		histories[name] = make([]PlayerEntry, 0, len(entries))
+		for _, entry := range entries {
+			doc, ok := entry.(bson.M)
+			if !ok {
+				utils.Log("error", "persist", "error casting score to bson.M")
+				continue
+			}
+
+			score, ok := doc["score"].(int32)
+			if !ok {
+				utils.Log("error", "persist", "error retrieving score as int %v", doc["score"])
+				continue
+			}
+
+			scoreTime, ok := doc["time"].(primitive.DateTime)
+			if !ok {
+				utils.Log("error", "persist", "error retrieving score time as time.Time, %v", doc["time"])
+				continue
+			}
+
+			histories[name] = append(histories[name], newPlayerEntry(score, scoreTime.Time()))
+		}
+	}
+
+	return histories, nil
+}
+
+// MemoryLeaderboardStore stores the leaderboard and the score histories in memory. Scores
+// are lost when the server stops.
+type MemoryLeaderboardStore struct {
+	scores    map[string]int
+	histories map[string][]PlayerEntry
+	mu        sync.Mutex
+}
+
+// NewMemoryLeaderboardStore creates a new empty MemoryLeaderboardStore.
+func NewMemoryLeaderboardStore() *MemoryLeaderboardStore {
+	return &MemoryLeaderboardStore{
+		scores:    make(map[string]int),
+		histories: make(map[string][]PlayerEntry),
+	}
+}
+
+func (s *MemoryLeaderboardStore) Increment(scores []model.PlayerScore) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, score := range scores {
+		s.scores[score.Name] += score.Score
+	}
+	return nil
+}
+
+func (s *MemoryLeaderboardStore) Ranking() ([]model.PlayerScore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scores := make([]model.PlayerScore, 0, len(s.scores))
+	for name, score := range s.scores {
+		scores = append(scores, model.PlayerScore{Name: name, Score: score})
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score == scores[j].Score {
+			return scores[i].Name > scores[j].Name
+		}
+		return scores[i].Score > scores[j].Score
+	})
+	return scores, nil
+}
+
+func (s *MemoryLeaderboardStore) PushHistory(scores []model.PlayerScore, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, score := range scores {
+		s.histories[score.Name] = append(s.histories[score.Name], newPlayerEntry(int32(score.Score), at))
+	}
+	return nil
+}
+
+func (s *MemoryLeaderboardStore) Histories(names []string) (map[string][]PlayerEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	histories := make(map[string][]PlayerEntry, len(names))
+	for _, name := range names {
+		if entries, ok := s.histories[name]; ok {
+			histories[name] = append([]PlayerEntry{}, entries...)
+		}
+	}
+	return histories, nil
+}
@@ -23,10 +23,13 @@ package manager
 // lifecycle of client connections and data flow throughout the game session.
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/capucinoxx/jdis-games-2024/pkg/model"
 	"github.com/capucinoxx/jdis-games-2024/pkg/network"
+	"github.com/capucinoxx/jdis-games-2024/pkg/utils"
 )
 
 const (
@@ -41,12 +44,23 @@ const (
 
 	// maxMessageSize is the maximum size of a message in bytes.
 	maxMessageSize = 1024
+
+	// snapshotHistory is the number of snapshots kept to encode deltas. A client whose
+	// last acknowledged snapshot is older receives a keyframe.
+	snapshotHistory = model.SnapshotHistory
+
+	// keyframeInterval is the number of snapshots between two keyframes sent to every
+	// client receiving deltas.
+	keyframeInterval = 10
 )
 
 // Protocol is an interface to encode and decode network messages.
 type Protocol interface {
 	Encode(message *model.ClientMessage) []byte
 	Decode(data []byte) model.ClientMessage
+
+	// DecodeWith decodes a message sent by a client whose actions use the given encoding.
+	DecodeWith(data []byte, encoding model.ActionEncoding) model.ClientMessage
 }
 
 // Network is an interface for network transports. It should be capable of initializing the network,
@@ -76,6 +90,35 @@ type NetworkManager struct {
 	// Messages sent here are broadcasted in the network manager's main loop.
 	broadcast chan []byte
 
+	// spectate is a channel used to send messages to spectators only, encoded for each
+	// protocol version.
+	spectate chan map[uint16][]byte
+
+	// replies is a channel used to send a message to a single client from its reader.
+	replies chan reply
+
+	// states is a channel used to send the game state to all connected clients, either
+	// in full or as a delta depending on the client.
+	states chan gameStateBroadcast
+
+	// events is a channel used to send the events of a tick to the clients which
+	// announced CapabilityGameEvents.
+	events chan eventsBroadcast
+
+	// snapshots captures the snapshots of the game. It is only used by the game loop.
+	snapshots *model.SnapshotBuilder
+
+	// history holds the last snapshots sent, indexed by sequence, for each client which
+	// has its own view of the game (fog of war) and for the shared view (nil key). It is
+	// only used by the network manager's main loop.
+	history map[*model.Client]*[snapshotHistory]*model.Snapshot
+
+	// fullVersions holds the protocol versions of the clients of the shared view which
+	// still receive the game state in full, as seen by the main loop at the last
+	// broadcast. The game loop only encodes the full game state for those versions.
+	fullVersions   map[uint16]bool
+	fullVersionsMu sync.Mutex
+
 	// register is a channel used for registering new clients to the server.
 	// Clients are added to the network manager's client map via this channel.
 	register chan *model.Client
@@ -84,6 +127,19 @@ type NetworkManager struct {
 	// It allows for clean removal of clients from the network manager's client map
 	// and proper resource cleanup.
 	unregister chan model.Connection
+
+	// players is the number of registered clients playing, the spectators left out. It
+	// is only changed by the main loop.
+	players atomic.Int32
+
+	// onUnregister is called by the main loop with every connection it unregisters.
+	onUnregister func(conn model.Connection)
+
+	// onHello is called by the reader of a client once its protocol is negotiated.
+	onHello func(client *model.Client)
+
+	// done is closed when the network manager is stopped.
+	done chan struct{}
 }
 
 // NewNetworkManager creates a new NetworkManager with the specified network transport and
@@ -94,21 +150,52 @@ func NewNetworkManager(transport *network.Network, protocol Protocol) *NetworkMa
 		protocol:   protocol,
 		clients:    make(map[model.Connection]*model.Client),
 		broadcast:  make(chan []byte),
+		spectate:   make(chan map[uint16][]byte),
+		replies:    make(chan reply),
+		states:     make(chan gameStateBroadcast),
+		events:     make(chan eventsBroadcast),
+		snapshots:  model.NewSnapshotBuilder(),
+		history:    make(map[*model.Client]*[snapshotHistory]*model.Snapshot),
 		register:   make(chan *model.Client),
 		unregister: make(chan model.Connection),
+		done:       make(chan struct{}),
 	}
 }
 
+// Players returns the number of players connected, the spectators left out.
+func (nm *NetworkManager) Players() int {
+	return int(nm.players.Load())
+}
+
+// SetUnregisterFunc sets the function called when a connection is unregistered. It must
+// be set before the main loop runs.
+func (nm *NetworkManager) SetUnregisterFunc(f func(conn model.Connection)) {
+	nm.onUnregister = f
+}
+
+// SetHelloFunc sets the function called once the protocol of a client is negotiated,
+// after the answer to its hello is sent. It must be set before the main loop runs.
+func (nm *NetworkManager) SetHelloFunc(f func(client *model.Client)) {
+	nm.onHello = f
+}
+
 // Address returns the server's address.
 func (nm *NetworkManager) Address() string {
 	return nm.transport.Address()
 }
 
-// Start initializes the NetworkManager and starts the server.
-func (nm *NetworkManager) Start() error {
-	nm.transport.Init()
-	go nm.run()
-	return nm.transport.Run()
+// Stop disconnects every client and stops the main loop. The messages sent afterwards
+// are dropped.
+func (nm *NetworkManager) Stop() {
+	close(nm.done)
+}
+
+// send sends v on ch, unless the network manager is stopped.
+func send[T any](nm *NetworkManager, ch chan T, v T) {
+	select {
+	case ch <- v:
+	case <-nm.done:
+	}
 }
 
 // run is the main loop of the NetworkManager. It handles incoming and outgoing messages,
@@ -117,21 +204,25 @@ func (nm *NetworkManager) Start() error {
 func (nm *NetworkManager) run() {
 	for {
 		select {
+		case <-nm.done:
+			for conn, client := range nm.clients {
+				conn.Close(writeWait, false)
+				client.Disconnect()
+				nm.transport.Unregister(conn)
+			}
+			return
+
 		case c := <-nm.register:
 			conn := c.GetConnection()
 			nm.clients[conn] = c
-			go nm.writer(c)
 			if conn.Identifier() != "" {
-				go nm.reader(c)
+				nm.players.Add(1)
 			}
+			go nm.writer(c)
+			go nm.reader(c)
 
 		case c := <-nm.unregister:
-			if client, ok := nm.clients[c]; ok {
-				client.Disconnect()
-				delete(nm.clients, c)
-
-				nm.transport.Unregister(c)
-			}
+			nm.remove(c)
 
 		case message := <-nm.broadcast:
 			for conn, client := range nm.clients {
@@ -145,20 +236,149 @@ func (nm *NetworkManager) run() {
 					nm.unregister <- conn
 				}
 			}
+
+		case state := <-nm.states:
+			nm.remember(nil, state.shared.snapshot)
+			for client, view := range state.views {
+				nm.remember(client, view.snapshot)
+			}
+			deltas := make(map[deltaKey][]byte)
+			versions := make(map[uint16]bool)
+
+			// The clients which cannot keep up are removed after the loop, the main loop
+			// being the only receiver of nm.unregister.
+			slow := []model.Connection{}
+			for conn, client := range nm.clients {
+				if client.IsBlind() {
+					continue
+				}
+
+				view, owner := state.shared, (*model.Client)(nil)
+				if v, ok := state.views[client]; ok {
+					view, owner = v, client
+				}
+
+				version := client.ProtocolVersion()
+				message := view.full[version]
+				if ack := client.Acknowledged(); ack != 0 {
+					message = nm.encodeDelta(owner, view.snapshot, ack, version, deltas)
+				} else if owner == nil {
+					versions[version] = true
+				}
+
+				// The state was not encoded for a client which just connected or changed
+				// its version, it receives the next one.
+				if message == nil {
+					continue
+				}
+
+				select {
+				case client.Out <- message:
+				default:
+					slow = append(slow, conn)
+				}
+			}
+			for _, conn := range slow {
+				nm.remove(conn)
+			}
+
+			nm.fullVersionsMu.Lock()
+			nm.fullVersions = versions
+			nm.fullVersionsMu.Unlock()
+
+		case events := <-nm.events:
+			slow := []model.Connection{}
+			for conn, client := range nm.clients {
+				if !client.Announced(model.CapabilityGameEvents) {
+					continue
+				}
+
+				message := events.shared
+				if events.views != nil && conn.Identifier() != "" {
+					if message = events.views[client]; message == nil {
+						continue
+					}
+				}
+
+				select {
+				case client.Out <- message:
+				default:
+					slow = append(slow, conn)
+				}
+			}
+			for _, conn := range slow {
+				nm.remove(conn)
+			}
+
+		case r := <-nm.replies:
+			conn := r.client.GetConnection()
+			if _, ok := nm.clients[conn]; !ok {
+				continue
+			}
+
+			select {
+			case r.client.Out <- r.message:
+			default:
+				nm.remove(conn)
+			}
+
+		case messages := <-nm.spectate:
+			slow := []model.Connection{}
+			for conn, client := range nm.clients {
+				if conn.Identifier() != "" {
+					continue
+				}
+
+				// A spectator speaking a version the message was not encoded for is skipped.
+				message := messages[client.ProtocolVersion()]
+				if message == nil {
+					continue
+				}
+
+				select {
+				case client.Out <- message:
+				default:
+					slow = append(slow, conn)
+				}
+			}
+			for _, conn := range slow {
+				nm.remove(conn)
+			}
 		}
 	}
 }
 
+// remove unregisters a connection and disconnects its client. It is only called by the
+// main loop.
+func (nm *NetworkManager) remove(conn model.Connection) {
+	client, ok := nm.clients[conn]
+	if !ok {
+		return
+	}
+
+	client.Disconnect()
+	delete(nm.clients, conn)
+	delete(nm.history, client)
+	if conn.Identifier() != "" {
+		nm.players.Add(-1)
+	}
+
+	nm.transport.Unregister(conn)
+	if nm.onUnregister != nil {
+		nm.onUnregister(conn)
+	}
+}
+
 // Register adds a player to the game and also sends the current state of the game to the player.
 // This method is called by the game loop when a client connects.
 func (nm *NetworkManager) Register(client *model.Client) {
-	nm.register <- client
+	send(nm, nm.register, client)
 }
 
 // ForceDisconnect forcibly disconnects a client from the game.
 func (nm *NetworkManager) ForceDisconnect(conn model.Connection) {
 	conn.Close(writeWait, false)
-	nm.unregister <- conn
+	send(nm, nm.unregister, conn)
 }
 
 // Send sends a message to a client.
@@ -166,59 +386,262 @@ func (nm *NetworkManager) Send(client *model.Client, message []byte) {
 	client.Out <- message
 }
 
+// Reply sends a message to a single client through the main loop, which drops it if the
+// client is no longer connected.
+func (nm *NetworkManager) Reply(client *model.Client, message []byte) {
+	send(nm, nm.replies, reply{client: client, message: message})
+}
+
+// reply is a message to send to a single client.
+type reply struct {
+	client  *model.Client
+	message []byte
+}
+
+// stateView is a state of the game as seen by some clients, encoded in full for each
+// protocol version and as a snapshot to encode deltas from.
+type stateView struct {
+	full     map[uint16][]byte
+	snapshot *model.Snapshot
+}
+
+// deltaKey identifies a delta of the shared view: the protocol version it is encoded
+// with and its base snapshot.
+type deltaKey struct {
+	version uint16
+	base    uint32
+}
+
+// gameStateBroadcast is a state of the game to send to the clients.
+type gameStateBroadcast struct {
+	// shared is the view of the clients without a view of their own: spectators, and
+	// every player when the fog of war is disabled.
+	shared stateView
+
+	// views holds the view of each player when the fog of war is enabled.
+	views map[*model.Client]stateView
+}
+
 // BroadcastGameState sends the current state of the game to all players.
-// This involves sending the positions of all players and coins in the game.
+// This involves sending the positions of all players and coins in the game. Clients
+// which acknowledge the snapshots they receive only get what changed since then, and
+// players only get what they can see when the fog of war is enabled.
 func (nm *NetworkManager) BroadcastGameState(state *model.GameState, tick int32, round int8) {
-	nm.broadcast <- nm.protocol.Encode(&model.ClientMessage{
-		MessageType: model.MessageGameState,
-		Body: model.MessageGameStateToEncode{
-			CurrentTick:  tick,
-			CurrentRound: round,
-			Players:      state.Players(),
-			Coins:        state.Coins().List(),
+	players := state.Players()
+	coins := state.Coins().List()
+	snapshot := nm.snapshots.Capture(players, coins, tick, round)
+	snapshot.Zone = state.Zone().Snapshot()
+
+	nm.fullVersionsMu.Lock()
+	versions := nm.fullVersions
+	nm.fullVersionsMu.Unlock()
+
+	broadcast := gameStateBroadcast{
+		shared: stateView{
+			full:     nm.encodeGameState(state, tick, round, nil, versions),
+			snapshot: snapshot,
+		},
+	}
+
+	if rules := state.Rules(); rules.FogOfWar {
+		space := state.Space()
+		broadcast.views = make(map[*model.Client]stateView, len(players))
+		for _, p := range players {
+			// Once a client acknowledges a snapshot, it only receives deltas.
+			versions := map[uint16]bool{}
+			if p.Client.Acknowledged() == 0 {
+				versions[p.Client.ProtocolVersion()] = true
+			}
+
+			visibility := model.NewVisibility(p, space, rules.VisionRadius)
+			broadcast.views[p.Client] = stateView{
+				full:     nm.encodeGameState(state, tick, round, visibility, versions),
+				snapshot: snapshot.Filter(visibility),
+			}
+		}
+	}
+
+	send(nm, nm.states, broadcast)
+}
+
+// eventsBroadcast is the events of a tick to send to the clients.
+type eventsBroadcast struct {
+	// shared holds every event, sent to the spectators and to every player when the fog
+	// of war is disabled.
+	shared []byte
+
+	// views holds the events of each player when the fog of war is enabled, nil for the
+	// players who have none.
+	views map[*model.Client][]byte
+}
+
+// BroadcastEvents sends the events of a tick to the clients which receive them. When
+// the fog of war is enabled, players only receive the events they are part of and the
+// stage changes.
+func (nm *NetworkManager) BroadcastEvents(state *model.GameState, events []model.Event) {
+	encode := func(events []model.Event) []byte {
+		return nm.protocol.Encode(&model.ClientMessage{
+			MessageType: model.MessageGameEvents,
+			Body:        model.MessageGameEventsToEncode{Events: events},
+		})
+	}
+
+	broadcast := eventsBroadcast{shared: encode(events)}
+	if state.Rules().FogOfWar {
+		players := state.Players()
+		broadcast.views = make(map[*model.Client][]byte, len(players))
+		for _, p := range players {
+			own := make([]model.Event, 0, len(events))
+			for _, e := range events {
+				if e.Type == model.EventStageChange || e.Involves(p.Nickname) {
+					own = append(own, e)
+				}
+			}
+			if len(own) != 0 {
+				broadcast.views[p.Client] = encode(own)
+			}
+		}
+	}
+
+	send(nm, nm.events, broadcast)
+}
+
+// remember adds a snapshot sent to the client, or to the clients sharing the same view
+// when client is nil, to the history.
+func (nm *NetworkManager) remember(client *model.Client, snapshot *model.Snapshot) {
+	history, ok := nm.history[client]
+	if !ok {
+		history = &[snapshotHistory]*model.Snapshot{}
+		nm.history[client] = history
+	}
+	history[snapshot.Sequence%snapshotHistory] = snapshot
+}
+
+// encodeDelta encodes the difference between the snapshot acknowledged by a client and
+// the current one, from the history of owner (nil for the shared view). Deltas of the
+// shared view are cached by version and base snapshot since most clients share the same.
+func (nm *NetworkManager) encodeDelta(owner *model.Client, current *model.Snapshot, ack uint32, version uint16, cache map[deltaKey][]byte) []byte {
+	var base *model.Snapshot
+	if current.Sequence%keyframeInterval != 0 && ack < current.Sequence {
+		if s := nm.history[owner][ack%snapshotHistory]; s != nil && s.Sequence == ack {
+			base = s
+		}
+	}
+
+	key := deltaKey{version: version}
+	if base != nil {
+		key.base = base.Sequence
+	}
+
+	if message, ok := cache[key]; ok && owner == nil {
+		return message
+	}
+
+	message := nm.protocol.Encode(&model.ClientMessage{
+		MessageType: model.MessageGameStateDelta,
+		Body: model.MessageGameStateDeltaToEncode{
+			Base:    base,
+			Current: current,
+			Version: version,
 		},
 	})
+	if owner == nil {
+		cache[key] = message
+	}
+	return message
+}
+
+// BroadcastSpectators sends an already encoded message to spectators only. It is used
+// to play back recorded games without disturbing connected players.
+func (nm *NetworkManager) BroadcastSpectators(message []byte) {
+	messages := make(map[uint16][]byte, len(model.ProtocolVersions()))
+	for _, version := range model.ProtocolVersions() {
+		messages[version] = message
+	}
+	send(nm, nm.spectate, messages)
+}
+
+// broadcastSpectatorsVersioned sends a message encoded for each protocol version to
+// spectators only.
+func (nm *NetworkManager) broadcastSpectatorsVersioned(messages map[uint16][]byte) {
+	send(nm, nm.spectate, messages)
+}
+
+// allVersions returns every protocol version supported, for the messages sent to the
+// spectators in each version.
+func allVersions() map[uint16]bool {
+	versions := make(map[uint16]bool, len(model.ProtocolVersions()))
+	for _, version := range model.ProtocolVersions() {
+		versions[version] = true
+	}
+	return versions
+}
+
+// encodeGameState encodes the current state of the game for each of the given protocol
+// versions, as seen with the given visibility (nil to encode everything).
+func (nm *NetworkManager) encodeGameState(state *model.GameState, tick int32, round int8, visibility *model.Visibility, versions map[uint16]bool) map[uint16][]byte {
+	messages := make(map[uint16][]byte, len(versions))
+	for version := range versions {
+		messages[version] = nm.protocol.Encode(&model.ClientMessage{
+			MessageType: model.MessageGameState,
+			Body: model.MessageGameStateToEncode{
+				CurrentTick:  tick,
+				CurrentRound: round,
+				Players:      state.Players(),
+				Coins:        state.Coins().List(),
+				Zone:         state.Zone(),
+				Visibility:   visibility,
+				Version:      version,
+			},
+		})
+	}
+	return messages
 }
 
 // BroadcastGameEnd sends a game end message to all players.
 func (nm *NetworkManager) BroadcastGameEnd() {
-	nm.broadcast <- nm.protocol.Encode(&model.ClientMessage{
+	send(nm, nm.broadcast, nm.protocol.Encode(&model.ClientMessage{
 		MessageType: model.MessageGameEnd,
-	})
+	}))
 }
 
 // BroadcastGameStart sends a game start message to all players.
 func (nm *NetworkManager) BroadcastGameStart(state *model.GameState) {
-	encodeMessage := func(isAdmin bool) []byte {
-		return nm.protocol.Encode(&model.ClientMessage{
-			MessageType: model.MessageMapState,
-			Body: model.MessageMapStateToEncode{
-				Map:     state.Map,
-				IsAdmin: isAdmin,
-				Storage: [100]byte{},
-			},
-		})
+	type key struct {
+		isAdmin bool
+		version uint16
 	}
-
-	msgAdmin := encodeMessage(true)
-	msg := encodeMessage(false)
+	messages := make(map[key][]byte)
 
 	for conn, client := range nm.clients {
-		var msgToSend []byte
-		if conn.IsAdmin() {
-			msgToSend = msgAdmin
-		} else {
-			msgToSend = msg
+		k := key{isAdmin: conn.IsAdmin(), version: client.ProtocolVersion()}
+		if _, ok := messages[k]; !ok {
+			messages[k] = nm.encodeMapState(state, k.isAdmin, [100]byte{}, k.version)
 		}
 
 		select {
-		case client.Out <- msgToSend:
+		case client.Out <- messages[k]:
 		default:
 			nm.unregister <- conn
 		}
 	}
 }
 
+// encodeMapState encodes the map state of the game for a client speaking the given
+// protocol version.
+func (nm *NetworkManager) encodeMapState(state *model.GameState, isAdmin bool, storage [100]byte, version uint16) []byte {
+	return nm.protocol.Encode(&model.ClientMessage{
+		MessageType: model.MessageMapState,
+		Body: model.MessageMapStateToEncode{
+			Map:     state.Map,
+			IsAdmin: isAdmin,
+			Storage: storage,
+			Seed:    state.Seed(),
+			Version: version,
+		},
+	})
+}
+
 // writer writes outgoing messages to the WebSocket network. If a message cannot be written,
 // the connection is closed. The game loop closes the connection in case of an error to prevent
 // read and write goroutines from leaking. The game loop also closes the connection if the
@@ -241,7 +664,7 @@ func (nm *NetworkManager) writer(client *model.Client) {
 			}
 
 			if err := conn.Write(msg); err != nil {
-				nm.unregister <- conn
+				send(nm, nm.unregister, conn)
 			}
 
 		case <-ticker.C:
@@ -252,11 +675,12 @@ func (nm *NetworkManager) writer(client *model.Client) {
 
 // reader reads incoming messages from the WebSocket network and sends them to the
 // game loop. The application reads incoming messages in a separate goroutine to avoid
-// blocking the game loop.
+// blocking the game loop. Spectators may only negotiate the protocol, their other
+// messages are dropped.
 func (nm *NetworkManager) reader(client *model.Client) {
 	defer func() {
 		client.GetConnection().Close(writeWait, false)
-		nm.unregister <- client.GetConnection()
+		send(nm, nm.unregister, client.GetConnection())
 	}()
 	client.GetConnection().PrepareRead(maxMessageSize, pongWait)
 
@@ -266,7 +690,50 @@ func (nm *NetworkManager) reader(client *model.Client) {
 			break
 		}
 
-		decoded := nm.protocol.Decode(msg)
-		client.In <- decoded
+		decoded := nm.protocol.DecodeWith(msg, client.ActionEncoding())
+		switch decoded.MessageType {
+		case model.MessageHello:
+			hello, ok := decoded.Body.(model.Hello)
+			if !ok {
+				continue
+			}
+
+			accepted, err := hello.Negotiate()
+			if err != nil {
+				utils.Log("network", "hello", "rejecting client: %v", err)
+				client.GetConnection().Reject(writeWait, err.Error())
+				return
+			}
+
+			client.SetProtocol(accepted)
+			nm.Reply(client, nm.protocol.Encode(&model.ClientMessage{
+				MessageType: model.MessageHello,
+				Body:        accepted,
+			}))
+			if nm.onHello != nil {
+				nm.onHello(client)
+			}
+			continue
+
+		case model.MessageStateAck:
+			if !client.Supports(model.CapabilityDeltaState) {
+				continue
+			}
+
+			if sequence, ok := decoded.Body.(uint32); ok {
+				client.Acknowledge(sequence)
+			}
+			continue
+
+		case model.MessageActionEncoding:
+			if encoding, ok := decoded.Body.(model.ActionEncoding); ok {
+				client.SetActionEncoding(encoding)
+			}
+			continue
+		}
+
+		if client.In != nil {
+			client.In <- decoded
+		}
 	}
 }
@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"testing"
+
+	iModel "github.com/capucinoxx/jdis-games-2024/internal/model"
+	"github.com/capucinoxx/jdis-games-2024/internal/protocol"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+)
+
+func TestBroadcastGameStateVersions(t *testing.T) {
+	state := model.NewGameState(&iModel.Map{})
+	rules := model.DefaultGameRules()
+	rules.FogOfWar = true
+	state.SetRules(rules)
+	state.Start()
+	defer state.Stop()
+
+	alice := state.AddPlayer("alice", 0, &headlessConnection{name: "alice"})
+	bob := state.AddPlayer("bob", 0, &headlessConnection{name: "bob"})
+	bob.Client.Acknowledge(1)
+
+	nm := NewNetworkManager(nil, protocol.NewBinaryProtocol())
+	nm.fullVersions = map[uint16]bool{model.ProtocolVersion2: true}
+
+	broadcasts := make(chan gameStateBroadcast, 1)
+	go func() { broadcasts <- <-nm.states }()
+	nm.BroadcastGameState(state, 1, 0)
+	broadcast := <-broadcasts
+
+	tests := map[string]struct {
+		view     stateView
+		expected []uint16
+	}{
+		"Shared view encoded for the versions in use": {view: broadcast.shared, expected: []uint16{model.ProtocolVersion2}},
+		"Own view encoded for the version of its client": {
+			view:     broadcast.views[alice.Client],
+			expected: []uint16{alice.Client.ProtocolVersion()},
+		},
+		"Deltas only once acknowledged": {view: broadcast.views[bob.Client]},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if len(tt.view.full) != len(tt.expected) {
+				t.Fatalf("Encoded %d versions, want %v", len(tt.view.full), tt.expected)
+			}
+			for _, version := range tt.expected {
+				if tt.view.full[version] == nil {
+					t.Errorf("Version %d not encoded", version)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package manager
+
+// ReplayManager plays recorded games back to spectators. A replay only contains the
+// seed of the game and the inputs of the players, the game is therefore simulated again
+// from the start and its states are broadcast at the original tickrate, as if the game
+// was being played live.
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/replay"
+	"github.com/capucinoxx/jdis-games-2024/pkg/utils"
+)
+
+// ErrReplayInProgress is returned when a replay is requested while another one is playing.
+var ErrReplayInProgress = errors.New("a replay is already playing")
+
+// ReplayManager plays recorded games back to spectators.
+type ReplayManager struct {
+	nm  *NetworkManager
+	rm  RoundManager
+	m   model.Map
+	dir string
+
+	mu      sync.Mutex
+	playing bool
+}
+
+// NewReplayManager creates a new ReplayManager reading replays from dir. The round manager
+// and the map must not be shared with the live game since the replay simulates its own.
+func NewReplayManager(nm *NetworkManager, rm RoundManager, m model.Map, dir string) *ReplayManager {
+	return &ReplayManager{
+		nm:  nm,
+		rm:  rm,
+		m:   m,
+		dir: dir,
+	}
+}
+
+// List returns the names of the available replays.
+func (rp *ReplayManager) List() ([]string, error) {
+	entries, err := os.ReadDir(rp.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), replay.Extension) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// Play starts playing back the replay with the given name to spectators.
+func (rp *ReplayManager) Play(name string) error {
+	rep, err := replay.Open(filepath.Join(rp.dir, filepath.Base(name)))
+	if err != nil {
+		return err
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.playing {
+		return ErrReplayInProgress
+	}
+	rp.playing = true
+
+	go func() {
+		rp.play(rep)
+
+		rp.mu.Lock()
+		rp.playing = false
+		rp.mu.Unlock()
+	}()
+
+	return nil
+}
+
+// play simulates the replay and broadcasts its states to spectators in real time.
+func (rp *ReplayManager) play(rep *replay.Replay) {
+	interval, _ := tickInterval(rep.Rules)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	utils.Log("game", "replay", "playing replay with seed %d (%d ticks)", rep.Seed, len(rep.Frames))
+
+	started := false
+	Simulate(rep, rp.rm, rp.m, func(state *model.GameState, broadcast bool) {
+		// The map is generated again from the seed when the simulated game starts.
+		if !started {
+			started = true
+			maps := make(map[uint16][]byte, len(model.ProtocolVersions()))
+			for _, version := range model.ProtocolVersions() {
+				maps[version] = rp.nm.encodeMapState(state, true, [100]byte{}, version)
+			}
+			rp.nm.broadcastSpectatorsVersioned(maps)
+		}
+
+		<-ticker.C
+		if broadcast {
+			rp.nm.broadcastSpectatorsVersioned(rp.nm.encodeGameState(state, int32(rp.rm.CurrentTick()), rp.rm.CurrentRound(), nil, allVersions()))
+		}
+	})
+
+	rp.nm.BroadcastSpectators(rp.nm.protocol.Encode(&model.ClientMessage{
+		MessageType: model.MessageGameEnd,
+	}))
+}
+
+// Simulate re-runs a recorded game headlessly. onTick is called after every tick with the
+// simulated state and whether the live game broadcast its state to the clients on that tick.
+func Simulate(rep *replay.Replay, rm RoundManager, m model.Map, onTick func(state *model.GameState, broadcast bool)) {
+	inputs := make(map[string][]model.ClientMessage)
+	gm := newHeadlessGameManager(rm, m, func(p *model.Player) []model.ClientMessage {
+		return inputs[p.Nickname]
+	})
+	state := gm.state
+
+	for _, p := range rep.Players {
+		player := state.AddPlayer(p.Nickname, p.Color, &headlessConnection{name: p.Nickname})
+		player.Team = p.Team
+		player.SetCurrentWeapon(p.Weapon)
+		player.Controls = p.Controls
+	}
+
+	state.SetSeed(rep.Seed)
+	state.SetRules(rep.Rules)
+	state.Start()
+	rm.Restart()
+
+	_, timestep := tickInterval(state.Rules())
+
+	count := 0
+	for _, frame := range rep.Frames {
+		for _, join := range frame.Joins {
+			player := state.AddPlayer(join.Nickname, join.Color, &headlessConnection{name: join.Nickname})
+			player.Team = join.Team
+			player.SetPosition(join.Position)
+		}
+
+		for _, command := range frame.Commands {
+			simulateCommand(state, command)
+		}
+
+		clear(inputs)
+		for _, action := range frame.Actions {
+			inputs[action.Nickname] = append(inputs[action.Nickname], model.ClientMessage{
+				MessageType: model.MessagePlayerAction,
+				Body:        model.PlayerAction{Controls: action.Controls},
+			})
+		}
+
+		if gm.update(timestep) {
+			break
+		}
+
+		onTick(state, count == 10)
+		if count == 10 {
+			count = 0
+		}
+
+		count++
+		if rm.HasEnded() {
+			break
+		}
+	}
+
+	state.Stop()
+}
+
+// simulateCommand applies a recorded command to the simulated state, including the
+// freezes and the connection changes which the live game applied as they happened.
+func simulateCommand(state *model.GameState, command replay.Command) {
+	switch command.Kind {
+	case replay.CommandFreeze, replay.CommandUnfreeze:
+		state.SetFreeze(command.Kind == replay.CommandFreeze)
+
+	case replay.CommandDisconnect:
+		if player := state.Player(command.Nickname); player != nil {
+			player.Client.Disconnect()
+		}
+
+	case replay.CommandReconnect:
+		if player := state.Player(command.Nickname); player != nil {
+			state.AddPlayer(player.Nickname, player.Color, &headlessConnection{name: player.Nickname})
+		}
+
+	default:
+		applyCommand(state, command)
+	}
+}
@@ -0,0 +1,256 @@
+package manager
+
+// RoomManager hosts several independent games in a single server. Each room has its own
+// game manager, network manager, map, round manager, players and scoring mode, while the
+// network transport, the users and the leaderboard are shared by every room.
+//
+// Clients pick the room to join when connecting (/echo?room=...), the default room being
+// used when none is given. Rooms can be created and destroyed while the server runs.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/network"
+)
+
+var (
+	// ErrUnknownRoom is returned when a room does not exist.
+	ErrUnknownRoom = errors.New("unknown room")
+
+	// ErrRoomExists is returned when creating a room whose name is already used.
+	ErrRoomExists = errors.New("room already exists")
+
+	// ErrDefaultRoom is returned when destroying the default room.
+	ErrDefaultRoom = errors.New("the default room cannot be destroyed")
+)
+
+// roomName restricts room names to what can be used in a URL without escaping.
+var roomName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// RoomConfig describes a room to create.
+type RoomConfig struct {
+	Name string `json:"name"`
+
+	// Ranked rooms add the scores of their games to the leaderboard.
+	Ranked bool `json:"ranked"`
+}
+
+// RoomInfo is a summary of a room.
+type RoomInfo struct {
+	Name       string `json:"name"`
+	Ranked     bool   `json:"ranked"`
+	Players    int    `json:"players"`
+	InProgress bool   `json:"in_progress"`
+}
+
+// Room is a game hosted by the RoomManager.
+type Room struct {
+	config RoomConfig
+	nm     *NetworkManager
+	gm     *GameManager
+	sm     *ScoreManager
+	rp     *ReplayManager
+}
+
+// Name returns the name of the room.
+func (r *Room) Name() string {
+	return r.config.Name
+}
+
+// GameManager returns the game manager of the room.
+func (r *Room) GameManager() *GameManager {
+	return r.gm
+}
+
+// ScoreManager returns the score manager of the room.
+func (r *Room) ScoreManager() *ScoreManager {
+	return r.sm
+}
+
+// ReplayManager returns the replay manager of the room.
+func (r *Room) ReplayManager() *ReplayManager {
+	return r.rp
+}
+
+// ConnectedPlayers returns the number of players connected to the room, the spectators
+// left out.
+func (r *Room) ConnectedPlayers() int {
+	return r.nm.Players()
+}
+
+// Info returns a summary of the room.
+func (r *Room) Info() RoomInfo {
+	return RoomInfo{
+		Name:       r.config.Name,
+		Ranked:     r.config.Ranked,
+		Players:    len(r.gm.state.Players()),
+		InProgress: r.gm.InProgress(),
+	}
+}
+
+// RoomManager creates, finds and destroys rooms.
+type RoomManager struct {
+	transport   *network.Network
+	protocol    Protocol
+	am          *AuthManager
+	leaderboard LeaderboardStore
+	users       UserStore
+
+	// newRoundManager and newMap create the round manager and the map of a new room.
+	newRoundManager func() RoundManager
+	newMap          func() model.Map
+
+	replayDir string
+	rules     *model.GameRules
+
+	mu          sync.RWMutex
+	rooms       map[string]*Room
+	defaultRoom string
+}
+
+// NewRoomManager creates a RoomManager without any room. Every room receives its
+// connections from transport and stores its scores in leaderboard.
+func NewRoomManager(transport *network.Network, protocol Protocol, am *AuthManager, leaderboard LeaderboardStore, users UserStore, newRoundManager func() RoundManager, newMap func() model.Map) *RoomManager {
+	rm := &RoomManager{
+		transport:       transport,
+		protocol:        protocol,
+		am:              am,
+		leaderboard:     leaderboard,
+		users:           users,
+		newRoundManager: newRoundManager,
+		newMap:          newMap,
+		rooms:           make(map[string]*Room),
+	}
+	transport.SetRegisterFunc(rm.RegisterConnection)
+	return rm
+}
+
+// SetReplayDirectory enables the recording of the games of the rooms created afterwards.
+// Each room records its games in a subdirectory named after it.
+func (rm *RoomManager) SetReplayDirectory(dir string) {
+	rm.replayDir = dir
+}
+
+// SetRules sets the rules of the rooms created afterwards.
+func (rm *RoomManager) SetRules(rules *model.GameRules) {
+	rm.rules = rules
+}
+
+// Create creates a new room. The first room created is the default one.
+func (rm *RoomManager) Create(config RoomConfig) (*Room, error) {
+	if !roomName.MatchString(config.Name) {
+		return nil, fmt.Errorf("invalid room name %q, only 1 to 32 lowercase letters, digits, - and _ are allowed", config.Name)
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if _, ok := rm.rooms[config.Name]; ok {
+		return nil, ErrRoomExists
+	}
+
+	// The admins of a room only see and play back the games of their room.
+	replayDir := ""
+	if rm.replayDir != "" {
+		replayDir = filepath.Join(rm.replayDir, config.Name)
+		if err := os.MkdirAll(replayDir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	nm := NewNetworkManager(rm.transport, rm.protocol)
+	sm := NewScoreManager(rm.leaderboard, rm.users)
+	sm.SetRanked(config.Ranked)
+
+	gm := NewGameManager(rm.am, nm, rm.newRoundManager(), sm, rm.newMap())
+	gm.SetReplayDirectory(replayDir)
+	nm.SetUnregisterFunc(gm.RemoveConnection)
+	nm.SetHelloFunc(gm.resendMapState)
+	if rm.rules != nil {
+		gm.SetRules(rm.rules)
+	}
+
+	room := &Room{
+		config: config,
+		nm:     nm,
+		gm:     gm,
+		sm:     sm,
+		rp:     NewReplayManager(nm, rm.newRoundManager(), rm.newMap(), replayDir),
+	}
+	go nm.run()
+
+	rm.rooms[config.Name] = room
+	if rm.defaultRoom == "" {
+		rm.defaultRoom = config.Name
+	}
+	return room, nil
+}
+
+// Destroy stops the game of a room and disconnects its clients.
+func (rm *RoomManager) Destroy(name string) error {
+	rm.mu.Lock()
+	room, ok := rm.rooms[name]
+	if !ok {
+		rm.mu.Unlock()
+		return ErrUnknownRoom
+	}
+
+	if name == rm.defaultRoom {
+		rm.mu.Unlock()
+		return ErrDefaultRoom
+	}
+	delete(rm.rooms, name)
+	rm.mu.Unlock()
+
+	room.gm.Close()
+	room.nm.Stop()
+	return nil
+}
+
+// Room returns the room with the given name, or the default room when name is empty.
+func (rm *RoomManager) Room(name string) (*Room, bool) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	if name == "" {
+		name = rm.defaultRoom
+	}
+	room, ok := rm.rooms[name]
+	return room, ok
+}
+
+// List returns a summary of every room, sorted by name.
+func (rm *RoomManager) List() []RoomInfo {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	infos := make([]RoomInfo, 0, len(rm.rooms))
+	for _, room := range rm.rooms {
+		infos = append(infos, room.Info())
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// RegisterConnection registers a new connection in the room it asked for.
+func (rm *RoomManager) RegisterConnection(conn model.Connection, adminToken string, room string) error {
+	r, ok := rm.Room(room)
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownRoom, room)
+	}
+
+	return r.gm.RegisterConnection(conn, adminToken)
+}
+
+// Start starts the server. The connections are dispatched to the rooms.
+func (rm *RoomManager) Start() error {
+	rm.transport.Init()
+	return rm.transport.Run()
+}
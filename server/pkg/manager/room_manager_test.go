@@ -0,0 +1,91 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	iManager "github.com/capucinoxx/jdis-games-2024/internal/manager"
+	iModel "github.com/capucinoxx/jdis-games-2024/internal/model"
+	"github.com/capucinoxx/jdis-games-2024/internal/protocol"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/network"
+	"github.com/capucinoxx/jdis-games-2024/pkg/replay"
+)
+
+func TestRoomManager(t *testing.T) {
+	users := NewMemoryUserStore()
+	rm := NewRoomManager(network.NewNetwork("127.0.0.1", 0), protocol.NewBinaryProtocol(), NewAuthManager(users),
+		NewMemoryLeaderboardStore(), users,
+		func() RoundManager { return iManager.NewRoundManager() },
+		func() model.Map { return &iModel.Map{} })
+
+	tests := []struct {
+		name       string
+		config     RoomConfig
+		shouldFail bool
+	}{
+		{"Default room", RoomConfig{Name: "rank", Ranked: true}, false},
+		{"Second room", RoomConfig{Name: "unrank"}, false},
+		{"Duplicated room", RoomConfig{Name: "rank"}, true},
+		{"Invalid name", RoomConfig{Name: "Not a name"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := rm.Create(tt.config)
+			if tt.shouldFail != (err != nil) {
+				t.Errorf("Create(%+v) error %v, should fail %v", tt.config, err, tt.shouldFail)
+			}
+		})
+	}
+
+	if room, ok := rm.Room(""); !ok || room.Name() != "rank" {
+		t.Errorf("The first room created should be the default one")
+	}
+
+	if err := rm.RegisterConnection(&headlessConnection{}, "", "unknown"); !errors.Is(err, ErrUnknownRoom) {
+		t.Errorf("Joining an unknown room should fail, got %v", err)
+	}
+
+	if err := rm.Destroy("rank"); err != ErrDefaultRoom {
+		t.Errorf("Destroying the default room should fail, got %v", err)
+	}
+
+	if err := rm.Destroy("unrank"); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if rooms := rm.List(); len(rooms) != 1 || rooms[0] != (RoomInfo{Name: "rank", Ranked: true}) {
+		t.Errorf("Unexpected rooms %+v", rooms)
+	}
+}
+
+func TestRoomReplayDirectory(t *testing.T) {
+	users := NewMemoryUserStore()
+	rm := NewRoomManager(network.NewNetwork("127.0.0.1", 0), protocol.NewBinaryProtocol(), NewAuthManager(users),
+		NewMemoryLeaderboardStore(), users,
+		func() RoundManager { return iManager.NewRoundManager() },
+		func() model.Map { return &iModel.Map{} })
+
+	dir := t.TempDir()
+	rm.SetReplayDirectory(dir)
+
+	rank, err := rm.Create(RoomConfig{Name: "rank"})
+	if err != nil {
+		t.Fatalf("Create() error %v", err)
+	}
+	if _, err := rm.Create(RoomConfig{Name: "unrank"}); err != nil {
+		t.Fatalf("Create() error %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "unrank", "1-42"+replay.Extension), nil, 0o644); err != nil {
+		t.Fatalf("WriteFile() error %v", err)
+	}
+
+	names, err := rank.ReplayManager().List()
+	if err != nil || len(names) != 0 {
+		t.Errorf("List() = (%v, %v), the replays of another room should not be listed", names, err)
+	}
+}
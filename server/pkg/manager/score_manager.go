@@ -1,24 +1,21 @@
 package manager
 
-// ScoreManager handles the management of player scores using Redis and MongoDB services.
+// ScoreManager handles the management of player scores using a LeaderboardStore.
 // This manager provides functionality for toggling score visibility, persisting scores,
 // adding new scores, and retrieving ranked player scores.
 //
-// This manager uses the RedisService for real-time score updates and the MongoService
-// for persistent storage of historical scores. It also ensures thread safety using a mutex
-// for concurrent access to visibility status.
+// This manager uses the LeaderboardStore for real-time score updates and persistent
+// storage of historical scores, and the UserStore to retrieve the colors of the players.
+// It also ensures thread safety using a mutex for concurrent access to visibility status.
 
 import (
-	"context"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/capucinoxx/jdis-games-2024/pkg/connector"
 	"github.com/capucinoxx/jdis-games-2024/pkg/model"
 	"github.com/capucinoxx/jdis-games-2024/pkg/utils"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type PlayerEntry struct {
@@ -34,6 +31,14 @@ type PlayerScore struct {
 	Ranking int    `json:"ranking"`
 }
 
+// TeamScore represents the score of a team, the sum of the scores of its members.
+type TeamScore struct {
+	Team    string   `json:"team"`
+	Score   int      `json:"score"`
+	Members []string `json:"members"`
+	Ranking int      `json:"ranking"`
+}
+
 type Cache struct {
 	leaderboard []PlayerScore
 	histories   map[string][]PlayerEntry
@@ -67,8 +72,8 @@ func (c *Cache) Set(leaderboard []PlayerScore, histories map[string][]PlayerEntr
 
 // ScoreManager handles the management of player scores.
 type ScoreManager struct {
-	redis        *connector.RedisService
-	mongo        *connector.MongoService
+	leaderboard  LeaderboardStore
+	users        UserStore
 	currentScore map[string]int
 	mu           sync.Mutex
 	visible      bool
@@ -76,11 +81,11 @@ type ScoreManager struct {
 	persist      bool
 }
 
-// NewScoreManager creates a new ScoreManager with the specified Redis and MongoDB services.
-func NewScoreManager(redis *connector.RedisService, mongo *connector.MongoService) *ScoreManager {
+// NewScoreManager creates a new ScoreManager with the specified leaderboard and user stores.
+func NewScoreManager(leaderboard LeaderboardStore, users UserStore) *ScoreManager {
 	return &ScoreManager{
-		redis:        redis,
-		mongo:        mongo,
+		leaderboard:  leaderboard,
+		users:        users,
 		currentScore: make(map[string]int),
 		visible:      true,
 		cache:        NewCache(time.Minute),
@@ -88,6 +93,12 @@ func NewScoreManager(redis *connector.RedisService, mongo *connector.MongoServic
 	}
 }
 
+// SetRanked sets whether the scores are added to the leaderboard. By default, they are
+// when the RANK environment variable is RANKED.
+func (sm *ScoreManager) SetRanked(ranked bool) {
+	sm.persist = ranked
+}
+
 // ToggleVisibility toggles the visibility of scores and returns the new visibility status.
 func (sm *ScoreManager) ToggleVisibility() bool {
 	sm.mu.Lock()
@@ -105,69 +116,48 @@ func (sm *ScoreManager) IsVisible() bool {
 	return sm.visible
 }
 
-// Persist saves the current scores to MongoDB. It retrieves the ranked scores from Redis,
-// associates them with the current time, and pushes them to the MongoDB collection.
+// Persist saves the current scores in the history. It retrieves the ranked scores from the
+// leaderboard, associates them with the current time, and pushes them to the history.
 func (sm *ScoreManager) Persist() error {
 	if !sm.persist {
 		return nil
 	}
 
-	val, err := sm.redis.ZRevRangeWithScores(context.Background(), "leaderboard", 0, -1).Result()
+	scores, err := sm.leaderboard.Ranking()
 	if err != nil {
 		return err
 	}
-	now := time.Now()
 
-	var errs utils.Errors
-	for _, s := range val {
-		if err = sm.mongo.Push("scores", s.Member.(string), "scores", bson.M{"score": int32(s.Score), "time": now}); err != nil {
-			errs.Append(err)
-		}
-	}
-	return errs.Error()
+	return sm.leaderboard.PushHistory(scores, time.Now())
 }
 
-// Add increments the score of a player identified by UUID in the Redis leaderboard.
+// Add increments the score of a player identified by UUID in the leaderboard.
 func (sm *ScoreManager) Adds(players []model.PlayerScore) {
 	if !sm.persist {
 		return
 	}
 
 	go func() {
-		ctx := context.Background()
-		pipe := sm.redis.Pipeline()
-
-		for _, player := range players {
-			pipe.ZIncrBy(ctx, "leaderboard", float64(player.Score), player.Name)
-		}
-
-		_, err := pipe.Exec(ctx)
-		if err != nil {
-			utils.Log("error", "redis", "adds players scores %s", err)
+		if err := sm.leaderboard.Increment(players); err != nil {
+			utils.Log("error", "leaderboard", "adds players scores %s", err)
 		}
 	}()
 }
 
-func (sm *ScoreManager) findColors(names []string) ([]int, error) {
-	filter := bson.M{"username": bson.M{"$in": names}}
-	res, err := sm.mongo.Find("users", filter)
+func (sm *ScoreManager) findColors(names []string) (map[string]int, error) {
+	users, err := sm.users.FindByUsernames(names)
 	if err != nil {
 		return nil, err
 	}
 
-	colors := make([]int, len(res))
-	for _, v := range res {
-		for j, name := range names {
-			if name == v["username"].(string) {
-				colors[j] = int(v["color"].(int32))
-				break
-			}
-		}
+	colors := make(map[string]int, len(users))
+	for _, user := range users {
+		colors[user.Username] = user.Color
 	}
 	return colors, nil
 }
 
-// Rank retrieves the ranked player scores from the Redis leaderboard.
+// Rank retrieves the ranked player scores from the leaderboard.
 // It returns a map of player UUIDs to their respective scores and positions.
 func (sm *ScoreManager) Rank() ([]PlayerScore, map[string][]PlayerEntry, error) {
 	if !sm.persist {
@@ -180,24 +170,23 @@ func (sm *ScoreManager) Rank() ([]PlayerScore, map[string][]PlayerEntry, error)
 	sm.cache.mu.Lock()
 	defer sm.cache.mu.Unlock()
 
-	val, err := sm.redis.ZRevRangeWithScores(context.Background(), "leaderboard", 0, -1).Result()
+	scores, err := sm.leaderboard.Ranking()
 	if err != nil {
 		return nil, nil, err
 	}
 
-	leaderboard := make([]PlayerScore, len(val))
-	names := make([]string, 10)
+	leaderboard := make([]PlayerScore, len(scores))
+	names := make([]string, 0, 10)
 
-	for i, v := range val {
-		uuid := v.Member.(string)
+	for i, v := range scores {
 		leaderboard[i] = PlayerScore{
 			Ranking: i + 1,
-			Name:    uuid,
-			Score:   int(v.Score),
+			Name:    v.Name,
+			Score:   v.Score,
 		}
 
 		if i < 10 {
-			names[i] = uuid
+			names = append(names, v.Name)
 		}
 	}
 	colors, err := sm.findColors(names)
@@ -206,48 +195,72 @@ func (sm *ScoreManager) Rank() ([]PlayerScore, map[string][]PlayerEntry, error)
 	}
 
 	for i := range leaderboard {
-		leaderboard[i].Color = colors[i]
+		leaderboard[i].Color = colors[leaderboard[i].Name]
 	}
 
-	filter := bson.M{"_id": bson.M{"$in": names}}
-	res, err := sm.mongo.Find("scores", filter)
+	histories, err := sm.leaderboard.Histories(names)
 	if err != nil {
 		return nil, nil, err
 	}
+	sm.cache.Set(leaderboard, histories)
 
-	histories := make(map[string][]PlayerEntry)
-	for _, history := range res {
-		name := history["_id"].(string)
-		entries, ok := history["scores"].(primitive.A)
-		if !ok {
-			utils.Log("error", "persist", "error retrieve scores")
+	return leaderboard, histories, nil
+}
+
+// RankTeams retrieves the ranked team scores, aggregated from the player scores of the
+// leaderboard. The players without a team are left out.
+func (sm *ScoreManager) RankTeams() ([]TeamScore, error) {
+	leaderboard, _, err := sm.Rank()
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := sm.users.List()
+	if err != nil {
+		return nil, err
+	}
+
+	teams := make(map[string]string, len(users))
+	for _, user := range users {
+		teams[user.Username] = user.Team
+	}
+
+	return teamScores(leaderboard, teams), nil
+}
+
+// teamScores sums the scores of the players of each team, teams giving the team of each
+// player. Teams are ranked from the highest score to the lowest, ties by name.
+func teamScores(leaderboard []PlayerScore, teams map[string]string) []TeamScore {
+	byTeam := make(map[string]*TeamScore)
+	for _, player := range leaderboard {
+		team := teams[player.Name]
+		if team == "" {
 			continue
 		}
 
-		histories[name] = make([]PlayerEntry, 0, len(entries))
-		for _, entry := range entries {
-			doc, ok := entry.(bson.M)
-			if !ok {
-				utils.Log("error", "persist", "error casting score to bson.M")
-				continue
-			}
-
-			score, ok := doc["score"].(int32)
-			if !ok {
-				utils.Log("error", "persist", "error retrieving score as int %v", doc["score"])
-				continue
-			}
-
-			scoreTime, ok := doc["time"].(primitive.DateTime)
-			if !ok {
-				utils.Log("error", "persist", "error retrieving score time as time.Time, %v", doc["time"])
-				continue
-			}
-
-			histories[name] = append(histories[name], PlayerEntry{Score: score, Time: int64(scoreTime.Time().UnixMilli() / 10_000)})
+		score, ok := byTeam[team]
+		if !ok {
+			score = &TeamScore{Team: team, Members: []string{}}
+			byTeam[team] = score
 		}
+		score.Score += player.Score
+		score.Members = append(score.Members, player.Name)
 	}
-	sm.cache.Set(leaderboard, histories)
 
-	return leaderboard, histories, nil
+	ranking := make([]TeamScore, 0, len(byTeam))
+	for _, score := range byTeam {
+		ranking = append(ranking, *score)
+	}
+
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Score != ranking[j].Score {
+			return ranking[i].Score > ranking[j].Score
+		}
+		return ranking[i].Team < ranking[j].Team
+	})
+
+	for i := range ranking {
+		ranking[i].Ranking = i + 1
+	}
+	return ranking
 }
@@ -0,0 +1,126 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+)
+
+func TestScoreManagerRank(t *testing.T) {
+	t.Setenv("RANK", "RANKED")
+
+	users := NewMemoryUserStore()
+	users.Insert(TokenInfo{Username: "alice", Token: "a", Color: 12})
+	users.Insert(TokenInfo{Username: "bob", Token: "b", Color: 34})
+
+	leaderboard := NewMemoryLeaderboardStore()
+	sm := NewScoreManager(leaderboard, users)
+
+	leaderboard.Increment([]model.PlayerScore{{Name: "alice", Score: 10}, {Name: "bob", Score: 30}})
+	leaderboard.Increment([]model.PlayerScore{{Name: "alice", Score: 5}})
+	if err := sm.Persist(); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	ranking, histories, err := sm.Rank()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	expected := []PlayerScore{
+		{Name: "bob", Score: 30, Color: 34, Ranking: 1},
+		{Name: "alice", Score: 15, Color: 12, Ranking: 2},
+	}
+
+	if len(ranking) != len(expected) {
+		t.Fatalf("Expected %d players in ranking, got %d", len(expected), len(ranking))
+	}
+
+	for i := range expected {
+		if ranking[i] != expected[i] {
+			t.Errorf("Ranking[%d] = %+v, want %+v", i, ranking[i], expected[i])
+		}
+	}
+
+	if len(histories["alice"]) != 1 || histories["alice"][0].Score != 15 {
+		t.Errorf("Expected alice history to contain her persisted score, got %+v", histories["alice"])
+	}
+}
+
+func TestScoreManagerRankTeams(t *testing.T) {
+	t.Setenv("RANK", "RANKED")
+
+	users := NewMemoryUserStore()
+	users.Insert(TokenInfo{Username: "alice", Token: "a", Team: "red"})
+	users.Insert(TokenInfo{Username: "bob", Token: "b", Team: "blue"})
+	users.Insert(TokenInfo{Username: "carol", Token: "c", Team: "red"})
+	users.Insert(TokenInfo{Username: "dave", Token: "d"})
+
+	leaderboard := NewMemoryLeaderboardStore()
+	sm := NewScoreManager(leaderboard, users)
+
+	leaderboard.Increment([]model.PlayerScore{
+		{Name: "alice", Score: 10}, {Name: "bob", Score: 30}, {Name: "carol", Score: 25}, {Name: "dave", Score: 50},
+	})
+
+	ranking, err := sm.RankTeams()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	expected := []TeamScore{
+		{Team: "red", Score: 35, Members: []string{"carol", "alice"}, Ranking: 1},
+		{Team: "blue", Score: 30, Members: []string{"bob"}, Ranking: 2},
+	}
+
+	if !reflect.DeepEqual(ranking, expected) {
+		t.Errorf("RankTeams() = %+v, want %+v", ranking, expected)
+	}
+}
+
+// countingUserStore counts the lookups of the users.
+type countingUserStore struct {
+	UserStore
+	lookups int
+}
+
+func (s *countingUserStore) FindByUsername(username string) (*TokenInfo, error) {
+	s.lookups++
+	return s.UserStore.FindByUsername(username)
+}
+
+func (s *countingUserStore) FindByUsernames(usernames []string) ([]TokenInfo, error) {
+	s.lookups++
+	return s.UserStore.FindByUsernames(usernames)
+}
+
+func TestScoreManagerRankColorsLookup(t *testing.T) {
+	t.Setenv("RANK", "RANKED")
+
+	users := &countingUserStore{UserStore: NewMemoryUserStore()}
+	leaderboard := NewMemoryLeaderboardStore()
+	scores := []model.PlayerScore{}
+	for i, name := range []string{"alice", "bob", "carol", "dave"} {
+		users.Insert(TokenInfo{Username: name, Token: name, Color: i})
+		scores = append(scores, model.PlayerScore{Name: name, Score: i})
+	}
+	leaderboard.Increment(scores)
+
+	sm := NewScoreManager(leaderboard, users)
+	ranking, _, err := sm.Rank()
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if users.lookups != 1 {
+		t.Errorf("Expected the colors to be found in a single lookup, got %d", users.lookups)
+	}
+
+	// Each player scored its color.
+	for _, score := range ranking {
+		if score.Color != score.Score {
+			t.Errorf("Color of %s = %d, want %d", score.Name, score.Color, score.Score)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package manager
+
+// Simulator runs games without any network, database or real time constraint. The
+// players are in-process bots which are asked for their controls at every tick. This
+// allows strategies and game constants to be tuned by simulating thousands of ticks
+// in a few seconds.
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+)
+
+// Bot is an in-process player driven by the Simulator.
+type Bot interface {
+	// Controls returns the controls of the bot for the current tick. The bot can
+	// inspect the game state but must not modify it.
+	Controls(self *model.Player, state *model.GameState) model.Controls
+}
+
+// Simulator runs a game headlessly with in-process bots.
+type Simulator struct {
+	gm   *GameManager
+	bots map[string]Bot
+}
+
+// NewSimulator creates a new Simulator. The game is generated from the given seed, so
+// two simulations with the same seed and the same bots produce the same game.
+func NewSimulator(rm RoundManager, m model.Map, seed int64) *Simulator {
+	s := &Simulator{bots: make(map[string]Bot)}
+	s.gm = newHeadlessGameManager(rm, m, s.inputs)
+	s.gm.state.SetSeed(seed)
+	return s
+}
+
+// AddBot adds a bot to the game. Bots must be added before the simulation runs.
+func (s *Simulator) AddBot(name string, color int, bot Bot) {
+	s.bots[name] = bot
+	s.gm.state.AddPlayer(name, color, &headlessConnection{name: name})
+}
+
+// SetRules sets the rules of the simulated game.
+func (s *Simulator) SetRules(rules *model.GameRules) {
+	s.gm.state.SetRules(rules)
+}
+
+// State returns the simulated game state.
+func (s *Simulator) State() *model.GameState {
+	return s.gm.state
+}
+
+// Run starts the game and simulates at most the given number of ticks. It returns the
+// number of ticks simulated, which is lower when the game ends before.
+func (s *Simulator) Run(ticks int) int {
+	s.gm.state.Start()
+	s.gm.rm.Restart()
+	defer s.gm.state.Stop()
+
+	_, timestep := tickInterval(s.gm.state.Rules())
+	for i := 1; i <= ticks; i++ {
+		if s.gm.update(timestep) || s.gm.rm.HasEnded() {
+			return i
+		}
+	}
+	return ticks
+}
+
+// Scores returns the score of every bot, from the highest to the lowest.
+func (s *Simulator) Scores() []model.PlayerScore {
+	players := s.gm.state.Players()
+	scores := make([]model.PlayerScore, 0, len(players))
+	for _, p := range players {
+		scores = append(scores, model.PlayerScore{Name: p.Nickname, Score: p.Score()})
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+	return scores
+}
+
+// inputs asks the bot controlling the player for its controls.
+func (s *Simulator) inputs(p *model.Player) []model.ClientMessage {
+	bot, ok := s.bots[p.Nickname]
+	if !ok {
+		return nil
+	}
+
+	return []model.ClientMessage{{
+		MessageType: model.MessagePlayerAction,
+		Body:        model.PlayerAction{Controls: bot.Controls(p, s.gm.state)},
+	}}
+}
+
+// newHeadlessGameManager creates a game manager simulating a game without any network,
+// the messages of the players being provided by inputs.
+func newHeadlessGameManager(rm RoundManager, m model.Map, inputs func(*model.Player) []model.ClientMessage) *GameManager {
+	state := model.NewGameState(m)
+	rm.SetState(state)
+
+	return &GameManager{
+		state:  state,
+		rm:     rm,
+		inputs: inputs,
+	}
+}
+
+// headlessConnection is the connection of a simulated player. It identifies the player
+// but never sends nor receives anything.
+type headlessConnection struct {
+	name string
+}
+
+func (c *headlessConnection) Identifier() string               { return c.name }
+func (c *headlessConnection) Close(time.Duration, bool)        {}
+func (c *headlessConnection) Reject(time.Duration, string)     {}
+func (c *headlessConnection) PrepareRead(int64, time.Duration) {}
+func (c *headlessConnection) Read() ([]byte, error)            { return nil, errors.New("headless connection") }
+func (c *headlessConnection) PrepareWrite(time.Duration)       {}
+func (c *headlessConnection) Write([]byte) error               { return nil }
+func (c *headlessConnection) Ping(time.Duration)               {}
+func (c *headlessConnection) IsAdmin() bool                    { return false }
+func (c *headlessConnection) SetAdmin(bool)                    {}
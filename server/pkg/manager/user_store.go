@@ -0,0 +1,181 @@
+package manager
+
+// UserStore abstracts the storage of the registered users. Two implementations are
+// provided: one backed by MongoDB, used in production, and one kept in memory, which
+// allows the server to run without any database (local development, tests).
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/connector"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UserStore stores the registered users.
+type UserStore interface {
+	// FindByUsername returns the user with the given username, or nil if there is none.
+	FindByUsername(username string) (*TokenInfo, error)
+
+	// FindByUsernames returns the users with the given usernames, in a single lookup.
+	// The unknown usernames are left out.
+	FindByUsernames(usernames []string) ([]TokenInfo, error)
+
+	// FindByToken returns the user with the given token, or nil if there is none.
+	FindByToken(token string) (*TokenInfo, error)
+
+	// Insert adds a new user.
+	Insert(user TokenInfo) error
+
+	// List returns every registered user.
+	List() ([]TokenInfo, error)
+}
+
+// MongoUserStore stores the users in a MongoDB collection.
+type MongoUserStore struct {
+	service    *connector.MongoService
+	collection string
+}
+
+// NewMongoUserStore creates a new MongoUserStore with the specified MongoDB service.
+func NewMongoUserStore(db *connector.MongoService) *MongoUserStore {
+	return &MongoUserStore{
+		service:    db,
+		collection: "users",
+	}
+}
+
+func (s *MongoUserStore) FindByUsername(username string) (*TokenInfo, error) {
+	return s.findOne(bson.M{"username": username})
+}
+
+func (s *MongoUserStore) FindByUsernames(usernames []string) ([]TokenInfo, error) {
+	return s.find(bson.M{"username": bson.M{"$in": usernames}})
+}
+
+func (s *MongoUserStore) FindByToken(token string) (*TokenInfo, error) {
+	return s.findOne(bson.M{"token": token})
+}
+
+func (s *MongoUserStore) findOne(filter bson.M) (*TokenInfo, error) {
+	v, err := s.service.FindOne(s.collection, filter)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var result TokenInfo
+	if err = v.Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (s *MongoUserStore) Insert(user TokenInfo) error {
+	_, err := s.service.Insert(s.collection, bson.M{
+		"username": user.Username,
+		"token":    user.Token,
+		"color":    user.Color,
+		"team":     user.Team,
+		"is_admin": user.IsAdmin,
+	})
+	return err
+}
+
+func (s *MongoUserStore) List() ([]TokenInfo, error) {
+	return s.find(bson.M{})
+}
+
+func (s *MongoUserStore) find(filter bson.M) ([]TokenInfo, error) {
+	v, err := s.service.Find(s.collection, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]TokenInfo, 0, len(v))
+	for _, e := range v {
+		bytes, err := bson.Marshal(e)
+		if err != nil {
+			continue
+		}
+
+		var res TokenInfo
+		if err = bson.Unmarshal(bytes, &res); err != nil {
+			continue
+		}
+
+		result = append(result, res)
+	}
+
+	return result, nil
+}
+
+// MemoryUserStore stores the users in memory. Users are lost when the server stops.
+type MemoryUserStore struct {
+	users []TokenInfo
+	mu    sync.RWMutex
+}
+
+// NewMemoryUserStore creates a new empty MemoryUserStore.
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: []TokenInfo{}}
+}
+
+func (s *MemoryUserStore) FindByUsername(username string) (*TokenInfo, error) {
+	return s.find(func(u TokenInfo) bool { return u.Username == username }), nil
+}
+
+func (s *MemoryUserStore) FindByUsernames(usernames []string) ([]TokenInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []TokenInfo{}
+	for _, u := range s.users {
+		if slices.Contains(usernames, u.Username) {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (s *MemoryUserStore) FindByToken(token string) (*TokenInfo, error) {
+	return s.find(func(u TokenInfo) bool { return u.Token == token }), nil
+}
+
+func (s *MemoryUserStore) find(match func(TokenInfo) bool) *TokenInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if match(u) {
+			return &u
+		}
+	}
+	return nil
+}
+
+func (s *MemoryUserStore) Insert(user TokenInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Username == user.Username {
+			return errors.New("user already exist")
+		}
+	}
+
+	s.users = append(s.users, user)
+	return nil
+}
+
+func (s *MemoryUserStore) List() ([]TokenInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]TokenInfo{}, s.users...), nil
+}
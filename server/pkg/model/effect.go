@@ -0,0 +1,159 @@
+package model
+
+import (
+	"math"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
+)
+
+// PlayerEffect is the effect of a power-up on the player who collects it.
+type PlayerEffect uint8
+
+const (
+	// EffectNone is the absence of effect, the pickup being a coin.
+	EffectNone PlayerEffect = iota
+
+	// EffectSpeed multiplies the speed of the player by the speed boost of the rules.
+	EffectSpeed
+
+	// EffectShield protects the player from any damage.
+	EffectShield
+
+	// EffectHealth restores the health pack of the rules to the player at once.
+	EffectHealth
+
+	// EffectRapidFire multiplies the rate of fire of the player by the rapid fire of the
+	// rules.
+	EffectRapidFire
+)
+
+// powerUps holds the effects of the power-ups spawned, in the order of their ids.
+var powerUps = []PlayerEffect{EffectSpeed, EffectShield, EffectHealth, EffectRapidFire}
+
+// String returns the name of the effect in the logs.
+func (e PlayerEffect) String() string {
+	switch e {
+	case EffectSpeed:
+		return "speed boost"
+	case EffectShield:
+		return "shield"
+	case EffectHealth:
+		return "health pack"
+	case EffectRapidFire:
+		return "rapid fire"
+	}
+	return "none"
+}
+
+// EffectState is the state of an effect active on a player sent to the clients.
+type EffectState struct {
+	Effect PlayerEffect
+
+	// Remaining is the number of ticks before the effect wears off.
+	Remaining uint16
+}
+
+func (e EffectState) Encode(w codec.Writer) (err error) {
+	if err = w.WriteUint8(uint8(e.Effect)); err != nil {
+		return
+	}
+
+	return w.WriteUint16(e.Remaining)
+}
+
+func (e *EffectState) Decode(r codec.Reader) (err error) {
+	var effect uint8
+	if effect, err = r.ReadUint8(); err != nil {
+		return
+	}
+	e.Effect = PlayerEffect(effect)
+
+	e.Remaining, err = r.ReadUint16()
+	return
+}
+
+// encodeEffects writes the number of effects then each effect.
+func encodeEffects(w codec.Writer, effects []EffectState) (err error) {
+	if err = w.WriteUint8(uint8(len(effects))); err != nil {
+		return
+	}
+
+	for _, e := range effects {
+		if err = e.Encode(w); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// decodeEffects reads the effects written by encodeEffects.
+func decodeEffects(r codec.Reader) (effects []EffectState, err error) {
+	var size uint8
+	if size, err = r.ReadUint8(); err != nil {
+		return
+	}
+
+	effects = make([]EffectState, size)
+	for i := range effects {
+		if err = effects[i].Decode(r); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// applyEffect gives the effect of a power-up to the player. Timed effects last the
+// power-up duration of the rules, collecting the same effect again restarts it.
+func (p *Player) applyEffect(effect PlayerEffect) {
+	if effect == EffectHealth {
+		p.health = min(p.rules.PlayerHealth, p.health+p.rules.HealthPack)
+		return
+	}
+
+	p.effects[effect] = p.rules.PowerUpDuration
+}
+
+// HasEffect returns true if the effect is active on the player.
+func (p *Player) HasEffect(effect PlayerEffect) bool {
+	return p.effects[effect] > 0
+}
+
+// updateEffects lets dt elapse, removing the effects which wore off.
+func (p *Player) updateEffects(dt float64) {
+	for effect, remaining := range p.effects {
+		if remaining -= dt; remaining > triggerEpsilon {
+			p.effects[effect] = remaining
+		} else {
+			delete(p.effects, effect)
+		}
+	}
+}
+
+// effectStates returns the state of the effects active on the player, in the order of
+// their ids.
+func (p *Player) effectStates() []EffectState {
+	states := []EffectState{}
+	for _, effect := range powerUps {
+		if remaining, ok := p.effects[effect]; ok {
+			ticks := math.Ceil(remaining*float64(p.rules.Tickrate) - triggerEpsilon)
+			states = append(states, EffectState{Effect: effect, Remaining: uint16(math.Min(ticks, math.MaxUint16))})
+		}
+	}
+	return states
+}
+
+// speed returns the distance traveled by the player per second.
+func (p *Player) speed() float64 {
+	if p.HasEffect(EffectSpeed) {
+		return p.rules.PlayerSpeed * p.rules.SpeedBoost
+	}
+	return p.rules.PlayerSpeed
+}
+
+// fireRate returns the factor applied to the rate of fire of the weapons of the player.
+func (p *Player) fireRate() float64 {
+	if p.HasEffect(EffectRapidFire) {
+		return p.rules.RapidFire
+	}
+	return 1
+}
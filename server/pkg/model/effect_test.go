@@ -0,0 +1,143 @@
+package model
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPowerUpEffects(t *testing.T) {
+	tests := map[string]struct {
+		effect PlayerEffect
+		health int
+		check  func(t *testing.T, p, enemy *Player)
+	}{
+		"Speed boost": {
+			effect: EffectSpeed,
+			check: func(t *testing.T, p, _ *Player) {
+				p.Controls.Dest = &Point{X: 90, Y: 10}
+				p.moveToDestination(1)
+				if want := 10 + p.rules.PlayerSpeed*p.rules.SpeedBoost; p.Position.X != want {
+					t.Errorf("Moved to %v, want %v", p.Position.X, want)
+				}
+			},
+		},
+		"Shield": {
+			effect: EffectShield,
+			check: func(t *testing.T, p, enemy *Player) {
+				enemy.hit(p, PlayerWeaponCanon, 30, 10)
+				if p.health != p.rules.PlayerHealth || enemy.score != 0 {
+					t.Errorf("Health and score of the attacker = (%d, %d), want (%d, 0)", p.health, enemy.score, p.rules.PlayerHealth)
+				}
+
+				// The damage which does not come from a player goes through the shield.
+				p.TakeDmg(30)
+				if want := p.rules.PlayerHealth - 30; p.health != want {
+					t.Errorf("Health = %d, want %d", p.health, want)
+				}
+			},
+		},
+		"Health pack": {
+			effect: EffectHealth,
+			health: 50,
+			check: func(t *testing.T, p, _ *Player) {
+				if want := 50 + p.rules.HealthPack; p.health != want {
+					t.Errorf("Health = %d, want %d", p.health, want)
+				}
+				if len(p.effectStates()) != 0 {
+					t.Errorf("Expected the health pack to be applied at once, got %v", p.effectStates())
+				}
+
+				p.applyEffect(EffectHealth)
+				if p.health != p.rules.PlayerHealth {
+					t.Errorf("Health = %d, want at most %d", p.health, p.rules.PlayerHealth)
+				}
+			},
+		},
+		"Rapid fire": {
+			effect: EffectRapidFire,
+			check: func(t *testing.T, p, _ *Player) {
+				p.cannon().Fire(&Controls{Shoot: &Point{X: 90, Y: 10}})
+				if want := p.rules.CannonCooldown / p.rules.RapidFire; p.cannon().Trigger().wait != want {
+					t.Errorf("Cooldown = %v, want %v", p.cannon().Trigger().wait, want)
+				}
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := NewPlayer("player", 0, &Point{X: 10, Y: 10}, nil)
+			enemy := NewPlayer("enemy", 0, &Point{X: 20, Y: 10}, nil)
+			if tt.health != 0 {
+				p.health = tt.health
+			}
+
+			powerUp := NewPowerUp(&Point{X: 10, Y: 10}, tt.effect, p.rules)
+			if !powerUp.IsCollidingWithPlayer(p) || powerUp.IsAlive() {
+				t.Fatalf("Expected the power-up to be collected")
+			}
+			if p.score != 0 {
+				t.Errorf("Expected a power-up not to score, got %d", p.score)
+			}
+
+			tt.check(t, p, enemy)
+		})
+	}
+}
+
+func TestEffectDuration(t *testing.T) {
+	p := NewPlayer("player", 0, &Point{X: 10, Y: 10}, nil)
+	p.applyEffect(EffectShield)
+	p.applyEffect(EffectSpeed)
+
+	want := []EffectState{
+		{Effect: EffectSpeed, Remaining: uint16(p.rules.PowerUpDuration * float64(p.rules.Tickrate))},
+		{Effect: EffectShield, Remaining: uint16(p.rules.PowerUpDuration * float64(p.rules.Tickrate))},
+	}
+	if got := p.effectStates(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Effects = %v, want %v", got, want)
+	}
+
+	p.updateEffects(p.rules.PowerUpDuration - 1)
+	p.applyEffect(EffectSpeed)
+	p.updateEffects(1)
+
+	if p.HasEffect(EffectShield) || !p.HasEffect(EffectSpeed) {
+		t.Errorf("Expected the shield to wear off and the speed boost to be restarted, got %v", p.effectStates())
+	}
+}
+
+func TestScorersSpawnPowerUps(t *testing.T) {
+	tests := map[string]struct {
+		chance   float64
+		powerUps int
+	}{
+		"Power-ups disabled": {chance: 0},
+		"Power-ups only":     {chance: 1, powerUps: 5},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rules := DefaultGameRules()
+			rules.PowerUpChance = tt.chance
+
+			scorers := NewScorers(rand.New(rand.NewSource(0)), rules)
+			for i := 0; i < 5; i++ {
+				coin := NewCoin(&Point{X: 5, Y: 5}, rules)
+				coin.Remove()
+				scorers.Add(coin)
+			}
+			scorers.Update()
+
+			powerUps := 0
+			for _, s := range scorers.List() {
+				if s.IsPowerUp() {
+					powerUps++
+				}
+			}
+			if len(scorers.List()) != 5 || powerUps != tt.powerUps {
+				t.Errorf("Got %d pickups with %d power-ups, want 5 with %d", len(scorers.List()), powerUps, tt.powerUps)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package model
+
+import (
+	"sync"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
+)
+
+// EventType is the kind of an Event.
+type EventType uint8
+
+const (
+	// EventHit is a player (Player) hitting another one (Target) with a weapon, Value
+	// being the damage.
+	EventHit EventType = iota + 1
+
+	// EventKill is a player (Player) eliminating another one (Target) with a weapon.
+	EventKill
+
+	// EventDeath is a player (Player) eliminated at Pos, by Target or by the server when
+	// Target is empty.
+	EventDeath
+
+	// EventRespawn is a player (Player) respawning at Pos.
+	EventRespawn
+
+	// EventCoinCollected is a player (Player) collecting a coin of Value at Pos.
+	EventCoinCollected
+
+	// EventWeaponSwitch is a player (Player) switching to Weapon.
+	EventWeaponSwitch
+
+	// EventStageChange is the game entering the stage Value (0 for the discovery stage,
+	// 1 for the point rush stage).
+	EventStageChange
+
+	// EventPowerUpCollected is a player (Player) collecting a power-up of the effect
+	// Value (see PlayerEffect) at Pos.
+	EventPowerUpCollected
+)
+
+// Event is something which happened during a tick of the game. Only the fields
+// documented for its type are set.
+type Event struct {
+	Type   EventType
+	Tick   int32
+	Player string
+	Target string
+	Weapon PlayerWeapon
+	Value  int32
+	Pos    Point
+}
+
+func (e *Event) Encode(w codec.Writer) (err error) {
+	if err = w.WriteUint8(uint8(e.Type)); err != nil {
+		return
+	}
+
+	if err = w.WriteInt32(e.Tick); err != nil {
+		return
+	}
+
+	if err = w.WriteString(e.Player); err != nil {
+		return
+	}
+
+	if err = w.WriteString(e.Target); err != nil {
+		return
+	}
+
+	if err = w.WriteUint8(uint8(e.Weapon)); err != nil {
+		return
+	}
+
+	if err = w.WriteInt32(e.Value); err != nil {
+		return
+	}
+
+	return e.Pos.Encode(w)
+}
+
+// eventMinSize is the size of the smallest event: its type, tick, empty player and
+// target, weapon, value and position.
+const eventMinSize = 1 + 4 + 1 + 1 + 1 + 4 + 16
+
+func (e *Event) Decode(r codec.Reader) (err error) {
+	var kind uint8
+	if kind, err = r.ReadUint8(); err != nil {
+		return
+	}
+	e.Type = EventType(kind)
+
+	if e.Tick, err = r.ReadInt32(); err != nil {
+		return
+	}
+
+	if e.Player, err = r.ReadString(); err != nil {
+		return
+	}
+
+	if e.Target, err = r.ReadString(); err != nil {
+		return
+	}
+
+	var weapon uint8
+	if weapon, err = r.ReadUint8(); err != nil {
+		return
+	}
+	e.Weapon = PlayerWeapon(weapon)
+
+	if e.Value, err = r.ReadInt32(); err != nil {
+		return
+	}
+
+	return e.Pos.Decode(r)
+}
+
+// Involves returns true if the player named nickname is the player or the target of the
+// event.
+func (e *Event) Involves(nickname string) bool {
+	return e.Player == nickname || e.Target == nickname
+}
+
+// MessageGameEventsToEncode is the body of a MessageGameEvents.
+type MessageGameEventsToEncode struct {
+	Events []Event
+}
+
+func (m *MessageGameEventsToEncode) Encode(w codec.Writer) (err error) {
+	if err = w.WriteInt32(int32(len(m.Events))); err != nil {
+		return
+	}
+
+	for i := range m.Events {
+		if err = m.Events[i].Encode(w); err != nil {
+			return
+		}
+	}
+	return
+}
+
+type MessageGameEventsToDecode struct {
+	Events []Event
+}
+
+func (m *MessageGameEventsToDecode) Decode(r codec.Reader) (err error) {
+	var size int
+	if size, err = ReadCount(r, eventMinSize); err != nil {
+		return
+	}
+
+	m.Events = make([]Event, 0, size)
+	for i := 0; i < size; i++ {
+		var e Event
+		if err = e.Decode(r); err != nil {
+			return
+		}
+		m.Events = append(m.Events, e)
+	}
+	return
+}
+
+// EventBus collects the events emitted by the game during a tick and publishes them to
+// its subscribers at the end of the tick. Events may be emitted from any goroutine, the
+// subscribers are called from the goroutine publishing the events. A nil bus drops the
+// events emitted.
+type EventBus struct {
+	pending     []Event
+	subscribers map[int]func(Event)
+	nextID      int
+	mu          sync.Mutex
+}
+
+// NewEventBus creates a new EventBus without subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[int]func(Event))}
+}
+
+// Emit queues an event until the end of the tick.
+func (b *EventBus) Emit(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, e)
+}
+
+// Subscribe calls fn with every event published until the returned function is called.
+func (b *EventBus) Subscribe(fn func(Event)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// Publish stamps the events queued since the previous call with the tick, sends them to
+// the subscribers in the order emitted and returns them.
+func (b *EventBus) Publish(tick int32) []Event {
+	b.mu.Lock()
+	events := b.pending
+	b.pending = nil
+
+	subscribers := make([]func(Event), 0, len(b.subscribers))
+	for id := 0; id < b.nextID; id++ {
+		if fn, ok := b.subscribers[id]; ok {
+			subscribers = append(subscribers, fn)
+		}
+	}
+	b.mu.Unlock()
+
+	for i := range events {
+		events[i].Tick = tick
+		for _, fn := range subscribers {
+			fn(events[i])
+		}
+	}
+	return events
+}
+
+// Clear drops the events queued.
+func (b *EventBus) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = nil
+}
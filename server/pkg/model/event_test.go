@@ -0,0 +1,86 @@
+package model
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+
+	var first, second []EventType
+	bus.Subscribe(func(e Event) { first = append(first, e.Type) })
+	unsubscribe := bus.Subscribe(func(e Event) { second = append(second, e.Type) })
+
+	bus.Emit(Event{Type: EventHit})
+	bus.Emit(Event{Type: EventKill})
+	events := bus.Publish(7)
+
+	if len(events) != 2 || events[0].Tick != 7 || events[1].Tick != 7 {
+		t.Errorf("Publish() = %+v, expected 2 events at tick 7", events)
+	}
+
+	unsubscribe()
+	bus.Emit(Event{Type: EventRespawn})
+	bus.Publish(8)
+
+	if !reflect.DeepEqual(first, []EventType{EventHit, EventKill, EventRespawn}) {
+		t.Errorf("First subscriber received %v", first)
+	}
+
+	if !reflect.DeepEqual(second, []EventType{EventHit, EventKill}) {
+		t.Errorf("Unsubscribed subscriber received %v", second)
+	}
+
+	if events := bus.Publish(9); len(events) != 0 {
+		t.Errorf("Expected the published events to be dropped, got %+v", events)
+	}
+
+	var none *EventBus
+	none.Emit(Event{Type: EventHit})
+}
+
+func TestGameEventsEncoding(t *testing.T) {
+	events := []Event{
+		{Type: EventKill, Tick: 3, Player: "alice", Target: "bob", Weapon: PlayerWeaponBlade},
+		{Type: EventCoinCollected, Tick: 4, Player: "bob", Value: 10, Pos: Point{X: 1.5, Y: 2}},
+	}
+
+	w := codec.NewByteWriter(binary.LittleEndian)
+	message := MessageGameEventsToEncode{Events: events}
+	if err := message.Encode(w); err != nil {
+		t.Fatalf("Encode() error %v", err)
+	}
+
+	var decoded MessageGameEventsToDecode
+	if err := decoded.Decode(codec.NewByteReader(w.Bytes(), binary.LittleEndian)); err != nil {
+		t.Fatalf("Decode() error %v", err)
+	}
+
+	if !reflect.DeepEqual(decoded.Events, events) {
+		t.Errorf("Decode() = %+v, want %+v", decoded.Events, events)
+	}
+}
+
+func TestHitEvents(t *testing.T) {
+	bus := NewEventBus()
+	owner := NewPlayer("owner", 0, &Point{X: 0, Y: 0}, nil)
+	enemy := NewPlayer("enemy", 0, &Point{X: 1, Y: 0}, nil)
+	owner.events, enemy.events = bus, bus
+	enemy.health = 1
+
+	rotation := 0.0
+	NewBlade(owner).swing(spaceOf(enemy), rotation)
+
+	want := []Event{
+		{Type: EventHit, Player: "owner", Target: "enemy", Weapon: PlayerWeaponBlade, Value: int32(owner.rules.BladeDmg)},
+		{Type: EventKill, Player: "owner", Target: "enemy", Weapon: PlayerWeaponBlade},
+		{Type: EventDeath, Player: "enemy", Target: "owner", Pos: Point{X: 1, Y: 0}},
+	}
+	if events := bus.Publish(0); !reflect.DeepEqual(events, want) {
+		t.Errorf("Events = %+v, want %+v", events, want)
+	}
+}
@@ -1,8 +1,13 @@
 package model
 
 import (
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/capucinoxx/jdis-games-2024/consts"
+	"github.com/capucinoxx/jdis-games-2024/pkg/utils"
 )
 
 type GameState struct {
@@ -14,24 +19,91 @@ type GameState struct {
 	cachedScore map[string]int
 	coins       *Scorers
 
+	// zone is the safe zone closing during the point rush stage, nil when there is none.
+	zone *Zone
+
 	Map        Map
+	space      *SpatialGrid
+	events     *EventBus
 	spawns     []*Point
 	spawnIndex int
 	mu         *sync.RWMutex
+
+	// seed is the seed of the game in progress. Every random decision of the
+	// simulation (maze, spawns, coins) is drawn from rng, which is seeded with it.
+	seed     int64
+	nextSeed *int64
+	rng      *rand.Rand
+
+	// rules are the rules of the game in progress. They are shared with the players
+	// and the scorers and only change when a game starts, from nextRules.
+	rules     *GameRules
+	nextRules *GameRules
 }
 
 func NewGameState(m Map) *GameState {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	rules := DefaultGameRules()
+
 	return &GameState{
 		spawns:      []*Point{},
 		spawnIndex:  0,
 		inProgress:  false,
 		freeze:      false,
-		coins:       NewScorers(),
+		coins:       NewScorers(rng, rules),
 		players:     make(map[string]*Player),
 		cachedScore: make(map[string]int),
 		Map:         m,
+		space:       NewSpatialGrid(0, consts.CellWidth),
+		events:      NewEventBus(),
 		mu:          &sync.RWMutex{},
+		rng:         rng,
+		rules:       rules,
+	}
+}
+
+// SetSeed sets the seed used by the next game started. Once that game starts, the
+// following ones go back to a time based seed.
+func (gs *GameState) SetSeed(seed int64) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.nextSeed = &seed
+}
+
+// Seed returns the seed of the current game.
+func (gs *GameState) Seed() int64 {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.seed
+}
+
+// SetRules sets the rules of the next game started. The game in progress keeps its
+// rules until it ends.
+func (gs *GameState) SetRules(rules *GameRules) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	next := *rules
+	gs.nextRules = &next
+}
+
+// Rules returns the rules of the current game.
+func (gs *GameState) Rules() *GameRules {
+	return gs.rules
+}
+
+// NextRules returns the rules of the next game started.
+func (gs *GameState) NextRules() GameRules {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	if gs.nextRules != nil {
+		return *gs.nextRules
 	}
+	return *gs.rules
+}
+
+// Rand returns the random source of the current game.
+func (gs *GameState) Rand() *rand.Rand {
+	return gs.rng
 }
 
 func (gs *GameState) GetSpawnPoint() *Point {
@@ -78,6 +150,12 @@ func (gd *GameState) Players() []*Player {
 			players = append(players, p)
 		}
 	}
+
+	// Map iteration order is random, players are sorted so that they are always
+	// updated in the same order for a given seed.
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Nickname < players[j].Nickname
+	})
 	return players
 }
 
@@ -106,6 +184,65 @@ func (gs *GameState) Coins() *Scorers {
 	return gs.coins
 }
 
+// SetZone sets the safe zone of the game, nil to remove it.
+func (gs *GameState) SetZone(zone *Zone) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.zone = zone
+}
+
+// Zone returns the safe zone of the game, nil when there is none.
+func (gs *GameState) Zone() *Zone {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.zone
+}
+
+// UpdateZone closes the safe zone by a tick and damages the players outside it. It must
+// be called once per tick, after the players are updated.
+func (gs *GameState) UpdateZone(players []*Player) {
+	zone := gs.Zone()
+	if zone == nil {
+		return
+	}
+
+	zone.tick()
+	for _, p := range players {
+		if p.IsAlive() && !zone.Contains(*p.Position) {
+			p.TakeDmg(gs.rules.ZoneDmg)
+		}
+	}
+}
+
+// Path returns the waypoints of a shortest path between two positions of the map. It
+// returns false when there is no such path or when the map cannot find paths.
+func (gs *GameState) Path(from, to Point) ([]Point, bool) {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	pathfinder, ok := gs.Map.(Pathfinder)
+	if !ok {
+		return nil, false
+	}
+	return pathfinder.Path(from, to)
+}
+
+// Events returns the bus of the events of the game.
+func (gs *GameState) Events() *EventBus {
+	return gs.events
+}
+
+// Space returns the broad phase of the collision detection of the current game.
+func (gs *GameState) Space() *SpatialGrid {
+	return gs.space
+}
+
+// UpdateSpace indexes the players and the coins at their current position. It must be
+// called at the start of every tick, before the players are updated by dt.
+func (gs *GameState) UpdateSpace(players []*Player, dt float64) {
+	gs.space.Rebuild(players, gs.Coins().List(), gs.rules.PlayerSpeed*gs.rules.SpeedBoost*dt)
+}
+
 func (gs *GameState) AddPlayer(username string, color int, conn Connection) *Player {
 	var player *Player
 	var ok bool
@@ -115,6 +252,8 @@ func (gs *GameState) AddPlayer(username string, color int, conn Connection) *Pla
 	gs.mu.Unlock()
 
 	if ok {
+		// A new connection numbers its actions from the start again.
+		player.SetLastSequence(0, 0)
 		player.Client.SetConnection(conn)
 		player.Client.In = make(chan ClientMessage, 10)
 		player.Client.Out = make(chan []byte, 10)
@@ -125,13 +264,40 @@ func (gs *GameState) AddPlayer(username string, color int, conn Connection) *Pla
 	if gs.InProgess() {
 		spawn = gs.GetSpawnPoint()
 	}
-	player = NewPlayer(username, color, spawn, conn)
+	player = newPlayer(username, color, spawn, conn, gs.rules)
+	player.events = gs.events
 	gs.mu.Lock()
 	gs.players[username] = player
 	gs.mu.Unlock()
 	return player
 }
 
+// Player returns the player named username, nil if there is none.
+func (gs *GameState) Player(username string) *Player {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.players[username]
+}
+
+// TeamFull returns true if the team has no room left for the player named username,
+// who may already be in it. Players without a team are never limited.
+func (gs *GameState) TeamFull(team, username string) bool {
+	if team == "" || gs.rules.TeamSize == 0 {
+		return false
+	}
+
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	count := 0
+	for _, p := range gs.players {
+		if p.Team == team && p.Nickname != username {
+			count++
+		}
+	}
+	return count >= gs.rules.TeamSize
+}
+
 func (gs *GameState) RemovePlayer(p *Player) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
@@ -145,9 +311,30 @@ func (gs *GameState) Start() {
 		gs.mu.Unlock()
 		return
 	}
+
+	seed := time.Now().UnixNano()
+	if gs.nextSeed != nil {
+		seed = *gs.nextSeed
+		gs.nextSeed = nil
+	}
+	gs.seed = seed
+	gs.rng.Seed(seed)
+
+	// The rules are updated in place since the players and the scorers hold them.
+	if gs.nextRules != nil {
+		*gs.rules = *gs.nextRules
+		gs.nextRules = nil
+	}
+
+	// The map is set up under the lock since Path may read it from another goroutine.
+	gs.Map.Setup(gs.rng, gs.rules)
 	gs.mu.Unlock()
 
-	gs.Map.Setup()
+	utils.Log("game", "start", "starting game with seed %d", seed)
+	gs.events.Clear()
+
+	gs.space = NewSpatialGrid(float64(gs.Map.Size()*consts.CellWidth), consts.CellWidth)
+	gs.space.SetWalls(gs.Map.Colliders())
 	gs.SetSpawns(gs.Map.Spawns(0))
 
 	gs.startTime = time.Now()
@@ -161,14 +348,17 @@ func (gs *GameState) Start() {
 	gs.inProgress = true
 }
 
+// Reset respawns the players with new weapons, replaces the coins and removes the safe
+// zone.
 func (gs *GameState) Reset(scorers []*Scorer) {
 	players := gs.Players()
 	for _, p := range players {
 		p.Respawn(gs)
-		p.cannon.Projectiles = []*Projectile{}
+		p.arm()
 	}
 
 	gs.coins.Set(scorers)
+	gs.SetZone(nil)
 }
 
 func (gs *GameState) Stop() {
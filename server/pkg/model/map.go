@@ -1,6 +1,8 @@
 package model
 
 import (
+	"math/rand"
+
 	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
 )
 
@@ -67,7 +69,9 @@ func (c *Collider) polygon() Polygon {
 
 // Map represents a game map, containing information about collisions and spawn points.
 type Map interface {
-	Setup()
+	// Setup generates a new layout following the rules of the game. Every random
+	// decision must be drawn from r so that the same seed always produces the same map.
+	Setup(r *rand.Rand, rules *GameRules)
 	Centroid() Point
 	Colliders() []*Collider
 	Spawns(int) []*Point
@@ -75,3 +79,11 @@ type Map interface {
 	DiscreteMap() [][]uint8
 	Encode(codec.Writer, bool) error
 }
+
+// Pathfinder is implemented by the maps able to find paths between two positions, for
+// the bots and to validate their paths.
+type Pathfinder interface {
+	// Path returns the waypoints of a shortest path from `from` to `to`, ending with
+	// `to`. It returns false when there is no such path.
+	Path(from, to Point) ([]Point, bool)
+}
@@ -0,0 +1,194 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/capucinoxx/jdis-games-2024/consts"
+)
+
+// MapLayout describes a hand-authored map. When the rules hold a layout, it is used
+// instead of generating a map.
+//
+// The walls are segments on the lines between the cells, in cell units: a wall from
+// (0, 2) to (3, 2) closes the south side of the first three cells of the second row.
+// The border of the map is always closed. The spawns and the big coin are positions
+// in the game units, like the positions of the players.
+type MapLayout struct {
+	// Size defines the width of the map in cells.
+	Size int `json:"size"`
+
+	// Walls are the walls inside the map.
+	Walls []WallSegment `json:"walls"`
+
+	// Spawns are the spawn points of the discovery stage (0) and of the point rush
+	// stage (1).
+	Spawns [2][]Point `json:"spawns"`
+
+	// Coin is the position of the big coin of the point rush stage.
+	Coin Point `json:"coin"`
+}
+
+// WallSegment is a horizontal or vertical wall between two corners of cells.
+type WallSegment struct {
+	From Point `json:"from"`
+	To   Point `json:"to"`
+}
+
+// Units splits the wall in walls one cell long, going right or down.
+func (w WallSegment) Units() []WallSegment {
+	from, to := w.From, w.To
+	if from.X > to.X || from.Y > to.Y {
+		from, to = to, from
+	}
+
+	length := int(to.X - from.X + to.Y - from.Y)
+	dx, dy := float64(0), float64(0)
+	if to.X > from.X {
+		dx = 1
+	} else {
+		dy = 1
+	}
+
+	units := make([]WallSegment, 0, length)
+	for i := 0; i < length; i++ {
+		start := Point{X: from.X + dx*float64(i), Y: from.Y + dy*float64(i)}
+		units = append(units, WallSegment{From: start, To: Point{X: start.X + dx, Y: start.Y + dy}})
+	}
+	return units
+}
+
+// LoadMapLayout reads a layout from the JSON file at path.
+func LoadMapLayout(path string) (*MapLayout, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodeMapLayout(file)
+}
+
+// DecodeMapLayout reads a JSON layout from r and validates it.
+func DecodeMapLayout(r io.Reader) (*MapLayout, error) {
+	var layout MapLayout
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&layout); err != nil {
+		return nil, err
+	}
+
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	return &layout, nil
+}
+
+// Validate returns an error if the layout cannot be played: walls off the lines between
+// the cells, positions outside of the map or spawns from which the big coin cannot be
+// reached.
+func (l *MapLayout) Validate() error {
+	if l.Size < 2 || l.Size > maxMapWidth {
+		return fmt.Errorf("size must be between 2 and %d", maxMapWidth)
+	}
+
+	var errs []error
+	for i, wall := range l.Walls {
+		if !l.onGrid(wall.From) || !l.onGrid(wall.To) || (wall.From.X != wall.To.X) == (wall.From.Y != wall.To.Y) {
+			errs = append(errs, fmt.Errorf("wall %d must be a horizontal or vertical segment between two corners of cells", i))
+		}
+	}
+
+	if !l.inside(l.Coin) {
+		errs = append(errs, errors.New("coin is outside of the map"))
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	reachable := l.reachableCells(l.cellOf(l.Coin))
+	for phase, spawns := range l.Spawns {
+		if len(spawns) == 0 {
+			errs = append(errs, fmt.Errorf("spawns of stage %d are missing", phase))
+		}
+
+		for i, spawn := range spawns {
+			if !l.inside(spawn) {
+				errs = append(errs, fmt.Errorf("spawn %d of stage %d is outside of the map", i, phase))
+			} else if !reachable[l.cellOf(spawn)] {
+				errs = append(errs, fmt.Errorf("spawn %d of stage %d cannot reach the coin", i, phase))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// onGrid returns true if p is a corner of a cell.
+func (l *MapLayout) onGrid(p Point) bool {
+	return p.X == math.Trunc(p.X) && p.Y == math.Trunc(p.Y) &&
+		p.X >= 0 && p.X <= float64(l.Size) && p.Y >= 0 && p.Y <= float64(l.Size)
+}
+
+// inside returns true if the position p is strictly inside the map.
+func (l *MapLayout) inside(p Point) bool {
+	width := float64(l.Size * consts.CellWidth)
+	return p.X > 0 && p.X < width && p.Y > 0 && p.Y < width
+}
+
+// cellOf returns the corner of the cell holding the position p.
+func (l *MapLayout) cellOf(p Point) Point {
+	return Point{X: math.Floor(p.X / consts.CellWidth), Y: math.Floor(p.Y / consts.CellWidth)}
+}
+
+// reachableCells returns the cells reachable from start without crossing a wall.
+func (l *MapLayout) reachableCells(start Point) map[Point]bool {
+	walls := make(map[WallSegment]bool)
+	for _, wall := range l.Walls {
+		for _, unit := range wall.Units() {
+			walls[unit] = true
+		}
+	}
+
+	// The wall crossed when leaving a cell in each direction.
+	crossed := []struct {
+		dx, dy   float64
+		from, to Point
+	}{
+		{1, 0, Point{X: 1, Y: 0}, Point{X: 1, Y: 1}},
+		{-1, 0, Point{X: 0, Y: 0}, Point{X: 0, Y: 1}},
+		{0, 1, Point{X: 0, Y: 1}, Point{X: 1, Y: 1}},
+		{0, -1, Point{X: 0, Y: 0}, Point{X: 1, Y: 0}},
+	}
+
+	reachable := map[Point]bool{start: true}
+	queue := []Point{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, c := range crossed {
+			next := Point{X: current.X + c.dx, Y: current.Y + c.dy}
+			wall := WallSegment{
+				From: Point{X: current.X + c.from.X, Y: current.Y + c.from.Y},
+				To:   Point{X: current.X + c.to.X, Y: current.Y + c.to.Y},
+			}
+
+			if next.X < 0 || next.Y < 0 || next.X >= float64(l.Size) || next.Y >= float64(l.Size) ||
+				reachable[next] || walls[wall] {
+				continue
+			}
+
+			reachable[next] = true
+			queue = append(queue, next)
+		}
+	}
+
+	return reachable
+}
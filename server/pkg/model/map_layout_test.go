@@ -0,0 +1,47 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMapLayoutValidate(t *testing.T) {
+	// A 2x2 map split in two by a vertical wall, the coin being on the left side.
+	base := `{"size": 2, "walls": [{"from": {"x": 1, "y": 0}, "to": {"x": 1, "y": 2}}], "coin": {"x": 5, "y": 5}, `
+
+	tests := map[string]struct {
+		layout     string
+		shouldFail bool
+	}{
+		"Valid layout":       {layout: base + `"spawns": [[{"x": 5, "y": 15}], [{"x": 2, "y": 2}]]}`},
+		"Unreachable spawn":  {layout: base + `"spawns": [[{"x": 15, "y": 15}], [{"x": 2, "y": 2}]]}`, shouldFail: true},
+		"Spawn outside":      {layout: base + `"spawns": [[{"x": 5, "y": 25}], [{"x": 2, "y": 2}]]}`, shouldFail: true},
+		"Missing spawns":     {layout: base + `"spawns": [[{"x": 5, "y": 15}], []]}`, shouldFail: true},
+		"Wall off the lines": {layout: `{"size": 2, "walls": [{"from": {"x": 0.5, "y": 0}, "to": {"x": 0.5, "y": 2}}], "coin": {"x": 5, "y": 5}, "spawns": [[{"x": 5, "y": 15}], [{"x": 2, "y": 2}]]}`, shouldFail: true},
+		"Diagonal wall":      {layout: `{"size": 2, "walls": [{"from": {"x": 0, "y": 0}, "to": {"x": 1, "y": 1}}], "coin": {"x": 5, "y": 5}, "spawns": [[{"x": 5, "y": 15}], [{"x": 2, "y": 2}]]}`, shouldFail: true},
+		"Unknown field":      {layout: `{"size": 2, "unknown": true}`, shouldFail: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeMapLayout(strings.NewReader(tt.layout))
+			if tt.shouldFail != (err != nil) {
+				t.Errorf("DecodeMapLayout() error %v, should fail %v", err, tt.shouldFail)
+			}
+		})
+	}
+}
+
+func TestWallSegmentUnits(t *testing.T) {
+	units := WallSegment{From: Point{X: 3, Y: 1}, To: Point{X: 0, Y: 1}}.Units()
+	if len(units) != 3 {
+		t.Fatalf("Expected 3 units, got %d", len(units))
+	}
+
+	for i, unit := range units {
+		want := WallSegment{From: Point{X: float64(i), Y: 1}, To: Point{X: float64(i + 1), Y: 1}}
+		if unit != want {
+			t.Errorf("Unit %d = %+v, want %+v", i, unit, want)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package model
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
 )
 
@@ -57,8 +60,78 @@ const (
 	// +-------------------+------------------------------------------+
 	// | End for each player                                          |
 	// +--------------------------------------------------------------+
+	// | 4 bytes (int32)   | number of coins, power-ups excluded      |
+	// | For each coin: 16 bytes unique id, position, 4 bytes value   |
+	// +-------------------+------------------------------------------+
+	// | Since ProtocolVersion2, for each player in the same order do |
+	// +-------------------+------------------------------------------+
+	// | 4 bytes (uint32)  | sequence of the last action processed    |
+	// | 4 bytes (int32)   | tick at which that action was applied    |
+	// | n bytes (string)  | player team, empty if none (until \0)    |
+	// | 1 byte  (uint8)   | number of weapon sections                |
+	// +-------------------+------------------------------------------+
+	// | For each weapon other than the cannon and the blade do       |
+	// +-------------------+------------------------------------------+
+	// | 1 byte  (uint8)   | weapon                                   |
+	// | 4 bytes (int32)   | number of projectiles, then for each the |
+	// |                   | same fields as the player projectiles    |
+	// +-------------------+------------------------------------------+
+	// | 1 byte  (uint8)   | number of weapons with a trigger         |
+	// +-------------------+------------------------------------------+
+	// | For each weapon with a trigger do                            |
+	// +-------------------+------------------------------------------+
+	// | 1 byte  (uint8)   | weapon                                   |
+	// | 2 bytes (uint16)  | ticks before the weapon can fire         |
+	// | 2 bytes (int16)   | rounds left, -1 without a magazine       |
+	// +-------------------+------------------------------------------+
+	// | 1 byte  (uint8)   | number of active effects                 |
+	// +-------------------+------------------------------------------+
+	// | For each active effect do                                    |
+	// +-------------------+------------------------------------------+
+	// | 1 byte  (uint8)   | effect (PlayerEffect)                    |
+	// | 2 bytes (uint16)  | ticks before the effect wears off        |
+	// +-------------------+------------------------------------------+
+	// | End for each player, since ProtocolVersion2                  |
+	// +-------------------+------------------------------------------+
+	// | 4 bytes (int32)   | number of power-ups                      |
+	// +-------------------+------------------------------------------+
+	// | For each power-up do                                         |
+	// +-------------------+------------------------------------------+
+	// | 16 bytes (string) | power-up unique id                       |
+	// | 8 bytes (float64) | power-up x axis position                 |
+	// | 8 bytes (float64) | power-up y axis position                 |
+	// | 1 byte  (uint8)   | effect (PlayerEffect)                    |
+	// +-------------------+------------------------------------------+
+	// | 1 byte  (bool)    | if there is a safe zone (0/1)            |
+	// | 16 bytes (2 f64)  | if there is a safe zone: zone center     |
+	// | 8 bytes (float64) | if there is a safe zone: zone radius     |
+	// +-------------------+------------------------------------------+
 	MessageGameState = 1
 
+	// MessagePlayerAction is sent by a player to change its controls. The body is a JSON
+	// object with the controls and an optional sequence number "seq", which the client
+	// increases with every action and which is echoed in MessageGameState once processed.
+	//
+	// Every action received since the previous tick is applied at the next tick. When
+	// several actions are received, they are merged in the order received: a control set
+	// by an action overrides the same control of the previous ones. The merged action
+	// then replaces the controls of the player, as a single action would. An action whose
+	// sequence is not greater than the last one processed is discarded.
+	//
+	// A client which sent MessageActionEncoding with ActionEncodingBinary sends the body
+	// in binary instead:
+	// Encode: PlayerAction.Encode()
+	// Decode: PlayerAction.Decode()
+	//
+	// +-------------------+------------------------------------------+
+	// | 4 bytes (uint32)  | sequence (0 if not numbered)             |
+	// | 1 byte  (uint8)   | mask of the controls present (Action*)   |
+	// | 16 bytes (2 f64)  | if dest: destination                     |
+	// | 16 bytes (2 f64)  | if shoot: shooting target                |
+	// | 1 byte  (uint8)   | if switch: weapon                        |
+	// | 8 bytes (float64) | if rotate_blade: blade target angle      |
+	// | n bytes (string)  | if save: saved data (read until \0)      |
+	// +-------------------+------------------------------------------+
 	MessagePlayerAction = 3
 
 	// +-------------------+------------------------------------------+
@@ -88,16 +161,274 @@ const (
 	// +-------------------+------------------------------------------+
 	// | End for each collider in wall                                |
 	// +-------------------+------------------------------------------+
+	// | 100 bytes         | player storage                           |
+	// | 8 bytes (int64)   | game seed, since ProtocolVersion2        |
+	// +-------------------+------------------------------------------+
 	MessageMapState = 4
 
 	MessageGameEnd = 5
+
+	// MessageGameStateDelta is sent instead of MessageGameState to the clients which
+	// acknowledge the snapshots they receive (see MessageStateAck). It contains only the
+	// entities which changed since the base snapshot, the last one acknowledged by the
+	// client, or every entity when the base sequence is 0 (keyframe). Entities are
+	// identified by ids which stay the same as long as the entity exists, and positions
+	// are quantized (x = value / PositionScale). Before ProtocolVersion2, only the
+	// projectiles of the cannons and the coins are sent, and the fields marked (v2) are
+	// left out.
+	// Encode: MessageGameStateDeltaToEncode.Encode()
+	// Decode: MessageGameStateDeltaToDecode.Decode() then Apply(base)
+	//
+	// +-------------------+------------------------------------------+
+	// |          Binary Representation                               |
+	// +-------------------+------------------------------------------+
+	// | Field             | Description                              |
+	// +-------------------+------------------------------------------+
+	// | 4 bytes (uint32)  | snapshot sequence                        |
+	// | 4 bytes (uint32)  | base snapshot sequence (0 = keyframe)    |
+	// | 4 bytes (int32)   | current tick                             |
+	// | 1 byte  (int8)    | current round (0/1)                      |
+	// | 2 bytes (uint16)  | number of changed players                |
+	// +-------------------+------------------------------------------+
+	// | For each changed player do                                   |
+	// +-------------------+------------------------------------------+
+	// | 2 bytes (uint16)  | player id                                |
+	// | 1 byte  (uint8)   | mask of the fields present (PlayerDelta*)|
+	// |                   | 2 bytes (uint16) since ProtocolVersion2  |
+	// | n bytes (string)  | if info: player name (read until \0)     |
+	// | 4 bytes (int32)   | if info: player color                    |
+	// | n bytes (string)  | if info (v2): player team (until \0)     |
+	// | 4 bytes (int32)   | if health: player health                 |
+	// | 4 bytes (int32)   | if score: player score                   |
+	// | 4 bytes (2 int16) | if position: player position             |
+	// | 1 byte  (bool)    | if destination: player has destination   |
+	// | 4 bytes (2 int16) | if destination and has one: destination  |
+	// | 1 byte  (uint8)   | if weapon: player current weapon         |
+	// | 8 bytes (4 int16) | if blade: blade start and end positions  |
+	// | 2 bytes (uint16)  | if blade: blade rotation (1/65536 turn)  |
+	// | 4 bytes (uint32)  | if sequence (v2): last action processed  |
+	// | 4 bytes (int32)   | if sequence (v2): tick it was applied at |
+	// | 1 byte  (uint8)   | if triggers (v2): number of triggers,    |
+	// |                   | then each as in MessageGameState         |
+	// | 1 byte  (uint8)   | if effects (v2): number of effects,      |
+	// |                   | then each as in MessageGameState         |
+	// +-------------------+------------------------------------------+
+	// | 2 bytes (uint16)  | number of removed players, then the ids  |
+	// | 2 bytes (uint16)  | number of changed projectiles            |
+	// +-------------------+------------------------------------------+
+	// | For each changed projectile do                               |
+	// +-------------------+------------------------------------------+
+	// | 2 bytes (uint16)  | projectile id                            |
+	// | 2 bytes (uint16)  | owner player id                          |
+	// | 1 byte  (uint8)   | weapon which fired the projectile (v2)   |
+	// | 4 bytes (2 int16) | projectile position                      |
+	// | 4 bytes (2 int16) | projectile destination                   |
+	// +-------------------+------------------------------------------+
+	// | 2 bytes (uint16)  | number of removed projectiles, then ids  |
+	// | 2 bytes (uint16)  | number of changed coins                  |
+	// +-------------------+------------------------------------------+
+	// | For each changed coin do                                     |
+	// +-------------------+------------------------------------------+
+	// | 2 bytes (uint16)  | coin id                                  |
+	// | 4 bytes (2 int16) | coin position                            |
+	// | 4 bytes (int32)   | coin value                               |
+	// | 1 byte  (uint8)   | effect of a power-up, 0 for a coin (v2)  |
+	// +-------------------+------------------------------------------+
+	// | 2 bytes (uint16)  | number of removed coins, then the ids    |
+	// +-------------------+------------------------------------------+
+	// | 1 byte  (bool)    | if there is a safe zone (v2)             |
+	// | 4 bytes (2 int16) | if there is a safe zone: zone center     |
+	// | 2 bytes (uint16)  | if there is a safe zone: zone radius in  |
+	// |                   | 1/PositionScale of a unit                |
+	// +-------------------+------------------------------------------+
+	MessageGameStateDelta = 6
+
+	// MessageStateAck is sent by a client to acknowledge the last snapshot it received.
+	// Once a client has sent one, it receives MessageGameStateDelta instead of
+	// MessageGameState.
+	//
+	// +-------------------+------------------------------------------+
+	// | 4 bytes (uint32)  | acknowledged snapshot sequence           |
+	// +-------------------+------------------------------------------+
+	MessageStateAck = 7
+
+	// MessageActionEncoding is sent by a client to choose the encoding of its next
+	// MessagePlayerAction. Every connection starts with ActionEncodingJSON.
+	//
+	// +-------------------+------------------------------------------+
+	// | 1 byte  (uint8)   | action encoding (0 = JSON, 1 = binary)   |
+	// +-------------------+------------------------------------------+
+	MessageActionEncoding = 8
+
+	// MessageHello is sent by a client to announce the version of the protocol it speaks
+	// and the capabilities it supports. The server answers with a MessageHello holding the
+	// version used for the connection and the capabilities it accepted, or closes the
+	// connection with the reason when the version is not supported. A client which never
+	// sends a hello is served ProtocolVersion1.
+	// Encode: Hello.Encode()
+	// Decode: Hello.Decode()
+	//
+	// +-------------------+------------------------------------------+
+	// | 2 bytes (uint16)  | protocol version                         |
+	// | 4 bytes (uint32)  | capabilities (Capability* bits)          |
+	// +-------------------+------------------------------------------+
+	MessageHello = 9
+
+	// MessageGameEvents is sent after every tick with events to the clients which
+	// announced CapabilityGameEvents in their MessageHello: hits, kills, deaths,
+	// respawns, coins and power-ups collected, weapon switches and stage changes (see
+	// EventType). When
+	// the fog of war is enabled, players only receive the events they are part of and the
+	// stage changes.
+	// Encode: MessageGameEventsToEncode.Encode()
+	// Decode: MessageGameEventsToDecode.Decode()
+	//
+	// +-------------------+------------------------------------------+
+	// | 4 bytes (int32)   | number of events                         |
+	// +-------------------+------------------------------------------+
+	// | For each event do                                            |
+	// +-------------------+------------------------------------------+
+	// | 1 byte  (uint8)   | event type (EventType)                   |
+	// | 4 bytes (int32)   | tick                                     |
+	// | n bytes (string)  | player (read until \0)                   |
+	// | n bytes (string)  | target, may be empty (read until \0)     |
+	// | 1 byte  (uint8)   | weapon                                   |
+	// | 4 bytes (int32)   | value (damage, coin value or stage)      |
+	// | 8 bytes (float64) | x axis position                          |
+	// | 8 bytes (float64) | y axis position                          |
+	// +-------------------+------------------------------------------+
+	MessageGameEvents = 10
+)
+
+// Versions of the protocol served.
+const (
+	// ProtocolVersion1 is the protocol of the 2024 starter packs.
+	ProtocolVersion1 uint16 = 1
+
+	// ProtocolVersion2 adds to MessageGameState and MessageGameStateDelta the sequence of
+	// the last action processed, the teams, the weapons other than the cannon and the
+	// blade, the cooldown and the ammunition of the weapons, the power-ups and the effects
+	// active on the players, and the safe zone of the point rush stage. It also adds the
+	// seed of the game to MessageMapState.
+	ProtocolVersion2 uint16 = 2
+
+	// MinProtocolVersion is the oldest version still served.
+	MinProtocolVersion = ProtocolVersion1
+
+	// CurrentProtocolVersion is the latest version of the protocol.
+	CurrentProtocolVersion = ProtocolVersion2
 )
 
+// ProtocolVersions returns every version of the protocol served, oldest first.
+func ProtocolVersions() []uint16 {
+	versions := make([]uint16, 0, CurrentProtocolVersion-MinProtocolVersion+1)
+	for v := MinProtocolVersion; v <= CurrentProtocolVersion; v++ {
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+// Capability is an optional feature of the protocol supported by a client.
+type Capability uint32
+
+const (
+	// CapabilityDeltaState allows the client to acknowledge snapshots with MessageStateAck
+	// and receive MessageGameStateDelta.
+	CapabilityDeltaState Capability = 1 << iota
+
+	// CapabilityBinaryActions makes the client send its actions with ActionEncodingBinary.
+	CapabilityBinaryActions
+
+	// CapabilityGameEvents makes the client receive MessageGameEvents.
+	CapabilityGameEvents
+
+	// SupportedCapabilities holds every capability the server supports.
+	SupportedCapabilities = CapabilityDeltaState | CapabilityBinaryActions | CapabilityGameEvents
+)
+
+// Hello is the body of a MessageHello.
+type Hello struct {
+	Version      uint16
+	Capabilities Capability
+}
+
+func (h *Hello) Encode(w codec.Writer) (err error) {
+	if err = w.WriteUint16(h.Version); err != nil {
+		return
+	}
+
+	if err = w.WriteUint32(uint32(h.Capabilities)); err != nil {
+		return
+	}
+
+	return
+}
+
+func (h *Hello) Decode(r codec.Reader) (err error) {
+	if h.Version, err = r.ReadUint16(); err != nil {
+		return
+	}
+
+	var capabilities uint32
+	if capabilities, err = r.ReadUint32(); err != nil {
+		return
+	}
+	h.Capabilities = Capability(capabilities)
+
+	return
+}
+
+// Negotiate returns the answer of the server to the hello of a client: the version used
+// for the connection and the capabilities accepted. It returns an error explaining why
+// the client is rejected when its version is not served.
+func (h Hello) Negotiate() (Hello, error) {
+	if h.Version < MinProtocolVersion || h.Version > CurrentProtocolVersion {
+		return Hello{}, fmt.Errorf("unsupported protocol version %d, the server supports versions %d to %d",
+			h.Version, MinProtocolVersion, CurrentProtocolVersion)
+	}
+
+	return Hello{Version: h.Version, Capabilities: h.Capabilities & SupportedCapabilities}, nil
+}
+
+// protocolVersion returns the version to encode a message with, 0 meaning the current one.
+func protocolVersion(version uint16) uint16 {
+	if version == 0 {
+		return CurrentProtocolVersion
+	}
+	return version
+}
+
+// ErrInvalidCount is returned when a message announces more entries than it holds.
+var ErrInvalidCount = errors.New("invalid number of entries")
+
+// ReadCount reads a number of entries of at least minSize bytes each. It rejects the
+// negative counts and the ones the bytes left cannot hold, before anything is allocated.
+func ReadCount(r codec.Reader, minSize int) (int, error) {
+	size, err := r.ReadInt32()
+	if err != nil {
+		return 0, err
+	}
+
+	if size < 0 || int64(size)*int64(minSize) > int64(r.Len()) {
+		return 0, ErrInvalidCount
+	}
+	return int(size), nil
+}
+
 type MessageGameStateToEncode struct {
 	CurrentTick  int32
 	CurrentRound int8
 	Players      []*Player
 	Coins        []*Scorer
+
+	// Zone is the safe zone, nil when there is none.
+	Zone *Zone
+
+	// Visibility limits the state to what a player can see, nil to send everything.
+	Visibility *Visibility
+
+	// Version is the protocol version of the recipients, 0 for the current one.
+	Version uint16
 }
 
 func (m *MessageGameStateToEncode) Encode(w codec.Writer) (err error) {
@@ -109,27 +440,94 @@ func (m *MessageGameStateToEncode) Encode(w codec.Writer) (err error) {
 		return
 	}
 
-	if err = w.WriteInt32(int32(len(m.Players))); err != nil {
+	players := make([]*Player, 0, len(m.Players))
+	for _, p := range m.Players {
+		if m.Visibility.CanSeePlayer(p) {
+			players = append(players, p)
+		}
+	}
+
+	if err = w.WriteInt32(int32(len(players))); err != nil {
 		return
 	}
 
-	for _, p := range m.Players {
-		if err = p.Encode(w); err != nil {
+	for _, p := range players {
+		if err = p.encode(w, m.Visibility); err != nil {
 			return
 		}
 	}
 
-	if err = w.WriteInt32(int32(len(m.Coins))); err != nil {
+	coins, powerUps := make([]*Scorer, 0, len(m.Coins)), []*Scorer{}
+	for _, c := range m.Coins {
+		switch {
+		case !m.Visibility.CanSee(*c.Position):
+		case c.IsPowerUp():
+			powerUps = append(powerUps, c)
+		default:
+			coins = append(coins, c)
+		}
+	}
+
+	if err = w.WriteInt32(int32(len(coins))); err != nil {
 		return
 	}
 
-	for _, c := range m.Coins {
+	for _, c := range coins {
 		if err = c.Encode(w); err != nil {
 			return
 		}
 	}
 
-	return
+	if protocolVersion(m.Version) < ProtocolVersion2 {
+		return
+	}
+
+	for _, p := range players {
+		sequence, tick := p.LastSequence()
+		if err = w.WriteUint32(sequence); err != nil {
+			return
+		}
+
+		if err = w.WriteInt32(tick); err != nil {
+			return
+		}
+
+		if err = w.WriteString(p.Team); err != nil {
+			return
+		}
+
+		if err = p.encodeWeapons(w, m.Visibility); err != nil {
+			return
+		}
+
+		if err = encodeTriggers(w, p.triggers()); err != nil {
+			return
+		}
+
+		if err = encodeEffects(w, p.effectStates()); err != nil {
+			return
+		}
+	}
+
+	if err = w.WriteInt32(int32(len(powerUps))); err != nil {
+		return
+	}
+
+	for _, c := range powerUps {
+		if _, err = w.WriteBytes(c.uuid[:]); err != nil {
+			return
+		}
+
+		if err = c.Position.Encode(w); err != nil {
+			return
+		}
+
+		if err = w.WriteUint8(uint8(c.Effect)); err != nil {
+			return
+		}
+	}
+
+	return encodeZone(w, m.Zone)
 }
 
 type MessageGameStateToDecode struct {
@@ -141,6 +539,22 @@ type MessageGameStateToDecode struct {
 		Value int32
 		Pos   Point
 	}
+
+	// PowerUps holds the power-ups, since ProtocolVersion2.
+	PowerUps []PowerUpInfo
+
+	// Zone is the safe zone, nil when there is none or before ProtocolVersion2.
+	Zone *ZoneInfo
+
+	// Version is the protocol version the message was encoded with, 0 for the current one.
+	Version uint16
+}
+
+// PowerUpInfo is a power-up of MessageGameState.
+type PowerUpInfo struct {
+	Uuid   [16]byte
+	Pos    Point
+	Effect PlayerEffect
 }
 
 func (m *MessageGameStateToDecode) Decode(r codec.Reader) (err error) {
@@ -152,13 +566,13 @@ func (m *MessageGameStateToDecode) Decode(r codec.Reader) (err error) {
 		return
 	}
 
-	var size int32
-	if size, err = r.ReadInt32(); err != nil {
+	var size int
+	if size, err = ReadCount(r, playerInfoMinSize); err != nil {
 		return
 	}
 
 	m.Players = make([]PlayerInfo, 0, size)
-	for i := int32(0); i < size; i++ {
+	for i := 0; i < size; i++ {
 		p := PlayerInfo{}
 		if err = p.Decode(r); err != nil {
 			return
@@ -166,7 +580,7 @@ func (m *MessageGameStateToDecode) Decode(r codec.Reader) (err error) {
 		m.Players = append(m.Players, p)
 	}
 
-	if size, err = r.ReadInt32(); err != nil {
+	if size, err = ReadCount(r, 16+4+16); err != nil {
 		return
 	}
 
@@ -175,7 +589,7 @@ func (m *MessageGameStateToDecode) Decode(r codec.Reader) (err error) {
 		Value int32
 		Pos   Point
 	}, 0, size)
-	for i := int32(0); i < size; i++ {
+	for i := 0; i < size; i++ {
 		c := struct {
 			Uuid  [16]byte
 			Value int32
@@ -197,6 +611,60 @@ func (m *MessageGameStateToDecode) Decode(r codec.Reader) (err error) {
 		m.Coins = append(m.Coins, c)
 	}
 
+	if protocolVersion(m.Version) < ProtocolVersion2 {
+		return
+	}
+
+	for i := range m.Players {
+		if m.Players[i].LastSequence, err = r.ReadUint32(); err != nil {
+			return
+		}
+
+		if m.Players[i].LastSequenceTick, err = r.ReadInt32(); err != nil {
+			return
+		}
+
+		if m.Players[i].Team, err = r.ReadString(); err != nil {
+			return
+		}
+
+		if err = m.Players[i].decodeWeapons(r); err != nil {
+			return
+		}
+
+		if m.Players[i].Triggers, err = decodeTriggers(r); err != nil {
+			return
+		}
+
+		if m.Players[i].Effects, err = decodeEffects(r); err != nil {
+			return
+		}
+	}
+
+	if size, err = ReadCount(r, 16+16+1); err != nil {
+		return
+	}
+
+	m.PowerUps = make([]PowerUpInfo, size)
+	for i := range m.PowerUps {
+		var id []byte
+		if id, err = r.ReadBytes(16); err != nil {
+			return
+		}
+		copy(m.PowerUps[i].Uuid[:], id)
+
+		if err = m.PowerUps[i].Pos.Decode(r); err != nil {
+			return
+		}
+
+		var effect uint8
+		if effect, err = r.ReadUint8(); err != nil {
+			return
+		}
+		m.PowerUps[i].Effect = PlayerEffect(effect)
+	}
+
+	m.Zone, err = decodeZone(r)
 	return
 }
 
@@ -204,6 +672,10 @@ type MessageMapStateToEncode struct {
 	Map     Map
 	IsAdmin bool
 	Storage [100]byte
+	Seed    int64
+
+	// Version is the protocol version of the recipient, 0 for the current one.
+	Version uint16
 }
 
 func (m *MessageMapStateToEncode) Encode(w codec.Writer) (err error) {
@@ -211,8 +683,17 @@ func (m *MessageMapStateToEncode) Encode(w codec.Writer) (err error) {
 	if err != nil {
 		return
 	}
-	_, err = w.WriteBytes(m.Storage[:])
-	return
+
+	if _, err = w.WriteBytes(m.Storage[:]); err != nil {
+		return
+	}
+
+	if protocolVersion(m.Version) < ProtocolVersion2 {
+		return
+	}
+
+	// The seed lets the teams play the same map again while debugging their bots.
+	return w.WriteInt64(m.Seed)
 }
 
 type MessageMapStateToDecode struct {
@@ -220,6 +701,12 @@ type MessageMapStateToDecode struct {
 	DiscreteGrid [][]uint8
 	Walls        []*Collider
 	Storage      [100]byte
+
+	// Seed is the seed of the game, since ProtocolVersion2.
+	Seed int64
+
+	// Version is the protocol version the message was encoded with, 0 for the current one.
+	Version uint16
 }
 
 func (m *MessageMapStateToDecode) Decode(r codec.Reader) (err error) {
@@ -228,6 +715,10 @@ func (m *MessageMapStateToDecode) Decode(r codec.Reader) (err error) {
 		return err
 	}
 
+	if m.Size < 0 {
+		return ErrInvalidCount
+	}
+
 	m.DiscreteGrid = make([][]uint8, m.Size)
 	for i := 0; i < int(m.Size); i++ {
 		m.DiscreteGrid[i] = make([]uint8, m.Size)
@@ -239,13 +730,13 @@ func (m *MessageMapStateToDecode) Decode(r codec.Reader) (err error) {
 		}
 	}
 
-	wallsLen, err := r.ReadInt32()
+	wallsLen, err := ReadCount(r, 1)
 	if err != nil {
 		return err
 	}
 
 	m.Walls = make([]*Collider, wallsLen)
-	for i := 0; i < int(wallsLen); i++ {
+	for i := 0; i < wallsLen; i++ {
 		m.Walls[i] = &Collider{}
 		if err = m.Walls[i].Decode(r); err != nil {
 			return err
@@ -258,5 +749,11 @@ func (m *MessageMapStateToDecode) Decode(r codec.Reader) (err error) {
 	}
 
 	copy(m.Storage[:], storage)
-	return nil
+
+	if protocolVersion(m.Version) < ProtocolVersion2 {
+		return nil
+	}
+
+	m.Seed, err = r.ReadInt64()
+	return err
 }
@@ -0,0 +1,213 @@
+package model
+
+import (
+	"encoding/binary"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
+)
+
+func TestHelloNegotiate(t *testing.T) {
+	tests := map[string]struct {
+		hello      Hello
+		want       Hello
+		shouldFail bool
+	}{
+		"Current version":      {hello: Hello{Version: CurrentProtocolVersion, Capabilities: CapabilityDeltaState}, want: Hello{Version: CurrentProtocolVersion, Capabilities: CapabilityDeltaState}},
+		"Oldest version":       {hello: Hello{Version: MinProtocolVersion}, want: Hello{Version: MinProtocolVersion}},
+		"Unknown capabilities": {hello: Hello{Version: CurrentProtocolVersion, Capabilities: 1 << 31}, want: Hello{Version: CurrentProtocolVersion}},
+		"Version too old":      {hello: Hello{Version: MinProtocolVersion - 1}, shouldFail: true},
+		"Version too new":      {hello: Hello{Version: CurrentProtocolVersion + 1}, shouldFail: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tt.hello.Negotiate()
+			if tt.shouldFail {
+				if err == nil {
+					t.Errorf("Negotiate() should fail for version %d", tt.hello.Version)
+				}
+				return
+			}
+
+			if err != nil || got != tt.want {
+				t.Errorf("Negotiate() = (%+v, %v), want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGameStateProtocolVersions(t *testing.T) {
+	p := NewPlayer("alice", 0, &Point{X: 5, Y: 5}, nil)
+	p.SetLastSequence(12, 34)
+	p.Team = "red"
+	p.Weapon(PlayerWeaponMine).Fire(&Controls{Shoot: &Point{}})
+
+	sizes := make(map[uint16]int)
+	for _, version := range ProtocolVersions() {
+		w := codec.NewByteWriter(binary.LittleEndian)
+		message := MessageGameStateToEncode{Players: []*Player{p}, Version: version}
+		if err := message.Encode(w); err != nil {
+			t.Fatalf("Encode() error %v", err)
+		}
+		sizes[version] = len(w.Bytes())
+
+		decoded := MessageGameStateToDecode{Version: version}
+		if err := decoded.Decode(codec.NewByteReader(w.Bytes(), binary.LittleEndian)); err != nil {
+			t.Fatalf("Decode() of version %d error %v", version, err)
+		}
+
+		want := uint32(0)
+		if version >= ProtocolVersion2 {
+			want = 12
+		}
+		if decoded.Players[0].LastSequence != want {
+			t.Errorf("Version %d: LastSequence = %d, want %d", version, decoded.Players[0].LastSequence, want)
+		}
+
+		team := ""
+		if version >= ProtocolVersion2 {
+			team = "red"
+		}
+		if decoded.Players[0].Team != team {
+			t.Errorf("Version %d: Team = %q, want %q", version, decoded.Players[0].Team, team)
+		}
+
+		mines := 0
+		for _, weapon := range decoded.Players[0].Weapons {
+			if weapon.Type == PlayerWeaponMine {
+				mines = len(weapon.Projectiles)
+			}
+		}
+		if want := map[bool]int{false: 0, true: 1}[version >= ProtocolVersion2]; mines != want {
+			t.Errorf("Version %d: %d mines decoded, want %d", version, mines, want)
+		}
+
+		if want := map[bool]int{false: 0, true: 4}[version >= ProtocolVersion2]; len(decoded.Players[0].Triggers) != want {
+			t.Errorf("Version %d: %d triggers decoded, want %d", version, len(decoded.Players[0].Triggers), want)
+		}
+	}
+
+	// The action sequence, the team, then the number of weapon sections with the shotgun,
+	// the mine layer with its mine and the grenade launcher, the number of triggers with
+	// the cannon, the shotgun, the mine layer and the grenade launcher, the number of
+	// effects, the number of power-ups and whether there is a safe zone.
+	added := 8 + len("red") + 1 + 1 + 3*(1+4) + 16 + 2*16 + 1 + 4*5 + 1 + 4 + 1
+	if sizes[ProtocolVersion2]-sizes[ProtocolVersion1] != added {
+		t.Errorf("Version 2 should add %d bytes, got sizes %v", added, sizes)
+	}
+}
+
+func TestGameStateZone(t *testing.T) {
+	zone := NewZone(Point{X: 10, Y: 20}, 30, 5, 10)
+
+	tests := map[string]struct {
+		version  uint16
+		zone     *Zone
+		expected *ZoneInfo
+	}{
+		"Zone left out before version 2": {version: ProtocolVersion1, zone: zone},
+		"No zone":                        {version: ProtocolVersion2},
+		"Zone sent since version 2": {
+			version:  ProtocolVersion2,
+			zone:     zone,
+			expected: &ZoneInfo{Center: Point{X: 10, Y: 20}, Radius: 30},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := codec.NewByteWriter(binary.LittleEndian)
+			message := MessageGameStateToEncode{Zone: tt.zone, Version: tt.version}
+			if err := message.Encode(w); err != nil {
+				t.Fatalf("Encode() error %v", err)
+			}
+
+			decoded := MessageGameStateToDecode{Version: tt.version}
+			if err := decoded.Decode(codec.NewByteReader(w.Bytes(), binary.LittleEndian)); err != nil {
+				t.Fatalf("Decode() error %v", err)
+			}
+
+			if !reflect.DeepEqual(decoded.Zone, tt.expected) {
+				t.Errorf("Zone %+v, want %+v", decoded.Zone, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGameStatePowerUps(t *testing.T) {
+	rules := DefaultGameRules()
+	coins := []*Scorer{
+		NewCoin(&Point{X: 10, Y: 10}, rules),
+		NewPowerUp(&Point{X: 20, Y: 20}, EffectShield, rules),
+	}
+
+	tests := map[string]struct {
+		version  uint16
+		powerUps []PlayerEffect
+	}{
+		"Power-ups left out before version 2": {version: ProtocolVersion1},
+		"Power-ups sent since version 2":      {version: ProtocolVersion2, powerUps: []PlayerEffect{EffectShield}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := codec.NewByteWriter(binary.LittleEndian)
+			message := MessageGameStateToEncode{Coins: coins, Version: tt.version}
+			if err := message.Encode(w); err != nil {
+				t.Fatalf("Encode() error %v", err)
+			}
+
+			decoded := MessageGameStateToDecode{Version: tt.version}
+			if err := decoded.Decode(codec.NewByteReader(w.Bytes(), binary.LittleEndian)); err != nil {
+				t.Fatalf("Decode() error %v", err)
+			}
+
+			if len(decoded.Coins) != 1 || decoded.Coins[0].Value != rules.CoinValue {
+				t.Errorf("Coins %+v, want only the coin", decoded.Coins)
+			}
+
+			powerUps := []PlayerEffect{}
+			for _, p := range decoded.PowerUps {
+				powerUps = append(powerUps, p.Effect)
+			}
+			if !slices.Equal(powerUps, tt.powerUps) {
+				t.Errorf("Power-ups %v, want %v", powerUps, tt.powerUps)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalidCounts(t *testing.T) {
+	tests := map[string]struct {
+		decoder codec.BinaryDecoder
+		data    []byte
+	}{
+		"Negative number of events": {
+			decoder: &MessageGameEventsToDecode{},
+			data:    []byte{0xff, 0xff, 0xff, 0xff},
+		},
+		"More events than the message holds": {
+			decoder: &MessageGameEventsToDecode{},
+			data:    []byte{0xff, 0xff, 0xff, 0x7f, 0},
+		},
+		"Negative number of players": {
+			decoder: &MessageGameStateToDecode{Version: ProtocolVersion1},
+			data:    []byte{0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff},
+		},
+		"Negative map size": {
+			decoder: &MessageMapStateToDecode{},
+			data:    []byte{0xff},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := tt.decoder.Decode(codec.NewByteReader(tt.data, binary.LittleEndian)); err != ErrInvalidCount {
+				t.Errorf("Decode() error %v, want %v", err, ErrInvalidCount)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"math"
 	"math/rand"
 
 	"github.com/google/uuid"
@@ -29,32 +30,71 @@ func (o *Object) IsCollidingWithPlayer(player *Player) bool {
 	return o.collider.Collisions(player.Collider().polygon())
 }
 
+// stopAt moves the object where it was after the fraction t of its movement from `from`
+// to `to`.
+func (o *Object) stopAt(from, to Point, t float64) {
+	o.Position.X, o.Position.Y = from.X+(to.X-from.X)*t, from.Y+(to.Y-from.Y)*t
+	o.collider.ChangePosition(o.Position.X, o.Position.Y)
+}
+
 func (o *Object) Remove() { o.cleanup = true }
 
 func (o *Object) IsAlive() bool { return !o.cleanup }
 
+// Scorer is a pickup: a coin adding its value to the score of the player who collects
+// it, or a power-up giving its effect.
 type Scorer struct {
 	Object
 	Value int32
+
+	// Effect is the effect of a power-up, EffectNone for a coin.
+	Effect PlayerEffect
+
+	// dropped is true for the coins dropped by eliminated players, which disappear once
+	// collected.
+	dropped bool
 }
 
-func NewCoin(pos ...*Point) *Scorer {
-	if len(pos) == 0 {
-		pos = []*Point{{
-			X: rand.Float64() * float64(consts.MapWidth*consts.CellWidth),
-			Y: rand.Float64() * float64(consts.MapWidth*consts.CellWidth),
-		}}
-	}
-	s := &Scorer{Value: consts.CoinValue}
+func NewCoin(pos *Point, rules *GameRules) *Scorer {
+	s := &Scorer{Value: rules.CoinValue}
+
+	s.setup(pos, rules.CoinSize)
+
+	return s
+}
 
-	s.setup(pos[0], consts.CoinSize)
+// NewRandomCoin creates a coin at a position drawn from the given random source,
+// so that coin placement can be reproduced from the game seed.
+func NewRandomCoin(r *rand.Rand, rules *GameRules) *Scorer {
+	return NewCoin(randomPosition(r, rules), rules)
+}
+
+// NewPowerUp creates a power-up giving effect to the player who collects it.
+func NewPowerUp(pos *Point, effect PlayerEffect, rules *GameRules) *Scorer {
+	s := &Scorer{Effect: effect}
+	s.setup(pos, rules.CoinSize)
 
 	return s
 }
 
-func NewBigCoin(center *Point) *Scorer {
-	s := &Scorer{Value: consts.BigCoinValue}
-	s.setup(center, consts.BigCoinSize)
+// NewRandomPowerUp creates a power-up of an effect and at a position drawn from the
+// given random source.
+func NewRandomPowerUp(r *rand.Rand, rules *GameRules) *Scorer {
+	effect := powerUps[r.Intn(len(powerUps))]
+	return NewPowerUp(randomPosition(r, rules), effect, rules)
+}
+
+// randomPosition returns a position on the map drawn from the given random source.
+func randomPosition(r *rand.Rand, rules *GameRules) *Point {
+	return &Point{
+		X: r.Float64() * float64(rules.MapWidth*consts.CellWidth),
+		Y: r.Float64() * float64(rules.MapWidth*consts.CellWidth),
+	}
+}
+
+func NewBigCoin(center *Point, rules *GameRules) *Scorer {
+	s := &Scorer{Value: rules.BigCoinValue}
+	s.setup(center, rules.BigCoinSize)
 
 	return s
 }
@@ -67,12 +107,20 @@ func (s *Scorer) IsCollidingWithPlayer(player *Player) bool {
 	ok := PolygonsIntersect(s.collider.polygon(), player.Collider().polygon())
 	if ok {
 		player.AddScore(int(s.Value))
+		if s.IsPowerUp() {
+			player.applyEffect(s.Effect)
+		}
 		s.Remove()
 	}
 
 	return ok
 }
 
+// IsPowerUp returns true if the pickup gives an effect rather than score.
+func (s *Scorer) IsPowerUp() bool {
+	return s.Effect != EffectNone
+}
+
 func (s *Scorer) Encode(w codec.Writer) (err error) {
 	if _, err = w.WriteBytes(s.uuid[:]); err != nil {
 		return
@@ -91,10 +139,14 @@ func (s *Scorer) Encode(w codec.Writer) (err error) {
 
 type Scorers struct {
 	scorers []*Scorer
+	rng     *rand.Rand
+	rules   *GameRules
 }
 
-func NewScorers() *Scorers {
-	return &Scorers{scorers: []*Scorer{}}
+// NewScorers creates an empty set of scorers. Collected coins are respawned following
+// rules, at positions drawn from r.
+func NewScorers(r *rand.Rand, rules *GameRules) *Scorers {
+	return &Scorers{scorers: []*Scorer{}, rng: r, rules: rules}
 }
 
 func (s *Scorers) Add(scorers ...*Scorer) {
@@ -105,19 +157,77 @@ func (s *Scorers) Set(scorers []*Scorer) {
 	s.scorers = scorers
 }
 
+// maxDroppedCoins is the largest number of coins dropped by an eliminated player.
+const maxDroppedCoins = 4
+
+// Drop scatters coins worth value in total around pos, in coins of at most the value of
+// a regular coin.
+func (s *Scorers) Drop(pos Point, value int32) {
+	count := int32(maxDroppedCoins)
+	if s.rules.CoinValue > 0 {
+		count = min(count, max(1, (value+s.rules.CoinValue-1)/s.rules.CoinValue))
+	}
+
+	for i := int32(0); i < count; i++ {
+		share := value / count
+		if i == 0 {
+			share += value % count
+		}
+
+		angle := 2 * math.Pi * float64(i) / float64(count)
+		coin := NewCoin(&Point{
+			X: pos.X + math.Cos(angle)*consts.PlayerSize/2,
+			Y: pos.Y + math.Sin(angle)*consts.PlayerSize/2,
+		}, s.rules)
+		coin.Value = share
+		coin.dropped = true
+		s.scorers = append(s.scorers, coin)
+	}
+}
+
+// Update replaces the coins and the power-ups collected, dropped coins excepted, by a
+// power-up with the chance of the rules and by a coin otherwise. It returns true when
+// the last regular coin, the big coin of the point rush stage, is collected.
 func (s *Scorers) Update() bool {
-	for i := 0; i < len(s.scorers); i++ {
-		if !s.scorers[i].IsAlive() {
-			if len(s.scorers) == 1 {
-				return true
-			}
-			s.scorers[i] = NewCoin()
-			utils.Log("coin", "score", "new coin spawn position (%f, %f)", s.scorers[i].Position.X, s.scorers[i].Position.Y)
+	regular := 0
+	for _, scorer := range s.scorers {
+		if !scorer.dropped {
+			regular++
 		}
 	}
+
+	scorers := make([]*Scorer, 0, len(s.scorers))
+	for _, scorer := range s.scorers {
+		switch {
+		case scorer.IsAlive():
+		case scorer.dropped:
+			continue
+		case regular == 1:
+			return true
+		default:
+			scorer = s.spawn()
+		}
+		scorers = append(scorers, scorer)
+	}
+
+	s.scorers = scorers
 	return false
 }
 
+// spawn returns the pickup replacing a collected one. The random source is only drawn
+// for power-ups when they are enabled, so that the coins stay the same otherwise.
+func (s *Scorers) spawn() *Scorer {
+	if s.rules.PowerUpChance > 0 && s.rng.Float64() < s.rules.PowerUpChance {
+		powerUp := NewRandomPowerUp(s.rng, s.rules)
+		utils.Log("coin", "score", "new %s spawn position (%f, %f)", powerUp.Effect, powerUp.Position.X, powerUp.Position.Y)
+		return powerUp
+	}
+
+	coin := NewRandomCoin(s.rng, s.rules)
+	utils.Log("coin", "score", "new coin spawn position (%f, %f)", coin.Position.X, coin.Position.Y)
+	return coin
+}
+
 func (s *Scorers) List() []*Scorer {
 	return s.scorers
 }
@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"math/rand"
 	"testing"
 
 	"github.com/capucinoxx/jdis-games-2024/consts"
@@ -53,10 +54,10 @@ func TestScorerCollision(t *testing.T) {
 				players[i] = NewPlayer(fmt.Sprintf("Player%d", i), 0, pos, nil)
 			}
 
-			scorers := NewScorers()
+			scorers := NewScorers(rand.New(rand.NewSource(0)), DefaultGameRules())
 			initialUUIDs := make([][16]byte, 0, len(tt.scorerPositions))
 			for _, pos := range tt.scorerPositions {
-				coin := NewCoin(pos)
+				coin := NewCoin(pos, DefaultGameRules())
 				scorers.Add(coin)
 				initialUUIDs = append(initialUUIDs, coin.uuid)
 			}
@@ -64,8 +65,9 @@ func TestScorerCollision(t *testing.T) {
 			gameState := NewGameState(nil)
 			gameState.coins = scorers
 
+			gameState.UpdateSpace(nil, 0)
 			for _, player := range players {
-				player.Update([]*Player{}, gameState, 0)
+				player.Update(gameState, 0)
 			}
 			scorers.Update()
 
@@ -87,3 +89,98 @@ func TestScorerCollision(t *testing.T) {
 		})
 	}
 }
+
+func TestScorersSeededRespawn(t *testing.T) {
+	respawn := func(seed int64) []Point {
+		scorers := NewScorers(rand.New(rand.NewSource(seed)), DefaultGameRules())
+		for i := 0; i < 5; i++ {
+			coin := NewCoin(&Point{X: 5, Y: 5}, DefaultGameRules())
+			coin.Remove()
+			scorers.Add(coin)
+		}
+		scorers.Update()
+
+		positions := make([]Point, 0, len(scorers.List()))
+		for _, coin := range scorers.List() {
+			positions = append(positions, *coin.Position)
+		}
+		return positions
+	}
+
+	a, b := respawn(42), respawn(42)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Errorf("Coin %d spawned at %v and %v with the same seed", i, a[i], b[i])
+		}
+	}
+
+	c := respawn(43)
+	if a[0] == c[0] {
+		t.Errorf("Expected different seeds to spawn coins at different positions")
+	}
+}
+
+func TestDeathScoreDrop(t *testing.T) {
+	rules := DefaultGameRules()
+	rules.DeathScoreDrop = 0.5
+
+	gameState := NewGameState(nil)
+	gameState.rules = rules
+	gameState.coins = NewScorers(rand.New(rand.NewSource(0)), rules)
+	bigCoin := NewBigCoin(&Point{X: 50, Y: 50}, rules)
+	gameState.coins.Add(bigCoin)
+
+	p := newPlayer("victim", 0, &Point{X: 5, Y: 5}, nil, rules)
+	p.score = 100
+	p.TakeDmg(rules.PlayerHealth)
+	p.Update(gameState, 0)
+
+	if p.score != 50 {
+		t.Errorf("Expected the victim to keep half of its score, got %d", p.score)
+	}
+
+	// 50 are dropped in two coins, the first one holding the remainder.
+	dropped := gameState.coins.List()[1:]
+	if len(dropped) != 2 || dropped[0].Value != 25 || dropped[1].Value != 25 {
+		t.Fatalf("Expected two coins of 25 to be dropped, got %d", len(dropped))
+	}
+
+	for _, coin := range dropped {
+		if !coin.Position.WithinDistanceOf(consts.PlayerSize, &Point{X: 5, Y: 5}) {
+			t.Errorf("Expected the coins to be dropped where the player died, got %v", coin.Position)
+		}
+	}
+
+	// Collected dropped coins disappear without ending the point rush stage.
+	dropped[0].Remove()
+	if over := gameState.coins.Update(); over || len(gameState.coins.List()) != 2 {
+		t.Errorf("Update() = %v with %d coins, expected the dropped coin to disappear", over, len(gameState.coins.List()))
+	}
+
+	bigCoin.Remove()
+	if over := gameState.coins.Update(); !over {
+		t.Errorf("Expected the game to end when the big coin is collected")
+	}
+}
+
+func TestAdminKillKeepsScore(t *testing.T) {
+	rules := DefaultGameRules()
+	rules.DeathScoreDrop = 0.5
+
+	gameState := NewGameState(nil)
+	gameState.rules = rules
+	gameState.coins = NewScorers(rand.New(rand.NewSource(0)), rules)
+
+	p := newPlayer("victim", 0, &Point{X: 5, Y: 5}, nil, rules)
+	p.score = 100
+	p.Kill()
+	p.Update(gameState, 0)
+
+	if p.IsAlive() {
+		t.Errorf("Expected the player to be killed")
+	}
+
+	if p.score != 100 || len(gameState.coins.List()) != 0 {
+		t.Errorf("Expected the player to keep its score, got %d with %d coins dropped", p.score, len(gameState.coins.List()))
+	}
+}
@@ -137,6 +137,63 @@ func Normalize(p Point) Point {
 	return Point{X: p.X / length, Y: p.Y / length}
 }
 
+// Sweep returns the fraction of the movement from `from` to `to` after which the moving
+// point enters the axis aligned rectangle between min and max, or false if it never
+// enters it. A point starting strictly inside the rectangle hits it right away.
+//
+// Testing the whole movement, instead of its end position only, keeps fast objects
+// from going through thin ones during a tick.
+func Sweep(from, to, min, max Point) (float64, bool) {
+	t, _, hit := sweep(from, to, min, max)
+	return t, hit
+}
+
+// sweep is Sweep, also returning the normal of the side of the rectangle entered. The
+// normal is zero when the point starts inside the rectangle.
+func sweep(from, to, min, max Point) (float64, Point, bool) {
+	enter, exit := math.Inf(-1), math.Inf(1)
+	normal := Point{}
+
+	axes := [2][4]float64{
+		{from.X, to.X - from.X, min.X, max.X},
+		{from.Y, to.Y - from.Y, min.Y, max.Y},
+	}
+	for i, axis := range axes {
+		start, delta, low, high := axis[0], axis[1], axis[2], axis[3]
+		if delta == 0 {
+			if start < low || start > high {
+				return 0, Point{}, false
+			}
+			continue
+		}
+
+		t1, t2 := (low-start)/delta, (high-start)/delta
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+
+		if t1 > enter {
+			enter = t1
+			normal = Point{}
+			if i == 0 {
+				normal.X = -math.Copysign(1, delta)
+			} else {
+				normal.Y = -math.Copysign(1, delta)
+			}
+		}
+		exit = math.Min(exit, t2)
+	}
+
+	if from.X > min.X && from.X < max.X && from.Y > min.Y && from.Y < max.Y {
+		return 0, Point{}, true
+	}
+
+	if enter > exit || enter < 0 || enter > 1 {
+		return 0, Point{}, false
+	}
+	return enter, normal, true
+}
+
 type Rect struct {
 	a, b, c, d *Point
 }
@@ -232,11 +289,13 @@ func ProjectPolygon(axis Point, polygon Polygon) (float64, float64) {
 	return min, max
 }
 
+// Rotate turns the collider around its pivot to the angle theta.
 func (r *RectCollider) Rotate(theta float64) {
-	r.rotate(theta, r.rect.a)
-	r.rotate(theta, r.rect.b)
-	r.rotate(theta, r.rect.c)
-	r.rotate(theta, r.rect.d)
+	delta := theta - r.Rotation
+	r.rotate(delta, r.rect.a)
+	r.rotate(delta, r.rect.b)
+	r.rotate(delta, r.rect.c)
+	r.rotate(delta, r.rect.d)
 
 	r.Rotation = theta
 }
@@ -1,6 +1,11 @@
 package model
 
-import "testing"
+import (
+	"math"
+	"testing"
+
+	"github.com/capucinoxx/jdis-games-2024/consts"
+)
 
 func TestPolygonsIntersect(t *testing.T) {
 	tests := map[string]struct {
@@ -42,3 +47,110 @@ func TestPolygonsIntersect(t *testing.T) {
 		})
 	}
 }
+
+func TestSweep(t *testing.T) {
+	min, max := Point{X: 4, Y: 4}, Point{X: 6, Y: 6}
+
+	tests := map[string]struct {
+		from, to Point
+		want     float64
+		hit      bool
+	}{
+		"Through the rectangle": {from: Point{X: 0, Y: 5}, to: Point{X: 10, Y: 5}, want: 0.4, hit: true},
+		"Stops before":          {from: Point{X: 0, Y: 5}, to: Point{X: 3, Y: 5}},
+		"Passes beside":         {from: Point{X: 0, Y: 7}, to: Point{X: 10, Y: 7}},
+		"Diagonal":              {from: Point{X: 0, Y: 0}, to: Point{X: 8, Y: 8}, want: 0.5, hit: true},
+		"Starts inside":         {from: Point{X: 5, Y: 5}, to: Point{X: 10, Y: 5}, want: 0, hit: true},
+		"Leaves from the edge":  {from: Point{X: 4, Y: 5}, to: Point{X: 0, Y: 5}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, hit := Sweep(tt.from, tt.to, min, max)
+			if hit != tt.hit || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Sweep() = (%v, %v), want (%v, %v)", got, hit, tt.want, tt.hit)
+			}
+		})
+	}
+}
+
+func TestPlayerMovementStopsAtWalls(t *testing.T) {
+	space := spaceOf()
+	space.SetWalls([]*Collider{{Points: []*Point{{X: 10, Y: 0}, {X: 10, Y: 20}}}})
+
+	p := NewPlayer("player", 0, &Point{X: 5, Y: 5}, nil)
+	p.rules = &GameRules{PlayerSpeed: 20}
+	p.Controls.Dest = &Point{X: 50, Y: 5}
+
+	p.HandleMovement(space, 1)
+
+	if want := 10 - consts.PlayerSize/2.0; p.Position.X > want || p.Position.X < want-0.001 {
+		t.Errorf("Expected the player to stop against the wall at x = %v, got %v", want, p.Position.X)
+	}
+
+	// The player is not stuck against the wall.
+	p.Controls.Dest = &Point{X: 9.5, Y: 50}
+	p.HandleMovement(space, 1)
+	if math.Abs(p.Position.Y-25) > 1e-9 {
+		t.Errorf("Expected the player to move along the wall, got %v", *p.Position)
+	}
+}
+
+func TestPlayerMovementSlidesAlongWalls(t *testing.T) {
+	// A corner: a vertical wall at x = 10 and a horizontal one at y = 20.
+	walls := []*Collider{
+		{Points: []*Point{{X: 10, Y: 0}, {X: 10, Y: 20}}},
+		{Points: []*Point{{X: 0, Y: 20}, {X: 10, Y: 20}}},
+	}
+	limit := Point{X: 10 - consts.PlayerSize/2.0, Y: 20 - consts.PlayerSize/2.0}
+
+	tests := map[string]struct {
+		from, dest Point
+		speed      float64
+		want       Point
+	}{
+		// Only the part of the movement going into the wall is lost.
+		"Slides along the wall": {from: Point{X: 5, Y: 5}, dest: Point{X: 25, Y: 10}, speed: 20, want: Point{X: limit.X, Y: 5 + 20*5/math.Sqrt(425)}},
+		"Stops in the corner":   {from: Point{X: 8, Y: 17}, dest: Point{X: 30, Y: 40}, speed: 10, want: limit},
+		"Moves away freely":     {from: Point{X: 5, Y: 5}, dest: Point{X: 0, Y: 10}, speed: 3, want: Point{X: 5 - 3/math.Sqrt(2), Y: 5 + 3/math.Sqrt(2)}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			space := spaceOf()
+			space.SetWalls(walls)
+
+			p := NewPlayer("player", 0, &tt.from, nil)
+			p.rules = &GameRules{PlayerSpeed: tt.speed}
+			p.Controls.Dest = &tt.dest
+			p.HandleMovement(space, 1)
+
+			if math.Abs(p.Position.X-tt.want.X) > 1e-5 || math.Abs(p.Position.Y-tt.want.Y) > 1e-5 {
+				t.Errorf("Position = %v, want %v", *p.Position, tt.want)
+			}
+
+			if p.Position.X > limit.X || p.Position.Y > limit.Y {
+				t.Errorf("The player went through a wall, got %v", *p.Position)
+			}
+		})
+	}
+}
+
+func TestPlayerMovementLeavesWalls(t *testing.T) {
+	space := spaceOf()
+	space.SetWalls([]*Collider{{Points: []*Point{{X: 10, Y: 0}, {X: 10, Y: 20}}}})
+
+	// The player overlaps the wall, closer to its left side.
+	p := NewPlayer("player", 0, &Point{X: 9.9, Y: 5}, nil)
+	p.rules = &GameRules{PlayerSpeed: 1}
+	p.Controls.Dest = &Point{X: 9.9, Y: 50}
+
+	p.HandleMovement(space, 1)
+
+	if want := 10 - consts.PlayerSize/2.0; p.Position.X > want || p.Position.X < want-0.001 {
+		t.Errorf("Expected the player to be pushed out of the wall at x = %v, got %v", want, p.Position.X)
+	}
+	if math.Abs(p.Position.Y-6) > 1e-9 {
+		t.Errorf("Expected the player to keep moving, got %v", *p.Position)
+	}
+}
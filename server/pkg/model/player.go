@@ -20,6 +20,9 @@ type Connection interface {
 	// Close terminates the connection after a specified timeout.
 	Close(time.Duration, bool)
 
+	// Reject closes the connection, telling the client the reason why it is rejected.
+	Reject(time.Duration, string)
+
 	// PrepareRead prepares the connection to read a specified amount of data within a timeout.
 	PrepareRead(int64, time.Duration)
 
@@ -53,10 +56,177 @@ type Controls struct {
 	RotateBlade  *float64      `json:"rotate_blade,omitempty"`
 }
 
+// Merge overrides the controls with the ones set in other.
+func (c *Controls) Merge(other Controls) {
+	if other.Dest != nil {
+		c.Dest = other.Dest
+	}
+	if other.Save != nil {
+		c.Save = other.Save
+	}
+	if other.SwitchWeapon != nil {
+		c.SwitchWeapon = other.SwitchWeapon
+	}
+	if other.Shoot != nil {
+		c.Shoot = other.Shoot
+	}
+	if other.RotateBlade != nil {
+		c.RotateBlade = other.RotateBlade
+	}
+}
+
+// PlayerAction is the body of a MessagePlayerAction: the controls sent by a client
+// along with their sequence number.
+type PlayerAction struct {
+	// Sequence is an increasing number chosen by the client and echoed in the game state
+	// once the action is processed. It is 0 for clients which do not number their actions.
+	Sequence uint32 `json:"seq,omitempty"`
+	Controls
+}
+
+// ActionEncoding is the encoding of the body of the MessagePlayerAction sent by a client.
+type ActionEncoding uint8
+
+const (
+	// ActionEncodingJSON encodes actions as a JSON object, the default.
+	ActionEncodingJSON ActionEncoding = iota
+
+	// ActionEncodingBinary encodes actions with PlayerAction.Encode.
+	ActionEncodingBinary
+)
+
+// Controls present in a binary encoded PlayerAction.
+const (
+	ActionDest uint8 = 1 << iota
+	ActionShoot
+	ActionSwitchWeapon
+	ActionRotateBlade
+	ActionSave
+)
+
+// Encode writes the action in its binary encoding: the sequence, a mask of the controls
+// present (Action*) and the value of each control present in the order of the mask.
+func (a *PlayerAction) Encode(w codec.Writer) (err error) {
+	var mask uint8
+	if a.Dest != nil {
+		mask |= ActionDest
+	}
+	if a.Shoot != nil {
+		mask |= ActionShoot
+	}
+	if a.SwitchWeapon != nil {
+		mask |= ActionSwitchWeapon
+	}
+	if a.RotateBlade != nil {
+		mask |= ActionRotateBlade
+	}
+	if a.Save != nil {
+		mask |= ActionSave
+	}
+
+	if err = w.WriteUint32(a.Sequence); err != nil {
+		return
+	}
+
+	if err = w.WriteUint8(mask); err != nil {
+		return
+	}
+
+	if a.Dest != nil {
+		if err = a.Dest.Encode(w); err != nil {
+			return
+		}
+	}
+
+	if a.Shoot != nil {
+		if err = a.Shoot.Encode(w); err != nil {
+			return
+		}
+	}
+
+	if a.SwitchWeapon != nil {
+		if err = w.WriteUint8(uint8(*a.SwitchWeapon)); err != nil {
+			return
+		}
+	}
+
+	if a.RotateBlade != nil {
+		if err = w.WriteFloat64(*a.RotateBlade); err != nil {
+			return
+		}
+	}
+
+	if a.Save != nil {
+		if err = w.WriteString(*a.Save); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Decode reads an action written by Encode.
+func (a *PlayerAction) Decode(r codec.Reader) (err error) {
+	*a = PlayerAction{}
+
+	if a.Sequence, err = r.ReadUint32(); err != nil {
+		return
+	}
+
+	var mask uint8
+	if mask, err = r.ReadUint8(); err != nil {
+		return
+	}
+
+	if mask&ActionDest != 0 {
+		a.Dest = &Point{}
+		if err = a.Dest.Decode(r); err != nil {
+			return
+		}
+	}
+
+	if mask&ActionShoot != 0 {
+		a.Shoot = &Point{}
+		if err = a.Shoot.Decode(r); err != nil {
+			return
+		}
+	}
+
+	if mask&ActionSwitchWeapon != 0 {
+		var weapon uint8
+		if weapon, err = r.ReadUint8(); err != nil {
+			return
+		}
+		switchWeapon := PlayerWeapon(weapon)
+		a.SwitchWeapon = &switchWeapon
+	}
+
+	if mask&ActionRotateBlade != 0 {
+		var rotation float64
+		if rotation, err = r.ReadFloat64(); err != nil {
+			return
+		}
+		a.RotateBlade = &rotation
+	}
+
+	if mask&ActionSave != 0 {
+		var save string
+		if save, err = r.ReadString(); err != nil {
+			return
+		}
+		a.Save = &save
+	}
+
+	return
+}
+
 const (
 	PlayerWeaponNone (PlayerWeapon) = iota
 	PlayerWeaponCanon
 	PlayerWeaponBlade
+	PlayerWeaponShotgun
+	PlayerWeaponMine
+	PlayerWeaponGrenade
 )
 
 type PlayerScore struct {
@@ -70,22 +240,44 @@ type Player struct {
 
 	Nickname         string
 	Color            int
+	Team             string
 	Client           *Client
 	health           int
 	respawnCountdown float64
 
+	// lastAttacker is the last player who damaged the player since it respawned, and
+	// dying is true until the penalty of its last death is applied.
+	lastAttacker *Player
+	dying        bool
+
 	Controls Controls
 
 	currentWeapon PlayerWeapon
-	cannon        *Cannon
-	blade         *Blade
+	weapons       map[PlayerWeapon]Weapon
 	score         int
 
+	// effects holds the time (in seconds) left to the effects of the power-ups active
+	// on the player.
+	effects map[PlayerEffect]float64
+
 	storage [100]byte
 	mu      sync.RWMutex
+
+	rules  *GameRules
+	events *EventBus
+
+	// lastSequence is the sequence of the last action processed and lastSequenceTick
+	// the tick at which it was applied.
+	lastSequence     uint32
+	lastSequenceTick int32
 }
 
+// NewPlayer creates a player following the default rules.
 func NewPlayer(name string, color int, pos *Point, conn Connection) *Player {
+	return newPlayer(name, color, pos, conn, DefaultGameRules())
+}
+
+func newPlayer(name string, color int, pos *Point, conn Connection, rules *GameRules) *Player {
 	p := &Player{
 		Nickname: name,
 		Color:    color,
@@ -96,27 +288,164 @@ func NewPlayer(name string, color int, pos *Point, conn Connection) *Player {
 		},
 		currentWeapon: PlayerWeaponNone,
 
-		health: 100,
+		health:  rules.PlayerHealth,
+		effects: make(map[PlayerEffect]float64),
+		rules:   rules,
 	}
 
 	p.setup(pos, consts.PlayerSize)
-	p.cannon = NewCanon(p)
-	p.blade = NewBlade(p)
+	p.arm()
 
 	return p
 }
 
+// arm gives the player a new weapon of each type registered.
+func (p *Player) arm() {
+	p.weapons = make(map[PlayerWeapon]Weapon, len(weaponTypes))
+	for _, t := range weaponTypes {
+		p.weapons[t.ID] = t.New(p)
+	}
+}
+
+// Weapon returns the weapon of the player with the given id, nil if it has none.
+func (p *Player) Weapon(id PlayerWeapon) Weapon {
+	return p.weapons[id]
+}
+
+// liveProjectiles returns the number of projectiles fired by the player still in the game.
+func (p *Player) liveProjectiles() int {
+	count := 0
+	for _, weapon := range p.weapons {
+		count += len(weapon.Fired())
+	}
+	return count
+}
+
+// triggers returns the state of the triggers of the weapons of the player, in the order
+// of their ids.
+func (p *Player) triggers() []TriggerState {
+	states := []TriggerState{}
+	for _, t := range weaponTypes {
+		if weapon, ok := p.weapons[t.ID].(Triggered); ok {
+			states = append(states, weapon.Trigger().State(t.ID, p.rules.Tickrate))
+		}
+	}
+	return states
+}
+
+// cannon returns the cannon of the player, nil if it was replaced by another weapon.
+func (p *Player) cannon() *Cannon {
+	cannon, _ := p.weapons[PlayerWeaponCanon].(*Cannon)
+	return cannon
+}
+
+// blade returns the blade of the player, nil if it was replaced by another weapon.
+func (p *Player) blade() *Blade {
+	blade, _ := p.weapons[PlayerWeaponBlade].(*Blade)
+	return blade
+}
+
 func (p *Player) Collider() *RectCollider {
 	return p.collider
 }
 
+// SetPosition moves the player to the given position.
+func (p *Player) SetPosition(pos Point) {
+	p.Position = &Point{X: pos.X, Y: pos.Y}
+	p.collider.ChangePosition(pos.X, pos.Y)
+}
+
+// CurrentWeapon returns the weapon currently equipped by the player.
+func (p *Player) CurrentWeapon() PlayerWeapon {
+	return p.currentWeapon
+}
+
+// SetCurrentWeapon equips the given weapon.
+func (p *Player) SetCurrentWeapon(weapon PlayerWeapon) {
+	p.currentWeapon = weapon
+}
+
+// LastSequence returns the sequence of the last action processed and the tick at which
+// it was applied.
+func (p *Player) LastSequence() (uint32, int32) {
+	return p.lastSequence, p.lastSequenceTick
+}
+
+// SetLastSequence records that the action with the given sequence was applied at tick.
+func (p *Player) SetLastSequence(sequence uint32, tick int32) {
+	p.lastSequence = sequence
+	p.lastSequenceTick = tick
+}
+
+// TakeDmg damages the player without an attacker, even through a shield. If it is
+// eliminated, the last player who hit it is credited with the kill.
 func (p *Player) TakeDmg(dmg int) {
+	p.takeDmg(dmg, nil, PlayerWeaponNone)
+}
+
+// Kill eliminates the player at once, for the admins. Nobody is credited with the kill
+// and the player does not drop any score.
+func (p *Player) Kill() {
+	if !p.IsAlive() {
+		return
+	}
+
+	p.lastAttacker = nil
+	p.TakeDmg(1_000_000)
+	p.dying = false
+}
+
+// takeDmg damages the player, attacker being nil when the damage does not come from a
+// player. When the player is eliminated, the killer is credited with the kill unless it
+// is a teammate. The shield is checked by the attacks, see hit.
+func (p *Player) takeDmg(dmg int, attacker *Player, weapon PlayerWeapon) {
 	alive := p.IsAlive()
 	p.health -= dmg
 
 	if p.health < 0 && alive {
 		p.Client.SetBlind(true)
 	}
+
+	if attacker != nil {
+		p.lastAttacker = attacker
+	}
+
+	if !alive || p.IsAlive() {
+		return
+	}
+	p.dying = true
+
+	killer := p.lastAttacker
+	if killer == nil {
+		p.events.Emit(Event{Type: EventDeath, Player: p.Nickname, Pos: *p.Position})
+		return
+	}
+
+	if killer != attacker {
+		weapon = PlayerWeaponNone
+	}
+	p.events.Emit(Event{Type: EventKill, Player: killer.Nickname, Target: p.Nickname, Weapon: weapon})
+	p.events.Emit(Event{Type: EventDeath, Player: p.Nickname, Target: killer.Nickname, Pos: *p.Position})
+
+	if !killer.IsTeammate(p) {
+		killer.score += p.rules.ScoreOnKill
+		utils.Log(killer.Nickname, "score", "kill %s +%d total: %d", p.Nickname, p.rules.ScoreOnKill, killer.score)
+	}
+}
+
+// dropScore takes the share of its score the player loses when eliminated and drops it
+// as coins where it died.
+func (p *Player) dropScore(coins *Scorers) {
+	p.dying = false
+
+	lost := int32(float64(p.score) * p.rules.DeathScoreDrop)
+	if lost <= 0 {
+		return
+	}
+
+	p.score -= int(lost)
+	coins.Drop(*p.Position, lost)
+	utils.Log(p.Nickname, "score", "drop %d total: %d", lost, p.score)
 }
 
 func (p *Player) AddScore(score int) {
@@ -143,15 +472,53 @@ func (p *Player) IsAlive() bool {
 	return p.health > 0
 }
 
-func (p *Player) Update(players []*Player, game *GameState, dt float64) {
+// IsTeammate returns true if other is in the same team as the player. Players without a
+// team have no teammates.
+func (p *Player) IsTeammate(other *Player) bool {
+	return p.Team != "" && p.Team == other.Team
+}
+
+// canHit returns true if the weapons of the player damage other.
+func (p *Player) canHit(other *Player) bool {
+	if p.Nickname == other.Nickname || !other.IsAlive() {
+		return false
+	}
+	return p.rules.FriendlyFire || !p.IsTeammate(other)
+}
+
+// hit damages other with a weapon and scores unless other is a teammate or shielded.
+func (p *Player) hit(other *Player, weapon PlayerWeapon, dmg, score int) {
+	if other.HasEffect(EffectShield) {
+		utils.Log(p.Nickname, "score", "hit shielded %s with %s", other.Nickname, weaponName(weapon))
+		return
+	}
+
+	p.events.Emit(Event{Type: EventHit, Player: p.Nickname, Target: other.Nickname, Weapon: weapon, Value: int32(dmg)})
+	other.takeDmg(dmg, p, weapon)
+
+	if p.IsTeammate(other) {
+		utils.Log(p.Nickname, "score", "hit teammate %s with %s", other.Nickname, weaponName(weapon))
+		return
+	}
+
+	p.score += score
+	utils.Log(p.Nickname, "score", "hit %s with %s +%d total: %d", other.Nickname, weaponName(weapon), score, p.score)
+}
+
+func (p *Player) Update(game *GameState, dt float64) {
+	if p.dying {
+		p.dropScore(game.Coins())
+	}
+
 	if !p.IsAlive() {
 		p.respawnCountdown += dt
 		return
 	}
 
-	p.HandleMovement(players, game.Map, dt)
-	p.HandleWeapon(players, game.Map, dt)
-	p.HandleCoinCollision(game.coins.List())
+	p.updateEffects(dt)
+	p.HandleMovement(game.space, dt)
+	p.HandleWeapon(game.space, dt)
+	p.HandleCoinCollision(game.space)
 	p.HandleSave()
 }
 
@@ -172,47 +539,93 @@ func (p *Player) HandleSave() {
 	p.mu.Unlock()
 }
 
-func (p *Player) HandleCoinCollision(coins []*Scorer) {
-	for _, coin := range coins {
-		if coin.IsCollidingWithPlayer(p) {
-			utils.Log(p.Nickname, "score", "take coin +%d total: %d",
-				coin.Value, p.score)
+func (p *Player) HandleCoinCollision(space *SpatialGrid) {
+	for _, coin := range space.Coins(p.collider.Bounds()) {
+		if !coin.IsCollidingWithPlayer(p) {
+			continue
 		}
+
+		if coin.IsPowerUp() {
+			p.events.Emit(Event{Type: EventPowerUpCollected, Player: p.Nickname, Value: int32(coin.Effect), Pos: *coin.Position})
+			utils.Log(p.Nickname, "score", "take %s", coin.Effect)
+			continue
+		}
+
+		p.events.Emit(Event{Type: EventCoinCollected, Player: p.Nickname, Value: coin.Value, Pos: *coin.Position})
+		utils.Log(p.Nickname, "score", "take coin +%d total: %d",
+			coin.Value, p.score)
 	}
 }
 
 func (p *Player) HandleRespawn(game *GameState) {
-	if !p.IsAlive() && p.respawnCountdown > consts.RespawnTime {
+	if !p.IsAlive() && p.respawnCountdown > p.rules.RespawnTime {
 		p.Respawn(game)
 	}
 }
 
 func (p *Player) Respawn(game *GameState) {
-	p.health = 100
+	p.health = p.rules.PlayerHealth
 	p.respawnCountdown = 0
+	p.lastAttacker = nil
+	clear(p.effects)
+	from := *p.collider.Pivot
 	p.Position = game.GetSpawnPoint()
 	p.collider.ChangePosition(p.Position.X, p.Position.Y)
+	game.space.Move(p, from)
 
-	p.blade.collider.Rotation = 0.0
+	if t, ok := weaponType(PlayerWeaponBlade); ok {
+		p.weapons[t.ID] = t.New(p)
+	}
 	p.Client.SetBlind(false)
+
+	p.events.Emit(Event{Type: EventRespawn, Player: p.Nickname, Pos: *p.Position})
 }
 
-func (p *Player) HandleMovement(players []*Player, m Map, dt float64) {
+// wallGap is the distance kept between a player and the wall it stopped against, so
+// that it can slide along the wall.
+const wallGap = 1e-6
+
+// maxSlides is the number of walls a player can slide along during a tick.
+const maxSlides = 3
+
+func (p *Player) HandleMovement(space *SpatialGrid, dt float64) {
 	if p.Controls.Dest == nil {
 		return
 	}
 
-	px, py := p.Position.X, p.Position.Y
-
+	// A player overlapping a wall, after a respawn or a teleport, is first pushed out of
+	// it, otherwise every sweep would stop it at once.
+	start := *p.Position
 	p.moveToDestination(dt)
+	from := space.pushOut(start, consts.PlayerSize)
+	to := Point{X: p.Position.X + from.X - start.X, Y: p.Position.Y + from.Y - start.Y}
+
+	// The player stops against the first wall on its way, then the rest of its movement
+	// is projected on the wall, so that it slides along it. Sliding in a corner hits the
+	// other wall, hence the few iterations.
+	for i := 0; i < maxSlides; i++ {
+		t, normal, hit := space.sweepWalls(from, to, consts.PlayerSize)
+		if !hit {
+			break
+		}
 
-	for _, collider := range m.Colliders() {
-		if PolygonsIntersect(p.collider.polygon(), collider.polygon()) {
-			p.Position.X, p.Position.Y = px, py
-			p.collider.ChangePosition(px, py)
-			return
+		contact := Point{
+			X: from.X + (to.X-from.X)*t + normal.X*wallGap,
+			Y: from.Y + (to.Y-from.Y)*t + normal.Y*wallGap,
+		}
+		rest := Point{X: (to.X - from.X) * (1 - t), Y: (to.Y - from.Y) * (1 - t)}
+		dot := rest.X*normal.X + rest.Y*normal.Y
+
+		from = contact
+		to = Point{X: contact.X + rest.X - normal.X*dot, Y: contact.Y + rest.Y - normal.Y*dot}
+		if normal == (Point{}) || i == maxSlides-1 {
+			to = contact
+			break
 		}
 	}
+
+	p.Position.X, p.Position.Y = to.X, to.Y
+	p.collider.ChangePosition(to.X, to.Y)
 }
 
 func (p *Player) moveToDestination(dt float64) {
@@ -222,9 +635,10 @@ func (p *Player) moveToDestination(dt float64) {
 	dy := float64(dest.Y - p.Position.Y)
 	dist := math.Sqrt(dx*dx + dy*dy)
 
-	if dist > consts.PlayerSpeed*float64(dt) {
-		nextX := p.Position.X + dx/dist*consts.PlayerSpeed*dt
-		nextY := p.Position.Y + dy/dist*consts.PlayerSpeed*dt
+	speed := p.speed()
+	if dist > speed*float64(dt) {
+		nextX := p.Position.X + dx/dist*speed*dt
+		nextY := p.Position.Y + dy/dist*speed*dt
 
 		p.Position.X = nextX
 		p.Position.Y = nextY
@@ -232,45 +646,161 @@ func (p *Player) moveToDestination(dt float64) {
 	}
 }
 
-func (p *Player) HandleWeapon(players []*Player, m Map, dt float64) {
-	p.cannon.Update(players, dt)
-	bladeCondition := p.Controls.SwitchWeapon == nil && p.currentWeapon == PlayerWeaponBlade
-	p.blade.Update(players, utils.NilIf(p.Controls.RotateBlade, !bladeCondition))
+// HandleWeapon fires the weapon held unless the player switches to another one, then
+// updates every weapon. Switching to a weapon which does not exist is ignored.
+func (p *Player) HandleWeapon(space *SpatialGrid, dt float64) {
+	switching := p.Controls.SwitchWeapon != nil
+	if weapon, ok := p.weapons[p.currentWeapon]; ok && !switching {
+		weapon.Fire(&p.Controls)
+	}
 
-	if p.Controls.SwitchWeapon != nil {
-		p.currentWeapon = *p.Controls.SwitchWeapon
-		return
+	for _, t := range weaponTypes {
+		if weapon, ok := p.weapons[t.ID]; ok {
+			weapon.Update(space, dt)
+		}
 	}
 
-	if p.currentWeapon == PlayerWeaponCanon && p.Controls.Shoot != nil {
-		p.cannon.ShootAt(*p.Controls.Shoot)
-		p.Controls.Shoot = nil
+	if switching {
+		next := *p.Controls.SwitchWeapon
+		if _, ok := p.weapons[next]; !ok && next != PlayerWeaponNone {
+			return
+		}
+
+		if p.currentWeapon != next {
+			p.events.Emit(Event{Type: EventWeaponSwitch, Player: p.Nickname, Weapon: next})
+
+			// The blade stops where it is, instead of turning toward the angle it was
+			// given before the switch.
+			if blade := p.blade(); blade != nil {
+				blade.target = nil
+			}
+		}
+		p.currentWeapon = next
+		return
 	}
 
 	p.Controls.RotateBlade = nil
 }
 
 type PlayerInfo struct {
+	// LastSequence and LastSequenceTick are the sequence of the last action of the
+	// player processed by the server and the tick at which it was applied.
+	LastSequence     uint32
+	LastSequenceTick int32
+
 	Nickname      string
 	Color         int32
+	Team          string
 	Health        int32
 	Score         int64
 	Pos           Point
 	Dest          *Point
 	CurrentWeapon PlayerWeapon
-	Projectiles   []struct {
-		Uuid [16]byte
-		Pos  Point
-		Dest Point
-	}
-	Blade struct {
+	Projectiles   []ProjectileInfo
+	Blade         struct {
 		Start    Point
 		End      Point
 		Rotation float64
 	}
+
+	// Weapons holds the sections of the weapons other than the cannon and the blade,
+	// since ProtocolVersion2.
+	Weapons []WeaponInfo
+
+	// Triggers holds the state of the triggers of the weapons, since ProtocolVersion2.
+	Triggers []TriggerState
+
+	// Effects holds the effects active on the player, since ProtocolVersion2.
+	Effects []EffectState
+}
+
+// TriggerState is the state of the trigger of a weapon sent to the clients.
+type TriggerState struct {
+	Weapon PlayerWeapon
+
+	// Cooldown is the number of ticks before the weapon can fire, reload included.
+	Cooldown uint16
+
+	// Ammo is the number of rounds left in the magazine, -1 for a weapon without one.
+	Ammo int16
+}
+
+func (t TriggerState) Encode(w codec.Writer) (err error) {
+	if err = w.WriteUint8(uint8(t.Weapon)); err != nil {
+		return
+	}
+
+	if err = w.WriteUint16(t.Cooldown); err != nil {
+		return
+	}
+
+	return w.WriteInt16(t.Ammo)
 }
 
-func (p *Player) Encode(w codec.Writer) (err error) {
+func (t *TriggerState) Decode(r codec.Reader) (err error) {
+	var weapon uint8
+	if weapon, err = r.ReadUint8(); err != nil {
+		return
+	}
+	t.Weapon = PlayerWeapon(weapon)
+
+	if t.Cooldown, err = r.ReadUint16(); err != nil {
+		return
+	}
+
+	t.Ammo, err = r.ReadInt16()
+	return
+}
+
+// encodeTriggers writes the number of triggers then each trigger.
+func encodeTriggers(w codec.Writer, triggers []TriggerState) (err error) {
+	if err = w.WriteUint8(uint8(len(triggers))); err != nil {
+		return
+	}
+
+	for _, t := range triggers {
+		if err = t.Encode(w); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// decodeTriggers reads the triggers written by encodeTriggers.
+func decodeTriggers(r codec.Reader) (triggers []TriggerState, err error) {
+	var size uint8
+	if size, err = r.ReadUint8(); err != nil {
+		return
+	}
+
+	triggers = make([]TriggerState, size)
+	for i := range triggers {
+		if err = triggers[i].Decode(r); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ProjectileInfo is a projectile fired by a player.
+type ProjectileInfo struct {
+	Uuid [16]byte
+	Pos  Point
+	Dest Point
+}
+
+// WeaponInfo is the section of a weapon in MessageGameState.
+type WeaponInfo struct {
+	Type        PlayerWeapon
+	Projectiles []ProjectileInfo
+}
+
+func (p *Player) Encode(w codec.Writer) error {
+	return p.encode(w, nil)
+}
+
+// encode writes the player with only the projectiles visible to v.
+func (p *Player) encode(w codec.Writer, v *Visibility) (err error) {
 	if err = w.WriteString(p.Nickname); err != nil {
 		return
 	}
@@ -309,40 +839,110 @@ func (p *Player) Encode(w codec.Writer) (err error) {
 		return
 	}
 
-	bullets := p.cannon.Projectiles
-	if err = w.WriteInt32(int32(len(bullets))); err != nil {
+	// A player without a cannon is written as one which fired nothing, and a player
+	// without a blade as one whose blade has no length.
+	if cannon, ok := p.weapons[PlayerWeaponCanon]; ok {
+		err = cannon.Encode(w, v)
+	} else {
+		err = w.WriteInt32(0)
+	}
+	if err != nil {
 		return
 	}
 
-	for _, bullet := range bullets {
-		if _, err = w.WriteBytes(bullet.uuid[:]); err != nil {
-			return
+	if blade, ok := p.weapons[PlayerWeaponBlade]; ok {
+		return blade.Encode(w, v)
+	}
+
+	if err = p.Position.Encode(w); err != nil {
+		return
+	}
+
+	if err = p.Position.Encode(w); err != nil {
+		return
+	}
+
+	return w.WriteFloat64(0)
+}
+
+// encodeWeapons writes the sections of the weapons other than the cannon and the blade,
+// which have their own place in MessageGameState.
+func (p *Player) encodeWeapons(w codec.Writer, v *Visibility) (err error) {
+	ids := make([]PlayerWeapon, 0, len(p.weapons))
+	for _, t := range weaponTypes {
+		if _, ok := p.weapons[t.ID]; ok && t.ID != PlayerWeaponCanon && t.ID != PlayerWeaponBlade {
+			ids = append(ids, t.ID)
 		}
+	}
+
+	if err = w.WriteUint8(uint8(len(ids))); err != nil {
+		return
+	}
 
-		if err = bullet.Position.Encode(w); err != nil {
+	for _, id := range ids {
+		if err = w.WriteUint8(uint8(id)); err != nil {
 			return
 		}
-		if err = bullet.Destination.Encode(w); err != nil {
+
+		if err = p.weapons[id].Encode(w, v); err != nil {
 			return
 		}
 	}
+	return
+}
 
-	// encode blade
-	if err = p.blade.collider.rect.a.Encode(w); err != nil {
+// decodeWeapons reads the sections written by encodeWeapons.
+func (p *PlayerInfo) decodeWeapons(r codec.Reader) (err error) {
+	var size uint8
+	if size, err = r.ReadUint8(); err != nil {
 		return
 	}
 
-	if err = p.blade.collider.rect.b.Encode(w); err != nil {
-		return
+	p.Weapons = make([]WeaponInfo, size)
+	for i := range p.Weapons {
+		var id uint8
+		if id, err = r.ReadUint8(); err != nil {
+			return
+		}
+		p.Weapons[i].Type = PlayerWeapon(id)
+
+		if p.Weapons[i].Projectiles, err = decodeProjectiles(r); err != nil {
+			return
+		}
 	}
+	return
+}
 
-	if err = w.WriteFloat64(p.blade.collider.Rotation); err != nil {
+// decodeProjectiles reads the projectiles of a weapon section.
+func decodeProjectiles(r codec.Reader) (projectiles []ProjectileInfo, err error) {
+	var length int
+	if length, err = ReadCount(r, 16+16+16); err != nil {
 		return
 	}
 
+	projectiles = make([]ProjectileInfo, length)
+	for i := range projectiles {
+		var id []byte
+		if id, err = r.ReadBytes(16); err != nil {
+			return
+		}
+		copy(projectiles[i].Uuid[:], id)
+
+		if err = projectiles[i].Pos.Decode(r); err != nil {
+			return
+		}
+
+		if err = projectiles[i].Dest.Decode(r); err != nil {
+			return
+		}
+	}
 	return
 }
 
+// playerInfoMinSize is the size of the smallest player of a MessageGameState: an empty
+// nickname, the color, health, score and position, without destination.
+const playerInfoMinSize = 1 + 4 + 4 + 8 + 16 + 1
+
 func (p *PlayerInfo) Decode(r codec.Reader) (err error) {
 	if p.Nickname, err = r.ReadString(); err != nil {
 		return
@@ -382,32 +982,10 @@ func (p *PlayerInfo) Decode(r codec.Reader) (err error) {
 	}
 	p.CurrentWeapon = PlayerWeapon(currentWeapon)
 
-	var length int32
-	if length, err = r.ReadInt32(); err != nil {
+	if p.Projectiles, err = decodeProjectiles(r); err != nil {
 		return
 	}
 
-	p.Projectiles = make([]struct {
-		Uuid [16]byte
-		Pos  Point
-		Dest Point
-	}, length)
-	for i := 0; i < int(length); i++ {
-		var id []byte
-		if id, err = r.ReadBytes(16); err != nil {
-			return
-		}
-		copy(p.Projectiles[i].Uuid[:], id)
-
-		if err = p.Projectiles[i].Pos.Decode(r); err != nil {
-			return
-		}
-
-		if err = p.Projectiles[i].Dest.Decode(r); err != nil {
-			return
-		}
-	}
-
 	// decode Blade
 	p.Blade.Start = Point{}
 	if err = p.Blade.Start.Decode(r); err != nil {
@@ -432,6 +1010,16 @@ type Client struct {
 	connection Connection
 	blind      bool
 	mu         sync.RWMutex
+
+	// ack is the sequence of the last snapshot acknowledged by the client, 0 if the
+	// client never acknowledged a snapshot and therefore receives full game states.
+	ack uint32
+
+	// actionEncoding is the encoding of the actions sent by the client.
+	actionEncoding ActionEncoding
+
+	// hello is the protocol negotiated with the client, zero until it sends a MessageHello.
+	hello Hello
 }
 
 func (c *Client) GetConnection() Connection {
@@ -449,6 +1037,81 @@ func (c *Client) SetConnection(conn Connection) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.connection = conn
+	c.ack = 0
+	c.actionEncoding = ActionEncodingJSON
+	c.hello = Hello{}
+}
+
+// SetProtocol sets the protocol negotiated with the client.
+func (c *Client) SetProtocol(hello Hello) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hello = hello
+	if hello.Capabilities&CapabilityBinaryActions != 0 {
+		c.actionEncoding = ActionEncodingBinary
+	}
+}
+
+// ProtocolVersion returns the version of the protocol spoken by the client,
+// ProtocolVersion1 when it never sent a MessageHello.
+func (c *Client) ProtocolVersion() uint16 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.hello.Version == 0 {
+		return ProtocolVersion1
+	}
+	return c.hello.Version
+}
+
+// Supports returns true if the client may use the capability. Clients which never sent
+// a MessageHello may use every capability.
+func (c *Client) Supports(capability Capability) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hello.Version == 0 || c.hello.Capabilities&capability != 0
+}
+
+// Announced returns true if the client announced the capability in its MessageHello.
+// Unlike Supports, it is false for the clients which never sent one.
+func (c *Client) Announced(capability Capability) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hello.Capabilities&capability != 0
+}
+
+// Acknowledge records that the client received the snapshot with the given sequence.
+func (c *Client) Acknowledge(sequence uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sequence > c.ack {
+		c.ack = sequence
+	}
+}
+
+// Acknowledged returns the sequence of the last snapshot acknowledged by the client.
+func (c *Client) Acknowledged() uint32 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ack
+}
+
+// SetActionEncoding sets the encoding of the actions sent by the client. Unknown
+// encodings are ignored.
+func (c *Client) SetActionEncoding(encoding ActionEncoding) {
+	if encoding != ActionEncodingJSON && encoding != ActionEncodingBinary {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.actionEncoding = encoding
+}
+
+// ActionEncoding returns the encoding of the actions sent by the client.
+func (c *Client) ActionEncoding() ActionEncoding {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.actionEncoding
 }
 
 func (c *Client) IsBlind() bool {
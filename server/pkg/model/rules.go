@@ -0,0 +1,370 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/capucinoxx/jdis-games-2024/consts"
+)
+
+// GameRules holds the tunable rules of a game. The defaults are the values of the
+// consts package, any field missing from a rules file keeps its default value.
+type GameRules struct {
+	// Tickrate defines the number of ticks per second.
+	Tickrate int `json:"tickrate"`
+
+	// TicksPerRound defines the number of ticks per round.
+	TicksPerRound int `json:"ticks_per_round"`
+
+	// TicksPointRushStage defines the tick at which the point rush stage starts.
+	TicksPointRushStage int `json:"ticks_point_rush_stage"`
+
+	// MapWidth defines the width of the map in cells.
+	MapWidth int `json:"map_width"`
+
+	// MapGenerator is the name of the algorithm generating the map, empty for the
+	// default one.
+	MapGenerator string `json:"map_generator,omitempty"`
+
+	// MapLayout is a hand-authored map played instead of a generated one. Its size
+	// must be MapWidth.
+	MapLayout *MapLayout `json:"map_layout,omitempty"`
+
+	// PlayerHealth is the starting health of a player.
+	PlayerHealth int `json:"player_health"`
+
+	// PlayerSpeed defines the distance traveled by a player per second.
+	PlayerSpeed float64 `json:"player_speed"`
+
+	// RespawnTime defines the time (in seconds) for a player to respawn after being eliminated.
+	RespawnTime float64 `json:"respawn_time"`
+
+	// ProjectileSize defines the size of a projectile.
+	ProjectileSize float64 `json:"projectile_size"`
+
+	// ProjectileDmg defines the damage suffered by a player when hit by a projectile.
+	ProjectileDmg int `json:"projectile_dmg"`
+
+	// ProjectileSpeed defines the distance traveled by a projectile per second.
+	ProjectileSpeed float64 `json:"projectile_speed"`
+
+	// ProjectileTTL defines the time to live of a projectile (in seconds).
+	ProjectileTTL float64 `json:"projectile_ttl"`
+
+	// MaxProjectiles defines the number of projectiles, pellets, mines and grenades
+	// included, a player can have in the game at the same time.
+	MaxProjectiles int `json:"max_projectiles"`
+
+	// CannonCooldown defines the time (in seconds) between two shots of a cannon.
+	CannonCooldown float64 `json:"cannon_cooldown"`
+
+	// CannonMagazine defines the number of shots of a cannon before it reloads, 0 for
+	// no magazine.
+	CannonMagazine int `json:"cannon_magazine"`
+
+	// CannonReloadTime defines the time (in seconds) a cannon takes to reload.
+	CannonReloadTime float64 `json:"cannon_reload_time"`
+
+	// BladeDmg defines the damage suffered by a player when hit by a blade.
+	BladeDmg int `json:"blade_dmg"`
+
+	// BladeRotationSpeed defines the speed of rotation of a blade (in degrees per second).
+	BladeRotationSpeed float64 `json:"blade_rotation_speed"`
+
+	// ShotgunPellets defines the number of pellets fired by a shotgun.
+	ShotgunPellets int `json:"shotgun_pellets"`
+
+	// ShotgunSpread defines the angle (in degrees) over which the pellets are spread.
+	ShotgunSpread float64 `json:"shotgun_spread"`
+
+	// ShotgunRange defines the distance traveled by a pellet.
+	ShotgunRange float64 `json:"shotgun_range"`
+
+	// ShotgunDmg defines the damage suffered by a player when hit by a pellet.
+	ShotgunDmg int `json:"shotgun_dmg"`
+
+	// ShotgunCooldown defines the time (in seconds) between two shots of a shotgun.
+	ShotgunCooldown float64 `json:"shotgun_cooldown"`
+
+	// ShotgunMagazine defines the number of shots of a shotgun before it reloads, 0 for
+	// no magazine.
+	ShotgunMagazine int `json:"shotgun_magazine"`
+
+	// ShotgunReloadTime defines the time (in seconds) a shotgun takes to reload.
+	ShotgunReloadTime float64 `json:"shotgun_reload_time"`
+
+	// MineRadius defines the distance at which an enemy triggers a mine, and the radius
+	// of its explosion.
+	MineRadius float64 `json:"mine_radius"`
+
+	// MineDmg defines the damage suffered by a player caught in the explosion of a mine.
+	MineDmg int `json:"mine_dmg"`
+
+	// MineTTL defines the time to live of a mine (in seconds).
+	MineTTL float64 `json:"mine_ttl"`
+
+	// MaxMines defines the number of mines a player can have at the same time.
+	MaxMines int `json:"max_mines"`
+
+	// MineCooldown defines the time (in seconds) between two mines dropped.
+	MineCooldown float64 `json:"mine_cooldown"`
+
+	// GrenadeRange defines the farthest a grenade can be thrown.
+	GrenadeRange float64 `json:"grenade_range"`
+
+	// GrenadeRadius defines the radius of the explosion of a grenade.
+	GrenadeRadius float64 `json:"grenade_radius"`
+
+	// GrenadeDmg defines the damage suffered by a player caught in the explosion of a grenade.
+	GrenadeDmg int `json:"grenade_dmg"`
+
+	// GrenadeCooldown defines the time (in seconds) between two grenades thrown.
+	GrenadeCooldown float64 `json:"grenade_cooldown"`
+
+	// CoinSize defines the size of a coin.
+	CoinSize float64 `json:"coin_size"`
+
+	// CoinValue defines the value when a player collects a coin.
+	CoinValue int32 `json:"coin_value"`
+
+	// NumCoins defines the number of coins available during the discovery stage.
+	NumCoins int `json:"num_coins"`
+
+	// BigCoinSize defines the size of the big coin of the point rush stage.
+	BigCoinSize float64 `json:"big_coin_size"`
+
+	// BigCoinValue defines the value when a player collects the big coin.
+	BigCoinValue int32 `json:"big_coin_value"`
+
+	// PowerUpChance defines the probability (between 0 and 1) that a collected coin is
+	// replaced by a power-up rather than another coin. 0 disables the power-ups.
+	PowerUpChance float64 `json:"power_up_chance"`
+
+	// PowerUpDuration defines the time (in seconds) the effect of a power-up lasts.
+	PowerUpDuration float64 `json:"power_up_duration"`
+
+	// SpeedBoost defines the factor applied to the speed of a player under a speed boost.
+	SpeedBoost float64 `json:"speed_boost"`
+
+	// HealthPack defines the health restored by a health pack.
+	HealthPack int `json:"health_pack"`
+
+	// RapidFire defines the factor applied to the rate of fire of a player under rapid fire.
+	RapidFire float64 `json:"rapid_fire"`
+
+	// ScoreOnHitWithProjectile defines the score awarded when hitting an opponent with a projectile.
+	ScoreOnHitWithProjectile int `json:"score_on_hit_with_projectile"`
+
+	// ScoreOnHitWithBlade defines the score awarded when hitting an opponent with a blade.
+	ScoreOnHitWithBlade int `json:"score_on_hit_with_blade"`
+
+	// ScoreOnHitWithShotgun defines the score awarded when hitting an opponent with a pellet.
+	ScoreOnHitWithShotgun int `json:"score_on_hit_with_shotgun"`
+
+	// ScoreOnHitWithExplosion defines the score awarded when an opponent is caught in the
+	// explosion of a mine or a grenade.
+	ScoreOnHitWithExplosion int `json:"score_on_hit_with_explosion"`
+
+	// ScoreOnKill defines the score awarded when eliminating an opponent. A player
+	// eliminated without an attacker credits the last opponent who hit it.
+	ScoreOnKill int `json:"score_on_kill"`
+
+	// DeathScoreDrop defines the share of its score (between 0 and 1) a player loses when
+	// eliminated. The score lost is dropped as coins where the player died.
+	DeathScoreDrop float64 `json:"death_score_drop"`
+
+	// FogOfWar limits the game state sent to each player to what it can see. Spectators
+	// still see everything.
+	FogOfWar bool `json:"fog_of_war"`
+
+	// VisionRadius defines how far a player sees when the fog of war is enabled, 0 for
+	// no limit other than the walls.
+	VisionRadius float64 `json:"vision_radius"`
+
+	// ClosingZone closes a safe zone on the big coin during the point rush stage. The
+	// players outside it are damaged every tick.
+	ClosingZone bool `json:"closing_zone"`
+
+	// ZoneEndRadius defines the radius of the safe zone at the end of the point rush stage.
+	ZoneEndRadius float64 `json:"zone_end_radius"`
+
+	// ZoneDmg defines the damage suffered every tick by a player outside the safe zone.
+	ZoneDmg int `json:"zone_dmg"`
+
+	// TeamSize defines the largest number of players in a team, 2 for 2v2 brackets and
+	// 4 for 4v4 ones. 0 means no limit.
+	TeamSize int `json:"team_size"`
+
+	// FriendlyFire allows the players to damage their teammates. Hitting a teammate
+	// never scores.
+	FriendlyFire bool `json:"friendly_fire"`
+}
+
+// mapGenerators holds the names of the map generators available.
+var mapGenerators = make(map[string]bool)
+
+// RegisterMapGenerator makes a map generator available to the rules. It is called by
+// the map implementations when they are initialized.
+func RegisterMapGenerator(name string) {
+	mapGenerators[name] = true
+}
+
+// maxMapWidth is the largest map supported, the size of the discrete map being sent
+// to the clients on a single signed byte.
+const maxMapWidth = 127
+
+// DefaultGameRules returns the rules of a standard game.
+func DefaultGameRules() *GameRules {
+	return &GameRules{
+		Tickrate:                 consts.Tickrate,
+		TicksPerRound:            consts.TicksPerRound,
+		TicksPointRushStage:      consts.TicksPointRushStage,
+		MapWidth:                 consts.MapWidth,
+		PlayerHealth:             consts.PlayerHealth,
+		PlayerSpeed:              consts.PlayerSpeed,
+		RespawnTime:              consts.RespawnTime,
+		ProjectileSize:           consts.ProjectileSize,
+		ProjectileDmg:            consts.ProjectileDmg,
+		ProjectileSpeed:          consts.ProjectileSpeed,
+		ProjectileTTL:            consts.ProjectileTTL,
+		MaxProjectiles:           consts.MaxProjectiles,
+		CannonCooldown:           consts.CannonCooldown,
+		CannonMagazine:           consts.CannonMagazine,
+		CannonReloadTime:         consts.CannonReloadTime,
+		BladeDmg:                 consts.BladeDmg,
+		BladeRotationSpeed:       consts.BladeRotationSpeed,
+		ShotgunPellets:           consts.ShotgunPellets,
+		ShotgunSpread:            consts.ShotgunSpread,
+		ShotgunRange:             consts.ShotgunRange,
+		ShotgunDmg:               consts.ShotgunDmg,
+		ShotgunCooldown:          consts.ShotgunCooldown,
+		ShotgunMagazine:          consts.ShotgunMagazine,
+		ShotgunReloadTime:        consts.ShotgunReloadTime,
+		MineRadius:               consts.MineRadius,
+		MineDmg:                  consts.MineDmg,
+		MineTTL:                  consts.MineTTL,
+		MaxMines:                 consts.MaxMines,
+		MineCooldown:             consts.MineCooldown,
+		GrenadeRange:             consts.GrenadeRange,
+		GrenadeRadius:            consts.GrenadeRadius,
+		GrenadeDmg:               consts.GrenadeDmg,
+		GrenadeCooldown:          consts.GrenadeCooldown,
+		CoinSize:                 consts.CoinSize,
+		CoinValue:                consts.CoinValue,
+		NumCoins:                 consts.NumCoins,
+		BigCoinSize:              consts.BigCoinSize,
+		BigCoinValue:             consts.BigCoinValue,
+		PowerUpChance:            consts.PowerUpChance,
+		PowerUpDuration:          consts.PowerUpDuration,
+		SpeedBoost:               consts.SpeedBoost,
+		HealthPack:               consts.HealthPack,
+		RapidFire:                consts.RapidFire,
+		ScoreOnHitWithProjectile: consts.ScoreOnHitWithProjectile,
+		ScoreOnHitWithBlade:      consts.ScoreOnHitWithBlade,
+		ScoreOnHitWithShotgun:    consts.ScoreOnHitWithShotgun,
+		ScoreOnHitWithExplosion:  consts.ScoreOnHitWithExplosion,
+		ScoreOnKill:              consts.ScoreOnKill,
+		ZoneEndRadius:            consts.ZoneEndRadius,
+		ZoneDmg:                  consts.ZoneDmg,
+	}
+}
+
+// LoadGameRules reads rules from the JSON file at path.
+func LoadGameRules(path string) (*GameRules, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodeGameRules(file, DefaultGameRules())
+}
+
+// DecodeGameRules reads JSON rules from r on top of base, so that only the fields
+// present in the document are changed. base is not modified.
+func DecodeGameRules(r io.Reader, base *GameRules) (*GameRules, error) {
+	rules := *base
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, err
+	}
+
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// Validate returns an error if the rules cannot be used to play a game.
+func (r *GameRules) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(r.Tickrate > 0 && r.Tickrate <= 1000, "tickrate must be between 1 and 1000")
+	check(r.TicksPerRound > 0, "ticks_per_round must be positive")
+	check(r.TicksPointRushStage > 0 && r.TicksPointRushStage < r.TicksPerRound,
+		"ticks_point_rush_stage must be between 1 and ticks_per_round")
+	check(r.MapWidth >= 2 && r.MapWidth <= maxMapWidth, "map_width must be between 2 and %d", maxMapWidth)
+	check(r.PlayerHealth > 0, "player_health must be positive")
+	check(r.PlayerSpeed > 0, "player_speed must be positive")
+	check(r.RespawnTime >= 0, "respawn_time must not be negative")
+	check(r.ProjectileSize > 0, "projectile_size must be positive")
+	check(r.ProjectileDmg >= 0, "projectile_dmg must not be negative")
+	check(r.ProjectileSpeed > 0, "projectile_speed must be positive")
+	check(r.ProjectileTTL > 0, "projectile_ttl must be positive")
+	check(r.MaxProjectiles > 0, "max_projectiles must be positive")
+	check(r.CannonCooldown >= 0, "cannon_cooldown must not be negative")
+	check(r.CannonMagazine >= 0, "cannon_magazine must not be negative")
+	check(r.CannonReloadTime >= 0, "cannon_reload_time must not be negative")
+	check(r.BladeDmg >= 0, "blade_dmg must not be negative")
+	check(r.BladeRotationSpeed > 0, "blade_rotation_speed must be positive")
+	check(r.ShotgunPellets > 0, "shotgun_pellets must be positive")
+	check(r.ShotgunSpread >= 0 && r.ShotgunSpread <= 360, "shotgun_spread must be between 0 and 360")
+	check(r.ShotgunRange > 0, "shotgun_range must be positive")
+	check(r.ShotgunDmg >= 0, "shotgun_dmg must not be negative")
+	check(r.ShotgunCooldown >= 0, "shotgun_cooldown must not be negative")
+	check(r.ShotgunMagazine >= 0, "shotgun_magazine must not be negative")
+	check(r.ShotgunReloadTime >= 0, "shotgun_reload_time must not be negative")
+	check(r.ShotgunPellets <= r.MaxProjectiles, "shotgun_pellets must not be greater than max_projectiles")
+	check(r.MineRadius > 0, "mine_radius must be positive")
+	check(r.MineDmg >= 0, "mine_dmg must not be negative")
+	check(r.MineTTL > 0, "mine_ttl must be positive")
+	check(r.MaxMines > 0, "max_mines must be positive")
+	check(r.MineCooldown >= 0, "mine_cooldown must not be negative")
+	check(r.GrenadeRange > 0, "grenade_range must be positive")
+	check(r.GrenadeRadius > 0, "grenade_radius must be positive")
+	check(r.GrenadeDmg >= 0, "grenade_dmg must not be negative")
+	check(r.GrenadeCooldown >= 0, "grenade_cooldown must not be negative")
+	check(r.CoinSize > 0, "coin_size must be positive")
+	check(r.NumCoins > 1, "num_coins must be greater than 1")
+	check(r.BigCoinSize > 0, "big_coin_size must be positive")
+	check(r.PowerUpChance >= 0 && r.PowerUpChance <= 1, "power_up_chance must be between 0 and 1")
+	check(r.PowerUpDuration >= 0, "power_up_duration must not be negative")
+	check(r.SpeedBoost >= 1, "speed_boost must be at least 1")
+	check(r.HealthPack >= 0, "health_pack must not be negative")
+	check(r.RapidFire >= 1, "rapid_fire must be at least 1")
+	check(r.VisionRadius >= 0, "vision_radius must not be negative")
+	check(r.ZoneEndRadius >= 0, "zone_end_radius must not be negative")
+	check(r.ZoneDmg >= 0, "zone_dmg must not be negative")
+	check(r.ScoreOnKill >= 0, "score_on_kill must not be negative")
+	check(r.DeathScoreDrop >= 0 && r.DeathScoreDrop <= 1, "death_score_drop must be between 0 and 1")
+	check(r.TeamSize >= 0, "team_size must not be negative")
+	check(r.MapGenerator == "" || mapGenerators[r.MapGenerator], "unknown map_generator %q", r.MapGenerator)
+	if r.MapLayout != nil {
+		check(r.MapLayout.Size == r.MapWidth, "map_width must be the size of the map_layout")
+		if err := r.MapLayout.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid map_layout: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeGameRules(t *testing.T) {
+	tests := map[string]struct {
+		document string
+		wantErr  bool
+		check    func(rules *GameRules) bool
+	}{
+		"Empty document keeps the base rules": {
+			document: `{}`,
+			check: func(rules *GameRules) bool {
+				return *rules == *DefaultGameRules()
+			},
+		},
+		"Only the given rules change": {
+			document: `{"map_width": 20, "projectile_speed": 6.5}`,
+			check: func(rules *GameRules) bool {
+				want := DefaultGameRules()
+				want.MapWidth = 20
+				want.ProjectileSpeed = 6.5
+				return *rules == *want
+			},
+		},
+		"Unknown rule": {
+			document: `{"gravity": 9.81}`,
+			wantErr:  true,
+		},
+		"Invalid rule": {
+			document: `{"tickrate": 0}`,
+			wantErr:  true,
+		},
+		"Point rush after the end of the round": {
+			document: `{"ticks_per_round": 100, "ticks_point_rush_stage": 200}`,
+			wantErr:  true,
+		},
+		"Map too large": {
+			document: `{"map_width": 500}`,
+			wantErr:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			base := DefaultGameRules()
+			rules, err := DecodeGameRules(strings.NewReader(tt.document), base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeGameRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if *base != *DefaultGameRules() {
+				t.Errorf("DecodeGameRules() modified the base rules")
+			}
+
+			if tt.check != nil && !tt.check(rules) {
+				t.Errorf("DecodeGameRules() = %+v", rules)
+			}
+		})
+	}
+}
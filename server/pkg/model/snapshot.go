@@ -0,0 +1,1026 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
+)
+
+// PositionScale is the number of quantization steps per map unit. Positions are sent
+// as int16, which covers maps of up to 1638 units with a precision of 0.05 unit.
+const PositionScale = 20
+
+// QuantizedPoint is a position rounded to 1/PositionScale of a unit.
+type QuantizedPoint struct {
+	X, Y int16
+}
+
+// Quantize rounds a position to the precision sent to the clients. Positions outside
+// of the range of int16 are clamped.
+func Quantize(p Point) QuantizedPoint {
+	return QuantizedPoint{X: quantize(p.X), Y: quantize(p.Y)}
+}
+
+// Point returns the position represented by the quantized point.
+func (q QuantizedPoint) Point() Point {
+	return Point{X: float64(q.X) / PositionScale, Y: float64(q.Y) / PositionScale}
+}
+
+func (q QuantizedPoint) Encode(w codec.Writer) (err error) {
+	if err = w.WriteInt16(q.X); err != nil {
+		return
+	}
+	return w.WriteInt16(q.Y)
+}
+
+func (q *QuantizedPoint) Decode(r codec.Reader) (err error) {
+	if q.X, err = r.ReadInt16(); err != nil {
+		return
+	}
+	q.Y, err = r.ReadInt16()
+	return
+}
+
+func quantize(v float64) int16 {
+	return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(v*PositionScale))))
+}
+
+// quantizeAngle maps an angle in radians to [0, 65536).
+func quantizeAngle(theta float64) uint16 {
+	turns := math.Mod(theta/(2*math.Pi), 1)
+	if turns < 0 {
+		turns++
+	}
+	return uint16(uint32(math.Round(turns*65536)) % 65536)
+}
+
+// PlayerSnapshot is the state of a player sent to the clients.
+type PlayerSnapshot struct {
+	Nickname      string
+	Color         int32
+	Team          string
+	Health        int32
+	Score         int32
+	Pos           QuantizedPoint
+	HasDest       bool
+	Dest          QuantizedPoint
+	CurrentWeapon PlayerWeapon
+	BladeStart    QuantizedPoint
+	BladeEnd      QuantizedPoint
+	BladeRotation uint16
+
+	// LastSequence and LastSequenceTick are the last action processed and its tick.
+	LastSequence     uint32
+	LastSequenceTick int32
+
+	// Triggers is the state of the triggers of the weapons of the player.
+	Triggers []TriggerState
+
+	// Effects is the state of the effects active on the player.
+	Effects []EffectState
+}
+
+// ProjectileSnapshot is the state of a projectile sent to the clients.
+type ProjectileSnapshot struct {
+	Owner  uint16
+	Weapon PlayerWeapon
+	Pos    QuantizedPoint
+	Dest   QuantizedPoint
+}
+
+// CoinSnapshot is the state of a coin sent to the clients.
+type CoinSnapshot struct {
+	Pos    QuantizedPoint
+	Value  int32
+	Effect PlayerEffect
+}
+
+// Snapshot is the state of the game at a broadcast. Entities are identified by small
+// ids which stay the same from one snapshot to the next, so that a snapshot can be sent
+// as the difference with a previous one.
+type Snapshot struct {
+	Sequence     uint32
+	CurrentTick  int32
+	CurrentRound int8
+	Players      map[uint16]PlayerSnapshot
+	Projectiles  map[uint16]ProjectileSnapshot
+	Coins        map[uint16]CoinSnapshot
+
+	// Zone is the safe zone, nil when there is none.
+	Zone *ZoneSnapshot
+}
+
+func newSnapshot() *Snapshot {
+	return &Snapshot{
+		Players:     make(map[uint16]PlayerSnapshot),
+		Projectiles: make(map[uint16]ProjectileSnapshot),
+		Coins:       make(map[uint16]CoinSnapshot),
+	}
+}
+
+// SnapshotHistory is the number of previous snapshots a delta can be based on.
+const SnapshotHistory = 32
+
+// SnapshotBuilder captures snapshots of the game and assigns the ids of the entities.
+type SnapshotBuilder struct {
+	sequence  uint32
+	nextID    uint16
+	playerIDs map[string]uint16
+	objectIDs map[[16]byte]uint16
+
+	// history holds the ids of the entities of the last SnapshotHistory snapshots, by
+	// sequence, and retained counts the snapshots of the history holding each id. These
+	// ids cannot be given to a new entity, since a delta based on one of the snapshots
+	// would merge the new entity with the old one.
+	history  [SnapshotHistory][]uint16
+	retained map[uint16]int
+
+	// used holds the ids allocated during the current capture.
+	used map[uint16]bool
+}
+
+// NewSnapshotBuilder creates a new SnapshotBuilder.
+func NewSnapshotBuilder() *SnapshotBuilder {
+	return &SnapshotBuilder{
+		playerIDs: make(map[string]uint16),
+		objectIDs: make(map[[16]byte]uint16),
+		retained:  make(map[uint16]int),
+	}
+}
+
+// Capture takes a snapshot of the given players and coins. Sequences start at 1, 0
+// meaning no snapshot.
+func (b *SnapshotBuilder) Capture(players []*Player, coins []*Scorer, tick int32, round int8) *Snapshot {
+	b.sequence++
+	s := newSnapshot()
+	s.Sequence = b.sequence
+	s.CurrentTick = tick
+	s.CurrentRound = round
+
+	b.used = make(map[uint16]bool)
+
+	playerIDs := make(map[string]uint16, len(players))
+	objectIDs := make(map[[16]byte]uint16)
+
+	for _, p := range players {
+		id := entityID(b, p.Nickname, b.playerIDs, playerIDs)
+		s.Players[id] = p.snapshot()
+
+		for _, t := range weaponTypes {
+			weapon, ok := p.weapons[t.ID]
+			if !ok {
+				continue
+			}
+
+			for _, projectile := range weapon.Fired() {
+				s.Projectiles[entityID(b, projectile.uuid, b.objectIDs, objectIDs)] = ProjectileSnapshot{
+					Owner:  id,
+					Weapon: t.ID,
+					Pos:    Quantize(*projectile.Position),
+					Dest:   Quantize(*projectile.Destination),
+				}
+			}
+		}
+	}
+
+	for _, c := range coins {
+		s.Coins[entityID(b, c.uuid, b.objectIDs, objectIDs)] = CoinSnapshot{
+			Pos:    Quantize(*c.Position),
+			Value:  c.Value,
+			Effect: c.Effect,
+		}
+	}
+
+	// Only the entities still present keep their id, the others are forgotten.
+	b.playerIDs = playerIDs
+	b.objectIDs = objectIDs
+	b.retain(s)
+	return s
+}
+
+// retain adds the ids of the snapshot to the history, in place of the ones of the
+// snapshot SnapshotHistory sequences older.
+func (b *SnapshotBuilder) retain(s *Snapshot) {
+	slot := &b.history[s.Sequence%SnapshotHistory]
+	for _, id := range *slot {
+		if b.retained[id]--; b.retained[id] == 0 {
+			delete(b.retained, id)
+		}
+	}
+
+	ids := make([]uint16, 0, len(b.playerIDs)+len(b.objectIDs))
+	for _, id := range b.playerIDs {
+		ids = append(ids, id)
+	}
+	for _, id := range b.objectIDs {
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		b.retained[id]++
+	}
+	*slot = ids
+}
+
+// Filter returns the part of the snapshot visible to the viewer of v, with the same
+// sequence. The viewer always sees itself and its projectiles.
+func (s *Snapshot) Filter(v *Visibility) *Snapshot {
+	if v == nil {
+		return s
+	}
+
+	filtered := newSnapshot()
+	filtered.Sequence = s.Sequence
+	filtered.CurrentTick = s.CurrentTick
+	filtered.CurrentRound = s.CurrentRound
+	filtered.Zone = s.Zone
+
+	viewer := uint16(0)
+	for id, p := range s.Players {
+		if p.Nickname == v.Viewer().Nickname {
+			viewer = id
+		}
+	}
+
+	for id, p := range s.Players {
+		if id == viewer || v.CanSee(p.Pos.Point()) {
+			filtered.Players[id] = p
+		}
+	}
+
+	for id, p := range s.Projectiles {
+		if p.Owner == viewer || v.CanSee(p.Pos.Point()) {
+			filtered.Projectiles[id] = p
+		}
+	}
+
+	for id, c := range s.Coins {
+		if v.CanSee(c.Pos.Point()) {
+			filtered.Coins[id] = c
+		}
+	}
+
+	return filtered
+}
+
+// entityID returns the id of the entity with the given key, allocating one if the
+// entity is new. The id is kept in next.
+func entityID[K comparable](b *SnapshotBuilder, key K, current, next map[K]uint16) uint16 {
+	id, ok := current[key]
+	if !ok {
+		id = b.allocate()
+	}
+	next[key] = id
+	return id
+}
+
+// allocate returns the next free id. Once the ids wrap, 0 and the ids of the entities
+// of the snapshots in the history are skipped.
+func (b *SnapshotBuilder) allocate() uint16 {
+	for {
+		b.nextID++
+		if b.nextID != 0 && !b.used[b.nextID] && b.retained[b.nextID] == 0 {
+			b.used[b.nextID] = true
+			return b.nextID
+		}
+	}
+}
+
+func (p *Player) snapshot() PlayerSnapshot {
+	s := PlayerSnapshot{
+		Nickname:      p.Nickname,
+		Color:         int32(p.Color),
+		Team:          p.Team,
+		Health:        int32(p.health),
+		Score:         int32(p.score),
+		Pos:           Quantize(*p.Position),
+		CurrentWeapon: p.currentWeapon,
+	}
+
+	if blade := p.blade(); blade != nil {
+		s.BladeStart = Quantize(*blade.collider.rect.a)
+		s.BladeEnd = Quantize(*blade.collider.rect.b)
+		s.BladeRotation = quantizeAngle(blade.collider.Rotation)
+	}
+
+	if p.Controls.Dest != nil {
+		s.HasDest = true
+		s.Dest = Quantize(*p.Controls.Dest)
+	}
+
+	s.LastSequence, s.LastSequenceTick = p.LastSequence()
+	s.Triggers = p.triggers()
+	s.Effects = p.effectStates()
+	return s
+}
+
+// Fields of a player which changed since the base snapshot.
+const (
+	PlayerDeltaInfo uint16 = 1 << iota
+	PlayerDeltaHealth
+	PlayerDeltaScore
+	PlayerDeltaPosition
+	PlayerDeltaDestination
+	PlayerDeltaWeapon
+	PlayerDeltaBlade
+	PlayerDeltaSequence
+	PlayerDeltaTriggers
+	PlayerDeltaEffects
+
+	playerDeltaAll = PlayerDeltaInfo | PlayerDeltaHealth | PlayerDeltaScore | PlayerDeltaPosition |
+		PlayerDeltaDestination | PlayerDeltaWeapon | PlayerDeltaBlade | PlayerDeltaSequence |
+		PlayerDeltaTriggers | PlayerDeltaEffects
+)
+
+// deltaMask returns the fields of p which differ from base.
+func (p PlayerSnapshot) deltaMask(base PlayerSnapshot) (mask uint16) {
+	if p.Nickname != base.Nickname || p.Color != base.Color || p.Team != base.Team {
+		mask |= PlayerDeltaInfo
+	}
+	if p.Health != base.Health {
+		mask |= PlayerDeltaHealth
+	}
+	if p.Score != base.Score {
+		mask |= PlayerDeltaScore
+	}
+	if p.Pos != base.Pos {
+		mask |= PlayerDeltaPosition
+	}
+	if p.HasDest != base.HasDest || p.Dest != base.Dest {
+		mask |= PlayerDeltaDestination
+	}
+	if p.CurrentWeapon != base.CurrentWeapon {
+		mask |= PlayerDeltaWeapon
+	}
+	if p.BladeStart != base.BladeStart || p.BladeEnd != base.BladeEnd || p.BladeRotation != base.BladeRotation {
+		mask |= PlayerDeltaBlade
+	}
+	if p.LastSequence != base.LastSequence || p.LastSequenceTick != base.LastSequenceTick {
+		mask |= PlayerDeltaSequence
+	}
+	if !slices.Equal(p.Triggers, base.Triggers) {
+		mask |= PlayerDeltaTriggers
+	}
+	if !slices.Equal(p.Effects, base.Effects) {
+		mask |= PlayerDeltaEffects
+	}
+	return
+}
+
+func (p PlayerSnapshot) encode(w codec.Writer, mask uint16, version uint16) (err error) {
+	if mask&PlayerDeltaInfo != 0 {
+		if err = w.WriteString(p.Nickname); err != nil {
+			return
+		}
+		if err = w.WriteInt32(p.Color); err != nil {
+			return
+		}
+		if version >= ProtocolVersion2 {
+			if err = w.WriteString(p.Team); err != nil {
+				return
+			}
+		}
+	}
+
+	if mask&PlayerDeltaHealth != 0 {
+		if err = w.WriteInt32(p.Health); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaScore != 0 {
+		if err = w.WriteInt32(p.Score); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaPosition != 0 {
+		if err = p.Pos.Encode(w); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaDestination != 0 {
+		if err = w.WriteBool(p.HasDest); err != nil {
+			return
+		}
+		if p.HasDest {
+			if err = p.Dest.Encode(w); err != nil {
+				return
+			}
+		}
+	}
+
+	if mask&PlayerDeltaWeapon != 0 {
+		if err = w.WriteUint8(uint8(p.CurrentWeapon)); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaBlade != 0 {
+		if err = p.BladeStart.Encode(w); err != nil {
+			return
+		}
+		if err = p.BladeEnd.Encode(w); err != nil {
+			return
+		}
+		if err = w.WriteUint16(p.BladeRotation); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaSequence != 0 {
+		if err = w.WriteUint32(p.LastSequence); err != nil {
+			return
+		}
+		if err = w.WriteInt32(p.LastSequenceTick); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaTriggers != 0 {
+		if err = encodeTriggers(w, p.Triggers); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaEffects != 0 {
+		if err = encodeEffects(w, p.Effects); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// decode reads the fields in mask on top of p.
+func (p *PlayerSnapshot) decode(r codec.Reader, mask uint16, version uint16) (err error) {
+	if mask&PlayerDeltaInfo != 0 {
+		if p.Nickname, err = r.ReadString(); err != nil {
+			return
+		}
+		if p.Color, err = r.ReadInt32(); err != nil {
+			return
+		}
+		if version >= ProtocolVersion2 {
+			if p.Team, err = r.ReadString(); err != nil {
+				return
+			}
+		}
+	}
+
+	if mask&PlayerDeltaHealth != 0 {
+		if p.Health, err = r.ReadInt32(); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaScore != 0 {
+		if p.Score, err = r.ReadInt32(); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaPosition != 0 {
+		if err = p.Pos.Decode(r); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaDestination != 0 {
+		if p.HasDest, err = r.ReadBool(); err != nil {
+			return
+		}
+		p.Dest = QuantizedPoint{}
+		if p.HasDest {
+			if err = p.Dest.Decode(r); err != nil {
+				return
+			}
+		}
+	}
+
+	if mask&PlayerDeltaWeapon != 0 {
+		var weapon uint8
+		if weapon, err = r.ReadUint8(); err != nil {
+			return
+		}
+		p.CurrentWeapon = PlayerWeapon(weapon)
+	}
+
+	if mask&PlayerDeltaBlade != 0 {
+		if err = p.BladeStart.Decode(r); err != nil {
+			return
+		}
+		if err = p.BladeEnd.Decode(r); err != nil {
+			return
+		}
+		if p.BladeRotation, err = r.ReadUint16(); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaSequence != 0 {
+		if p.LastSequence, err = r.ReadUint32(); err != nil {
+			return
+		}
+		if p.LastSequenceTick, err = r.ReadInt32(); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaTriggers != 0 {
+		if p.Triggers, err = decodeTriggers(r); err != nil {
+			return
+		}
+	}
+
+	if mask&PlayerDeltaEffects != 0 {
+		if p.Effects, err = decodeEffects(r); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// writeDeltaMask writes the mask of the fields of a player, on a single byte before
+// ProtocolVersion2.
+func writeDeltaMask(w codec.Writer, mask uint16, version uint16) error {
+	if version < ProtocolVersion2 {
+		return w.WriteUint8(uint8(mask))
+	}
+	return w.WriteUint16(mask)
+}
+
+// readDeltaMask reads the mask written by writeDeltaMask.
+func readDeltaMask(r codec.Reader, version uint16) (uint16, error) {
+	if version < ProtocolVersion2 {
+		mask, err := r.ReadUint8()
+		return uint16(mask), err
+	}
+	return r.ReadUint16()
+}
+
+// sortedIDs returns the keys of m in increasing order, so that encoding is deterministic.
+func sortedIDs[T any](m map[uint16]T) []uint16 {
+	ids := make([]uint16, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// removedIDs returns the ids of base which are not in current.
+func removedIDs[T any](base, current map[uint16]T) []uint16 {
+	removed := []uint16{}
+	for _, id := range sortedIDs(base) {
+		if _, ok := current[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	return removed
+}
+
+func writeIDs(w codec.Writer, ids []uint16) (err error) {
+	if err = w.WriteUint16(uint16(len(ids))); err != nil {
+		return
+	}
+	for _, id := range ids {
+		if err = w.WriteUint16(id); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func readIDs(r codec.Reader) (ids []uint16, err error) {
+	var size uint16
+	if size, err = r.ReadUint16(); err != nil {
+		return
+	}
+
+	ids = make([]uint16, size)
+	for i := range ids {
+		if ids[i], err = r.ReadUint16(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// MessageGameStateDeltaToEncode is the difference between two snapshots.
+type MessageGameStateDeltaToEncode struct {
+	// Base is the snapshot known by the client, nil to send a keyframe.
+	Base    *Snapshot
+	Current *Snapshot
+
+	// Version is the protocol version of the recipients, 0 for the current one.
+	Version uint16
+}
+
+func (m *MessageGameStateDeltaToEncode) Encode(w codec.Writer) (err error) {
+	base := m.Base
+	if base == nil {
+		base = newSnapshot()
+	}
+	cur := m.Current
+
+	if err = w.WriteUint32(cur.Sequence); err != nil {
+		return
+	}
+
+	if err = w.WriteUint32(base.Sequence); err != nil {
+		return
+	}
+
+	if err = w.WriteInt32(cur.CurrentTick); err != nil {
+		return
+	}
+
+	if err = w.WriteInt8(cur.CurrentRound); err != nil {
+		return
+	}
+
+	changed := []uint16{}
+	masks := make(map[uint16]uint16)
+	for _, id := range sortedIDs(cur.Players) {
+		mask := playerDeltaAll
+		if prev, ok := base.Players[id]; ok {
+			mask = cur.Players[id].deltaMask(prev)
+		}
+		if protocolVersion(m.Version) < ProtocolVersion2 {
+			mask &^= PlayerDeltaSequence | PlayerDeltaTriggers | PlayerDeltaEffects
+		}
+		if mask != 0 {
+			changed = append(changed, id)
+			masks[id] = mask
+		}
+	}
+
+	if err = w.WriteUint16(uint16(len(changed))); err != nil {
+		return
+	}
+
+	for _, id := range changed {
+		if err = w.WriteUint16(id); err != nil {
+			return
+		}
+		if err = writeDeltaMask(w, masks[id], protocolVersion(m.Version)); err != nil {
+			return
+		}
+		if err = cur.Players[id].encode(w, masks[id], protocolVersion(m.Version)); err != nil {
+			return
+		}
+	}
+
+	if err = writeIDs(w, removedIDs(base.Players, cur.Players)); err != nil {
+		return
+	}
+
+	// Before ProtocolVersion2, only the projectiles of the cannons are sent.
+	baseProjectiles, curProjectiles := base.Projectiles, cur.Projectiles
+	if protocolVersion(m.Version) < ProtocolVersion2 {
+		baseProjectiles, curProjectiles = cannonProjectiles(baseProjectiles), cannonProjectiles(curProjectiles)
+	}
+
+	changed = changed[:0]
+	for _, id := range sortedIDs(curProjectiles) {
+		if prev, ok := baseProjectiles[id]; !ok || prev != curProjectiles[id] {
+			changed = append(changed, id)
+		}
+	}
+
+	if err = w.WriteUint16(uint16(len(changed))); err != nil {
+		return
+	}
+
+	for _, id := range changed {
+		projectile := curProjectiles[id]
+		if err = w.WriteUint16(id); err != nil {
+			return
+		}
+		if err = w.WriteUint16(projectile.Owner); err != nil {
+			return
+		}
+		if protocolVersion(m.Version) >= ProtocolVersion2 {
+			if err = w.WriteUint8(uint8(projectile.Weapon)); err != nil {
+				return
+			}
+		}
+		if err = projectile.Pos.Encode(w); err != nil {
+			return
+		}
+		if err = projectile.Dest.Encode(w); err != nil {
+			return
+		}
+	}
+
+	if err = writeIDs(w, removedIDs(baseProjectiles, curProjectiles)); err != nil {
+		return
+	}
+
+	// Before ProtocolVersion2, the power-ups are not sent.
+	baseCoins, curCoins := base.Coins, cur.Coins
+	if protocolVersion(m.Version) < ProtocolVersion2 {
+		baseCoins, curCoins = coinsOnly(baseCoins), coinsOnly(curCoins)
+	}
+
+	changed = changed[:0]
+	for _, id := range sortedIDs(curCoins) {
+		if prev, ok := baseCoins[id]; !ok || prev != curCoins[id] {
+			changed = append(changed, id)
+		}
+	}
+
+	if err = w.WriteUint16(uint16(len(changed))); err != nil {
+		return
+	}
+
+	for _, id := range changed {
+		coin := curCoins[id]
+		if err = w.WriteUint16(id); err != nil {
+			return
+		}
+		if err = coin.Pos.Encode(w); err != nil {
+			return
+		}
+		if err = w.WriteInt32(coin.Value); err != nil {
+			return
+		}
+		if protocolVersion(m.Version) >= ProtocolVersion2 {
+			if err = w.WriteUint8(uint8(coin.Effect)); err != nil {
+				return
+			}
+		}
+	}
+
+	if err = writeIDs(w, removedIDs(baseCoins, curCoins)); err != nil {
+		return
+	}
+
+	if protocolVersion(m.Version) < ProtocolVersion2 {
+		return
+	}
+
+	// The zone is small and changes at every tick while it closes, it is always sent.
+	return encodeZoneSnapshot(w, cur.Zone)
+}
+
+// cannonProjectiles returns the projectiles fired by cannons.
+func cannonProjectiles(projectiles map[uint16]ProjectileSnapshot) map[uint16]ProjectileSnapshot {
+	cannon := make(map[uint16]ProjectileSnapshot, len(projectiles))
+	for id, p := range projectiles {
+		if p.Weapon == PlayerWeaponCanon {
+			cannon[id] = p
+		}
+	}
+	return cannon
+}
+
+// coinsOnly returns the coins, power-ups excluded.
+func coinsOnly(coins map[uint16]CoinSnapshot) map[uint16]CoinSnapshot {
+	only := make(map[uint16]CoinSnapshot, len(coins))
+	for id, c := range coins {
+		if c.Effect == EffectNone {
+			only[id] = c
+		}
+	}
+	return only
+}
+
+// PlayerDelta holds the fields of a player present in a delta. Only the fields in
+// Mask are set.
+type PlayerDelta struct {
+	ID     uint16
+	Mask   uint16
+	Player PlayerSnapshot
+}
+
+// MessageGameStateDeltaToDecode is a decoded delta, which needs the base snapshot to be
+// turned back into a snapshot.
+type MessageGameStateDeltaToDecode struct {
+	Sequence     uint32
+	BaseSequence uint32
+	CurrentTick  int32
+	CurrentRound int8
+
+	Players            []PlayerDelta
+	RemovedPlayers     []uint16
+	Projectiles        map[uint16]ProjectileSnapshot
+	RemovedProjectiles []uint16
+	Coins              map[uint16]CoinSnapshot
+	RemovedCoins       []uint16
+
+	// Zone is the safe zone, nil when there is none or before ProtocolVersion2.
+	Zone *ZoneSnapshot
+
+	// Version is the protocol version the message was encoded with, 0 for the current one.
+	Version uint16
+}
+
+func (m *MessageGameStateDeltaToDecode) Decode(r codec.Reader) (err error) {
+	if m.Sequence, err = r.ReadUint32(); err != nil {
+		return
+	}
+
+	if m.BaseSequence, err = r.ReadUint32(); err != nil {
+		return
+	}
+
+	if m.CurrentTick, err = r.ReadInt32(); err != nil {
+		return
+	}
+
+	if m.CurrentRound, err = r.ReadInt8(); err != nil {
+		return
+	}
+
+	var size uint16
+	if size, err = r.ReadUint16(); err != nil {
+		return
+	}
+
+	m.Players = make([]PlayerDelta, size)
+	for i := range m.Players {
+		if m.Players[i].ID, err = r.ReadUint16(); err != nil {
+			return
+		}
+		if m.Players[i].Mask, err = readDeltaMask(r, protocolVersion(m.Version)); err != nil {
+			return
+		}
+		if err = m.Players[i].Player.decode(r, m.Players[i].Mask, protocolVersion(m.Version)); err != nil {
+			return
+		}
+	}
+
+	if m.RemovedPlayers, err = readIDs(r); err != nil {
+		return
+	}
+
+	if size, err = r.ReadUint16(); err != nil {
+		return
+	}
+
+	m.Projectiles = make(map[uint16]ProjectileSnapshot, size)
+	for i := uint16(0); i < size; i++ {
+		var id uint16
+		projectile := ProjectileSnapshot{Weapon: PlayerWeaponCanon}
+		if id, err = r.ReadUint16(); err != nil {
+			return
+		}
+		if projectile.Owner, err = r.ReadUint16(); err != nil {
+			return
+		}
+		if protocolVersion(m.Version) >= ProtocolVersion2 {
+			var weapon uint8
+			if weapon, err = r.ReadUint8(); err != nil {
+				return
+			}
+			projectile.Weapon = PlayerWeapon(weapon)
+		}
+		if err = projectile.Pos.Decode(r); err != nil {
+			return
+		}
+		if err = projectile.Dest.Decode(r); err != nil {
+			return
+		}
+		m.Projectiles[id] = projectile
+	}
+
+	if m.RemovedProjectiles, err = readIDs(r); err != nil {
+		return
+	}
+
+	if size, err = r.ReadUint16(); err != nil {
+		return
+	}
+
+	m.Coins = make(map[uint16]CoinSnapshot, size)
+	for i := uint16(0); i < size; i++ {
+		var id uint16
+		var coin CoinSnapshot
+		if id, err = r.ReadUint16(); err != nil {
+			return
+		}
+		if err = coin.Pos.Decode(r); err != nil {
+			return
+		}
+		if coin.Value, err = r.ReadInt32(); err != nil {
+			return
+		}
+		if protocolVersion(m.Version) >= ProtocolVersion2 {
+			var effect uint8
+			if effect, err = r.ReadUint8(); err != nil {
+				return
+			}
+			coin.Effect = PlayerEffect(effect)
+		}
+		m.Coins[id] = coin
+	}
+
+	if m.RemovedCoins, err = readIDs(r); err != nil {
+		return
+	}
+
+	if protocolVersion(m.Version) < ProtocolVersion2 {
+		return
+	}
+
+	m.Zone, err = decodeZoneSnapshot(r)
+	return
+}
+
+// ErrDeltaBase is returned when a delta is applied to another snapshot than its base.
+var ErrDeltaBase = errors.New("delta applied to the wrong base snapshot")
+
+// IsKeyframe returns true if the delta contains the whole state of the game.
+func (m *MessageGameStateDeltaToDecode) IsKeyframe() bool {
+	return m.BaseSequence == 0
+}
+
+// Apply returns the snapshot described by the delta. base must be the snapshot with
+// the base sequence of the delta, it is ignored for keyframes.
+func (m *MessageGameStateDeltaToDecode) Apply(base *Snapshot) (*Snapshot, error) {
+	if m.IsKeyframe() {
+		base = newSnapshot()
+	} else if base == nil || base.Sequence != m.BaseSequence {
+		return nil, ErrDeltaBase
+	}
+
+	s := newSnapshot()
+	s.Sequence = m.Sequence
+	s.CurrentTick = m.CurrentTick
+	s.CurrentRound = m.CurrentRound
+	s.Zone = m.Zone
+
+	for id, p := range base.Players {
+		s.Players[id] = p
+	}
+	for _, delta := range m.Players {
+		p := s.Players[delta.ID]
+		p.merge(delta.Player, delta.Mask)
+		s.Players[delta.ID] = p
+	}
+	for _, id := range m.RemovedPlayers {
+		delete(s.Players, id)
+	}
+
+	for id, p := range base.Projectiles {
+		s.Projectiles[id] = p
+	}
+	for id, p := range m.Projectiles {
+		s.Projectiles[id] = p
+	}
+	for _, id := range m.RemovedProjectiles {
+		delete(s.Projectiles, id)
+	}
+
+	for id, c := range base.Coins {
+		s.Coins[id] = c
+	}
+	for id, c := range m.Coins {
+		s.Coins[id] = c
+	}
+	for _, id := range m.RemovedCoins {
+		delete(s.Coins, id)
+	}
+
+	return s, nil
+}
+
+// merge copies the fields of other in mask into p.
+func (p *PlayerSnapshot) merge(other PlayerSnapshot, mask uint16) {
+	if mask&PlayerDeltaInfo != 0 {
+		p.Nickname, p.Color, p.Team = other.Nickname, other.Color, other.Team
+	}
+	if mask&PlayerDeltaHealth != 0 {
+		p.Health = other.Health
+	}
+	if mask&PlayerDeltaScore != 0 {
+		p.Score = other.Score
+	}
+	if mask&PlayerDeltaPosition != 0 {
+		p.Pos = other.Pos
+	}
+	if mask&PlayerDeltaDestination != 0 {
+		p.HasDest, p.Dest = other.HasDest, other.Dest
+	}
+	if mask&PlayerDeltaWeapon != 0 {
+		p.CurrentWeapon = other.CurrentWeapon
+	}
+	if mask&PlayerDeltaBlade != 0 {
+		p.BladeStart, p.BladeEnd, p.BladeRotation = other.BladeStart, other.BladeEnd, other.BladeRotation
+	}
+	if mask&PlayerDeltaSequence != 0 {
+		p.LastSequence, p.LastSequenceTick = other.LastSequence, other.LastSequenceTick
+	}
+	if mask&PlayerDeltaTriggers != 0 {
+		p.Triggers = other.Triggers
+	}
+	if mask&PlayerDeltaEffects != 0 {
+		p.Effects = other.Effects
+	}
+}
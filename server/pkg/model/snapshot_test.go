@@ -0,0 +1,303 @@
+package model
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
+)
+
+func TestQuantize(t *testing.T) {
+	tests := map[string]struct {
+		point Point
+		want  Point
+	}{
+		"Exact position":   {point: Point{X: 12.5, Y: 0.05}, want: Point{X: 12.5, Y: 0.05}},
+		"Rounded position": {point: Point{X: 1.234, Y: -7.891}, want: Point{X: 1.25, Y: -7.9}},
+		"Clamped position": {point: Point{X: 5000, Y: -5000}, want: Point{X: 32767.0 / PositionScale, Y: -32768.0 / PositionScale}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Quantize(tt.point).Point()
+			if math.Abs(got.X-tt.want.X) > 1e-9 || math.Abs(got.Y-tt.want.Y) > 1e-9 {
+				t.Errorf("Quantize(%v) = %v, want %v", tt.point, got, tt.want)
+			}
+		})
+	}
+}
+
+// roundTripDelta encodes the delta between base and current, decodes it and applies it
+// to base.
+func roundTripDelta(t *testing.T, base, current *Snapshot) (*Snapshot, int) {
+	t.Helper()
+
+	w := codec.NewByteWriter(binary.LittleEndian)
+	delta := MessageGameStateDeltaToEncode{Base: base, Current: current}
+	if err := delta.Encode(w); err != nil {
+		t.Fatalf("Encode() error %v", err)
+	}
+
+	var decoded MessageGameStateDeltaToDecode
+	if err := decoded.Decode(codec.NewByteReader(w.Bytes(), binary.LittleEndian)); err != nil {
+		t.Fatalf("Decode() error %v", err)
+	}
+
+	got, err := decoded.Apply(base)
+	if err != nil {
+		t.Fatalf("Apply() error %v", err)
+	}
+	return got, len(w.Bytes())
+}
+
+func TestSnapshotDelta(t *testing.T) {
+	alice := NewPlayer("alice", 1, &Point{X: 5, Y: 5}, nil)
+	bob := NewPlayer("bob", 2, &Point{X: 20, Y: 20}, nil)
+	carol := NewPlayer("carol", 3, &Point{X: 40, Y: 40}, nil)
+	bob.Team = "blue"
+	coins := []*Scorer{
+		NewCoin(&Point{X: 10, Y: 10}, DefaultGameRules()),
+		NewCoin(&Point{X: 30, Y: 30}, DefaultGameRules()),
+	}
+
+	builder := NewSnapshotBuilder()
+	first := builder.Capture([]*Player{alice, bob, carol}, coins, 1, 0)
+
+	keyframe, _ := roundTripDelta(t, nil, first)
+	if !reflect.DeepEqual(keyframe, first) {
+		t.Errorf("Keyframe mismatch: got %+v, want %+v", keyframe, first)
+	}
+
+	unchanged := builder.Capture([]*Player{alice, bob, carol}, coins, 2, 0)
+	got, size := roundTripDelta(t, first, unchanged)
+	if !reflect.DeepEqual(got, unchanged) {
+		t.Errorf("Unchanged delta mismatch: got %+v, want %+v", got, unchanged)
+	}
+
+	// Header, the six counts of entities and removed entities and the absence of zone.
+	if size != 4+4+4+1+6*2+1 {
+		t.Errorf("Unchanged delta should only contain its header, got %d bytes", size)
+	}
+
+	// alice moves and shoots, carol leaves and a coin is collected.
+	alice.SetPosition(Point{X: 6, Y: 5})
+	alice.cannon().ShootAt(Point{X: 50, Y: 50})
+	alice.AddScore(10)
+	bob.TakeDmg(15)
+	coins = []*Scorer{coins[0], NewCoin(&Point{X: 70, Y: 10}, DefaultGameRules())}
+
+	changed := builder.Capture([]*Player{alice, bob}, coins, 3, 1)
+	got, _ = roundTripDelta(t, unchanged, changed)
+	if !reflect.DeepEqual(got, changed) {
+		t.Errorf("Delta mismatch: got %+v, want %+v", got, changed)
+	}
+
+	if len(got.Players) != 2 || len(got.Projectiles) != 1 || len(got.Coins) != 2 {
+		t.Errorf("Unexpected entities %d players, %d projectiles, %d coins",
+			len(got.Players), len(got.Projectiles), len(got.Coins))
+	}
+
+	var decoded MessageGameStateDeltaToDecode
+	decoded.BaseSequence = unchanged.Sequence
+	if _, err := decoded.Apply(first); err != ErrDeltaBase {
+		t.Errorf("Apply() on the wrong base should fail, got %v", err)
+	}
+}
+
+func TestSnapshotDeltaWeapons(t *testing.T) {
+	alice := NewPlayer("alice", 1, &Point{X: 5, Y: 5}, nil)
+	alice.cannon().ShootAt(Point{X: 50, Y: 50})
+	alice.Weapon(PlayerWeaponMine).Fire(&Controls{Shoot: &Point{}})
+
+	current := NewSnapshotBuilder().Capture([]*Player{alice}, nil, 1, 0)
+
+	tests := map[string]struct {
+		version  uint16
+		expected []PlayerWeapon
+	}{
+		"Cannon only before version 2": {version: ProtocolVersion1, expected: []PlayerWeapon{PlayerWeaponCanon}},
+		"Every weapon since version 2": {version: ProtocolVersion2, expected: []PlayerWeapon{PlayerWeaponCanon, PlayerWeaponMine}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := codec.NewByteWriter(binary.LittleEndian)
+			delta := MessageGameStateDeltaToEncode{Current: current, Version: tt.version}
+			if err := delta.Encode(w); err != nil {
+				t.Fatalf("Encode() error %v", err)
+			}
+
+			decoded := MessageGameStateDeltaToDecode{Version: tt.version}
+			if err := decoded.Decode(codec.NewByteReader(w.Bytes(), binary.LittleEndian)); err != nil {
+				t.Fatalf("Decode() error %v", err)
+			}
+
+			weapons := []PlayerWeapon{}
+			for _, id := range sortedIDs(decoded.Projectiles) {
+				weapons = append(weapons, decoded.Projectiles[id].Weapon)
+			}
+			if !reflect.DeepEqual(weapons, tt.expected) {
+				t.Errorf("Projectiles of the weapons %v, want %v", weapons, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSnapshotDeltaTriggers(t *testing.T) {
+	alice := NewPlayer("alice", 1, &Point{X: 5, Y: 5}, nil)
+
+	builder := NewSnapshotBuilder()
+	base := builder.Capture([]*Player{alice}, nil, 1, 0)
+
+	alice.cannon().Fire(&Controls{Shoot: &Point{X: 50, Y: 50}})
+	current := builder.Capture([]*Player{alice}, nil, 2, 0)
+
+	tests := map[string]struct {
+		version uint16
+		sent    bool
+	}{
+		"Triggers dropped before version 2": {version: ProtocolVersion1},
+		"Triggers sent since version 2":     {version: ProtocolVersion2, sent: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := codec.NewByteWriter(binary.LittleEndian)
+			delta := MessageGameStateDeltaToEncode{Base: base, Current: current, Version: tt.version}
+			if err := delta.Encode(w); err != nil {
+				t.Fatalf("Encode() error %v", err)
+			}
+
+			decoded := MessageGameStateDeltaToDecode{Version: tt.version}
+			if err := decoded.Decode(codec.NewByteReader(w.Bytes(), binary.LittleEndian)); err != nil {
+				t.Fatalf("Decode() error %v", err)
+			}
+
+			// Only the triggers of alice changed, she is left out of the delta without them.
+			if sent := len(decoded.Players) == 1 && decoded.Players[0].Mask == PlayerDeltaTriggers; sent != tt.sent {
+				t.Fatalf("Players %+v, want the triggers sent %v", decoded.Players, tt.sent)
+			}
+
+			got, err := decoded.Apply(base)
+			if err != nil {
+				t.Fatalf("Apply() error %v", err)
+			}
+
+			want := base.Players
+			if tt.sent {
+				want = current.Players
+			}
+			if !reflect.DeepEqual(got.Players[1].Triggers, want[1].Triggers) {
+				t.Errorf("Triggers %+v, want %+v", got.Players[1].Triggers, want[1].Triggers)
+			}
+		})
+	}
+}
+
+func TestSnapshotDeltaPowerUps(t *testing.T) {
+	rules := DefaultGameRules()
+	coins := []*Scorer{
+		NewCoin(&Point{X: 10, Y: 10}, rules),
+		NewPowerUp(&Point{X: 20, Y: 20}, EffectSpeed, rules),
+	}
+	current := NewSnapshotBuilder().Capture(nil, coins, 1, 0)
+
+	tests := map[string]struct {
+		version  uint16
+		expected []PlayerEffect
+	}{
+		"Coins only before version 2":    {version: ProtocolVersion1, expected: []PlayerEffect{EffectNone}},
+		"Power-ups sent since version 2": {version: ProtocolVersion2, expected: []PlayerEffect{EffectNone, EffectSpeed}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := codec.NewByteWriter(binary.LittleEndian)
+			delta := MessageGameStateDeltaToEncode{Current: current, Version: tt.version}
+			if err := delta.Encode(w); err != nil {
+				t.Fatalf("Encode() error %v", err)
+			}
+
+			decoded := MessageGameStateDeltaToDecode{Version: tt.version}
+			if err := decoded.Decode(codec.NewByteReader(w.Bytes(), binary.LittleEndian)); err != nil {
+				t.Fatalf("Decode() error %v", err)
+			}
+
+			effects := []PlayerEffect{}
+			for _, id := range sortedIDs(decoded.Coins) {
+				effects = append(effects, decoded.Coins[id].Effect)
+			}
+			if !reflect.DeepEqual(effects, tt.expected) {
+				t.Errorf("Coins of the effects %v, want %v", effects, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSnapshotDeltaZone(t *testing.T) {
+	base := NewSnapshotBuilder().Capture(nil, nil, 1, 0)
+	current := NewSnapshotBuilder().Capture(nil, nil, 2, 0)
+	current.Sequence = base.Sequence + 1
+	current.Zone = NewZone(Point{X: 10, Y: 20}, 30, 5, 10).Snapshot()
+
+	got, _ := roundTripDelta(t, base, current)
+	if !reflect.DeepEqual(got.Zone, current.Zone) {
+		t.Errorf("Zone %+v, want %+v", got.Zone, current.Zone)
+	}
+
+	want := &ZoneSnapshot{Center: Quantize(Point{X: 10, Y: 20}), Radius: uint16(30 * PositionScale)}
+	if !reflect.DeepEqual(current.Zone, want) {
+		t.Errorf("Snapshot() = %+v, want %+v", current.Zone, want)
+	}
+}
+
+func TestSnapshotIDsWrap(t *testing.T) {
+	alice := NewPlayer("alice", 1, &Point{X: 5, Y: 5}, nil)
+	first := NewCoin(&Point{X: 10, Y: 10}, DefaultGameRules())
+	second := NewCoin(&Point{X: 30, Y: 30}, DefaultGameRules())
+
+	builder := NewSnapshotBuilder()
+	builder.Capture([]*Player{alice}, []*Scorer{first}, 1, 0)
+
+	builder.nextID = math.MaxUint16
+	s := builder.Capture([]*Player{alice}, []*Scorer{first, second}, 2, 0)
+
+	if len(s.Players) != 1 || len(s.Coins) != 2 {
+		t.Fatalf("Expected 1 player and 2 coins, got %d and %d", len(s.Players), len(s.Coins))
+	}
+
+	if id := builder.objectIDs[second.uuid]; id != 3 {
+		t.Errorf("New coin id = %d, want 3 after skipping 0 and the ids in use", id)
+	}
+}
+
+func TestSnapshotIDsWrapKeepHistory(t *testing.T) {
+	alice := NewPlayer("alice", 1, &Point{X: 5, Y: 5}, nil)
+	bob := NewPlayer("bob", 2, &Point{X: 10, Y: 10}, nil)
+
+	builder := NewSnapshotBuilder()
+	base := builder.Capture([]*Player{alice}, nil, 1, 0)
+
+	// alice leaves, then the ids wrap while a client may still acknowledge the base.
+	for tick := int32(2); tick < SnapshotHistory; tick++ {
+		builder.Capture(nil, nil, tick, 0)
+	}
+	builder.nextID = math.MaxUint16
+	s := builder.Capture([]*Player{bob}, nil, SnapshotHistory, 0)
+
+	for id := range s.Players {
+		if _, ok := base.Players[id]; ok {
+			t.Errorf("bob got id %d, still held by alice in the base snapshot", id)
+		}
+	}
+
+	// Once the base snapshot leaves the history, its ids are free again.
+	builder.Capture(nil, nil, SnapshotHistory+1, 0)
+	builder.nextID = math.MaxUint16
+	s = builder.Capture([]*Player{alice}, nil, SnapshotHistory+2, 0)
+	if _, ok := s.Players[1]; !ok {
+		t.Errorf("Expected id 1 to be reused, got %v", s.Players)
+	}
+}
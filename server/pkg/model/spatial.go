@@ -0,0 +1,241 @@
+package model
+
+import "math"
+
+// SpatialGrid is the broad phase of the collision detection: it splits the map in square
+// cells and finds the walls, players and coins close to an area, so that the precise
+// polygon tests only run against them.
+//
+// The walls are indexed once per game, in every cell they overlap. The players and the
+// coins move, so they are indexed again every tick, in the cell holding their center.
+// A query is extended by the reach of these objects, their half diagonal plus the
+// distance they can travel during the tick, so that it finds them anywhere in the tick.
+// A player teleported during the tick, by a respawn, must be moved in the grid.
+type SpatialGrid struct {
+	cellSize float64
+	size     int
+
+	walls     [][]int
+	wallList  []*Collider
+	wallMarks []uint32
+	mark      uint32
+
+	players [][]*Player
+	coins   [][]*Scorer
+	reach   float64
+}
+
+// NewSpatialGrid creates a grid covering a map of the given width, in cells of cellSize.
+// The objects outside of the map are indexed in the closest cell.
+func NewSpatialGrid(width, cellSize float64) *SpatialGrid {
+	size := int(math.Max(1, math.Ceil(width/cellSize)))
+
+	return &SpatialGrid{
+		cellSize: cellSize,
+		size:     size,
+		walls:    make([][]int, size*size),
+		players:  make([][]*Player, size*size),
+		coins:    make([][]*Scorer, size*size),
+	}
+}
+
+// SetWalls indexes the walls of the map.
+func (g *SpatialGrid) SetWalls(walls []*Collider) {
+	for i := range g.walls {
+		g.walls[i] = g.walls[i][:0]
+	}
+	g.wallList = walls
+	g.wallMarks = make([]uint32, len(walls))
+	g.mark = 0
+
+	for i, wall := range walls {
+		min, max := bounds(wall.Points)
+		g.each(min, max, func(cell int) {
+			g.walls[cell] = append(g.walls[cell], i)
+		})
+	}
+}
+
+// Rebuild indexes the players and the coins at their current position. margin is the
+// farthest distance an object can travel before the next rebuild.
+func (g *SpatialGrid) Rebuild(players []*Player, coins []*Scorer, margin float64) {
+	for i := range g.players {
+		g.players[i] = g.players[i][:0]
+		g.coins[i] = g.coins[i][:0]
+	}
+	g.reach = 0
+
+	for _, p := range players {
+		cell := g.cellOf(*p.collider.Pivot)
+		g.players[cell] = append(g.players[cell], p)
+		g.extendReach(p.collider)
+	}
+
+	for _, c := range coins {
+		cell := g.cellOf(*c.collider.Pivot)
+		g.coins[cell] = append(g.coins[cell], c)
+		g.extendReach(c.collider)
+	}
+
+	g.reach += margin
+}
+
+// Move indexes a player teleported from a position to its current one.
+func (g *SpatialGrid) Move(p *Player, from Point) {
+	cell := g.cellOf(from)
+	for i, other := range g.players[cell] {
+		if other == p {
+			g.players[cell] = append(g.players[cell][:i], g.players[cell][i+1:]...)
+			break
+		}
+	}
+
+	cell = g.cellOf(*p.collider.Pivot)
+	g.players[cell] = append(g.players[cell], p)
+}
+
+// Walls returns the walls which may overlap the area between min and max.
+func (g *SpatialGrid) Walls(min, max Point) []*Collider {
+	g.mark++
+	if g.mark == 0 {
+		clear(g.wallMarks)
+		g.mark = 1
+	}
+
+	walls := []*Collider{}
+	g.each(min, max, func(cell int) {
+		for _, i := range g.walls[cell] {
+			if g.wallMarks[i] != g.mark {
+				g.wallMarks[i] = g.mark
+				walls = append(walls, g.wallList[i])
+			}
+		}
+	})
+	return walls
+}
+
+// Players returns the players which may overlap the area between min and max.
+func (g *SpatialGrid) Players(min, max Point) []*Player {
+	players := []*Player{}
+	g.each(g.extend(min, -g.reach), g.extend(max, g.reach), func(cell int) {
+		players = append(players, g.players[cell]...)
+	})
+	return players
+}
+
+// Coins returns the coins which may overlap the area between min and max.
+func (g *SpatialGrid) Coins(min, max Point) []*Scorer {
+	coins := []*Scorer{}
+	g.each(g.extend(min, -g.reach), g.extend(max, g.reach), func(cell int) {
+		coins = append(coins, g.coins[cell]...)
+	})
+	return coins
+}
+
+// SweepWalls returns the fraction of the movement from `from` to `to` after which a
+// square of the given size centered on the moving point hits a wall, or false if it hits
+// none. The walls are segments on the lines between the cells.
+func (g *SpatialGrid) SweepWalls(from, to Point, size float64) (float64, bool) {
+	t, _, hit := g.sweepWalls(from, to, size)
+	return t, hit
+}
+
+// sweepWalls is SweepWalls, also returning the normal of the side of the wall hit.
+func (g *SpatialGrid) sweepWalls(from, to Point, size float64) (float64, Point, bool) {
+	half := size / 2
+	min, max := bounds([]*Point{&from, &to})
+
+	first, normal, hit := 1.0, Point{}, false
+	for _, wall := range g.Walls(g.extend(min, -half), g.extend(max, half)) {
+		low, high := bounds(wall.Points)
+		if t, n, ok := sweep(from, to, g.extend(low, -half), g.extend(high, half)); ok && t <= first {
+			first, normal, hit = t, n, true
+		}
+	}
+	return first, normal, hit
+}
+
+// pushOut moves p out of the walls whose box, widened by half of the given size, holds
+// it, along the axis on which it overlaps the wall the least.
+func (g *SpatialGrid) pushOut(p Point, size float64) Point {
+	half := size / 2
+	for _, wall := range g.Walls(g.extend(p, -half), g.extend(p, half)) {
+		low, high := bounds(wall.Points)
+		low, high = g.extend(low, -half), g.extend(high, half)
+		if p.X <= low.X || p.X >= high.X || p.Y <= low.Y || p.Y >= high.Y {
+			continue
+		}
+
+		x := low.X - wallGap
+		if high.X-p.X < p.X-low.X {
+			x = high.X + wallGap
+		}
+		y := low.Y - wallGap
+		if high.Y-p.Y < p.Y-low.Y {
+			y = high.Y + wallGap
+		}
+
+		if math.Abs(x-p.X) < math.Abs(y-p.Y) {
+			p.X = x
+		} else {
+			p.Y = y
+		}
+	}
+	return p
+}
+
+// extendReach makes sure that the queries reach the whole collider from its pivot.
+func (g *SpatialGrid) extendReach(c *RectCollider) {
+	min, max := c.Bounds()
+	g.reach = math.Max(g.reach, math.Max(
+		math.Max(c.Pivot.X-min.X, max.X-c.Pivot.X),
+		math.Max(c.Pivot.Y-min.Y, max.Y-c.Pivot.Y),
+	))
+}
+
+// each calls fn with every cell overlapping the area between min and max, row by row.
+func (g *SpatialGrid) each(min, max Point, fn func(cell int)) {
+	for row := g.index(min.Y); row <= g.index(max.Y); row++ {
+		for col := g.index(min.X); col <= g.index(max.X); col++ {
+			fn(row*g.size + col)
+		}
+	}
+}
+
+// extend moves p by d on both axes.
+func (g *SpatialGrid) extend(p Point, d float64) Point {
+	return Point{X: p.X + d, Y: p.Y + d}
+}
+
+// index returns the column or the row holding the coordinate v.
+func (g *SpatialGrid) index(v float64) int {
+	i := int(math.Floor(v / g.cellSize))
+	if i < 0 {
+		return 0
+	}
+	if i >= g.size {
+		return g.size - 1
+	}
+	return i
+}
+
+// cellOf returns the cell holding the position p.
+func (g *SpatialGrid) cellOf(p Point) int {
+	return g.index(p.Y)*g.size + g.index(p.X)
+}
+
+// Bounds returns the corners of the smallest axis aligned rectangle holding the collider.
+func (r *RectCollider) Bounds() (Point, Point) {
+	return bounds(r.polygon().vertices)
+}
+
+// bounds returns the corners of the smallest axis aligned rectangle holding the points.
+func bounds(points []*Point) (Point, Point) {
+	min := Point{X: math.Inf(1), Y: math.Inf(1)}
+	max := Point{X: math.Inf(-1), Y: math.Inf(-1)}
+	for _, p := range points {
+		min.X, min.Y = math.Min(min.X, p.X), math.Min(min.Y, p.Y)
+		max.X, max.Y = math.Max(max.X, p.X), math.Max(max.Y, p.Y)
+	}
+	return min, max
+}
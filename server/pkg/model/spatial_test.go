@@ -0,0 +1,88 @@
+package model
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/capucinoxx/jdis-games-2024/consts"
+)
+
+// spaceOf returns a grid holding players, for the collision tests of a single object.
+func spaceOf(players ...*Player) *SpatialGrid {
+	space := NewSpatialGrid(consts.MapWidth*consts.CellWidth, consts.CellWidth)
+	space.Rebuild(players, nil, 0)
+	return space
+}
+
+func TestSpatialGridQueries(t *testing.T) {
+	players := []*Player{
+		NewPlayer("near", 0, &Point{X: 9.9, Y: 9.9}, nil),
+		NewPlayer("far", 0, &Point{X: 85, Y: 85}, nil),
+		NewPlayer("outside", 0, &Point{X: -20, Y: 45}, nil),
+	}
+	walls := []*Collider{
+		{Points: []*Point{{X: 0, Y: 10}, {X: 10, Y: 10}}},
+		{Points: []*Point{{X: 10, Y: 0}, {X: 10, Y: 20}}},
+		{Points: []*Point{{X: 90, Y: 90}, {X: 100, Y: 90}}},
+	}
+
+	space := spaceOf(players...)
+	space.SetWalls(walls)
+
+	tests := map[string]struct {
+		min, max    Point
+		wantPlayers []string
+		wantWalls   int
+	}{
+		"Across a cell border": {min: Point{X: 10.2, Y: 10.2}, max: Point{X: 11, Y: 11}, wantPlayers: []string{"near"}, wantWalls: 2},
+		"Empty area":           {min: Point{X: 50, Y: 50}, max: Point{X: 51, Y: 51}, wantWalls: 0},
+		"Outside of the map":   {min: Point{X: -25, Y: 48}, max: Point{X: -24, Y: 49}, wantPlayers: []string{"outside"}, wantWalls: 0},
+		"Whole map":            {min: Point{X: 0, Y: 0}, max: Point{X: 100, Y: 100}, wantPlayers: []string{"near", "outside", "far"}, wantWalls: 3},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			found := space.Players(tt.min, tt.max)
+			if len(found) != len(tt.wantPlayers) {
+				t.Fatalf("Players() returned %d players, want %v", len(found), tt.wantPlayers)
+			}
+			for i, p := range found {
+				if p.Nickname != tt.wantPlayers[i] {
+					t.Errorf("Players()[%d] = %s, want %s", i, p.Nickname, tt.wantPlayers[i])
+				}
+			}
+
+			if walls := space.Walls(tt.min, tt.max); len(walls) != tt.wantWalls {
+				t.Errorf("Walls() returned %d walls, want %d", len(walls), tt.wantWalls)
+			}
+		})
+	}
+}
+
+// TestSpatialGridBroadPhase checks that the broad phase never misses a collision found by
+// testing every pair.
+func TestSpatialGridBroadPhase(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	width := float64(consts.MapWidth * consts.CellWidth)
+
+	players := make([]*Player, 200)
+	for i := range players {
+		players[i] = NewPlayer("", 0, &Point{X: r.Float64() * width, Y: r.Float64() * width}, nil)
+	}
+	space := spaceOf(players...)
+
+	for i := 0; i < 1000; i++ {
+		probe := NewProjectile(&Point{X: r.Float64() * width, Y: r.Float64() * width}, &Point{}, DefaultGameRules())
+
+		candidates := make(map[*Player]bool)
+		for _, p := range space.Players(probe.collider.Bounds()) {
+			candidates[p] = true
+		}
+
+		for _, p := range players {
+			if probe.IsCollidingWithPlayer(p) && !candidates[p] {
+				t.Fatalf("Player at %v colliding with the projectile at %v was not found", *p.Position, *probe.Position)
+			}
+		}
+	}
+}
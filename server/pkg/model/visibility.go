@@ -0,0 +1,76 @@
+package model
+
+import "math"
+
+// Visibility computes what a player can see when the fog of war is enabled: the
+// positions in its line of sight, closer than the vision radius when there is one.
+// A nil Visibility sees everything.
+type Visibility struct {
+	viewer *Player
+	radius float64
+	space  *SpatialGrid
+}
+
+// NewVisibility creates the visibility of viewer through the walls indexed by space. A
+// radius of 0 limits the vision to the line of sight only.
+func NewVisibility(viewer *Player, space *SpatialGrid, radius float64) *Visibility {
+	return &Visibility{viewer: viewer, radius: radius, space: space}
+}
+
+// Viewer returns the player whose visibility is computed, nil when everything is visible.
+func (v *Visibility) Viewer() *Player {
+	if v == nil {
+		return nil
+	}
+	return v.viewer
+}
+
+// CanSee returns true if the position is visible to the viewer.
+func (v *Visibility) CanSee(p Point) bool {
+	if v == nil {
+		return true
+	}
+
+	from := *v.viewer.Position
+	if v.radius > 0 && !from.WithinDistanceOf(float32(v.radius), &p) {
+		return false
+	}
+
+	sight := Polygon{vertices: []*Point{&from, &p}}
+	minX, maxX := math.Min(from.X, p.X), math.Max(from.X, p.X)
+	minY, maxY := math.Min(from.Y, p.Y), math.Max(from.Y, p.Y)
+
+	for _, wall := range v.space.Walls(Point{X: minX, Y: minY}, Point{X: maxX, Y: maxY}) {
+		if !wall.overlaps(minX, minY, maxX, maxY) {
+			continue
+		}
+
+		if PolygonsIntersect(sight, wall.polygon()) {
+			return false
+		}
+	}
+	return true
+}
+
+// CanSeePlayer returns true if the player is visible to the viewer. The viewer always
+// sees itself.
+func (v *Visibility) CanSeePlayer(p *Player) bool {
+	return v == nil || p == v.viewer || v.CanSee(*p.Position)
+}
+
+// CanSeeProjectile returns true if the projectile of owner is visible to the viewer. The
+// viewer always sees its own projectiles.
+func (v *Visibility) CanSeeProjectile(owner *Player, p *Projectile) bool {
+	return v == nil || owner == v.viewer || v.CanSee(*p.Position)
+}
+
+// overlaps returns true if the bounding box of the collider overlaps the given box.
+func (c *Collider) overlaps(minX, minY, maxX, maxY float64) bool {
+	cMinX, cMinY := math.Inf(1), math.Inf(1)
+	cMaxX, cMaxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range c.Points {
+		cMinX, cMaxX = math.Min(cMinX, p.X), math.Max(cMaxX, p.X)
+		cMinY, cMaxY = math.Min(cMinY, p.Y), math.Max(cMaxY, p.Y)
+	}
+	return cMinX <= maxX && cMaxX >= minX && cMinY <= maxY && cMaxY >= minY
+}
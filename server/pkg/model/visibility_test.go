@@ -0,0 +1,100 @@
+package model
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
+)
+
+func TestVisibilityCanSee(t *testing.T) {
+	viewer := NewPlayer("viewer", 0, &Point{X: 5, Y: 5}, nil)
+	walls := []*Collider{
+		{Points: []*Point{{X: 10, Y: 0}, {X: 10, Y: 10}}, Type: ColliderWall},
+	}
+
+	tests := map[string]struct {
+		radius float64
+		target Point
+		want   bool
+	}{
+		"Same cell":                {target: Point{X: 8, Y: 8}, want: true},
+		"Behind a wall":            {target: Point{X: 15, Y: 5}, want: false},
+		"Around the wall":          {target: Point{X: 15, Y: 25}, want: true},
+		"Out of the vision radius": {radius: 10, target: Point{X: 5, Y: 25}, want: false},
+		"In the vision radius":     {radius: 10, target: Point{X: 5, Y: 12}, want: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := NewVisibility(viewer, wallSpace(walls), tt.radius)
+			if got := v.CanSee(tt.target); got != tt.want {
+				t.Errorf("CanSee(%v) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+
+	var everything *Visibility
+	if !everything.CanSee(Point{X: 15, Y: 5}) {
+		t.Errorf("A nil visibility should see everything")
+	}
+}
+
+func TestGameStateFogOfWar(t *testing.T) {
+	viewer := NewPlayer("viewer", 0, &Point{X: 5, Y: 5}, nil)
+	hidden := NewPlayer("hidden", 0, &Point{X: 15, Y: 5}, nil)
+	visible := NewPlayer("visible", 0, &Point{X: 5, Y: 15}, nil)
+	walls := []*Collider{
+		{Points: []*Point{{X: 10, Y: 0}, {X: 10, Y: 10}}, Type: ColliderWall},
+	}
+
+	// The viewer always sees its projectiles, even behind a wall.
+	viewer.cannon().Projectiles = []*Projectile{NewProjectile(&Point{X: 15, Y: 6}, &Point{X: 20, Y: 6}, DefaultGameRules())}
+	hidden.cannon().Projectiles = []*Projectile{NewProjectile(&Point{X: 15, Y: 7}, &Point{X: 20, Y: 7}, DefaultGameRules())}
+
+	coins := []*Scorer{
+		NewCoin(&Point{X: 6, Y: 6}, DefaultGameRules()),
+		NewCoin(&Point{X: 16, Y: 6}, DefaultGameRules()),
+	}
+
+	w := codec.NewByteWriter(binary.LittleEndian)
+	message := MessageGameStateToEncode{
+		Players:    []*Player{viewer, hidden, visible},
+		Coins:      coins,
+		Visibility: NewVisibility(viewer, wallSpace(walls), 0),
+	}
+	if err := message.Encode(w); err != nil {
+		t.Fatalf("Encode() error %v", err)
+	}
+
+	var decoded MessageGameStateToDecode
+	if err := decoded.Decode(codec.NewByteReader(w.Bytes(), binary.LittleEndian)); err != nil {
+		t.Fatalf("Decode() error %v", err)
+	}
+
+	if len(decoded.Players) != 2 || decoded.Players[0].Nickname != "viewer" || decoded.Players[1].Nickname != "visible" {
+		t.Errorf("Unexpected visible players %+v", decoded.Players)
+	}
+
+	if len(decoded.Players[0].Projectiles) != 1 {
+		t.Errorf("The viewer should see its projectile")
+	}
+
+	if len(decoded.Coins) != 1 {
+		t.Errorf("Only one coin should be visible, got %d", len(decoded.Coins))
+	}
+
+	snapshot := NewSnapshotBuilder().Capture([]*Player{viewer, hidden, visible}, coins, 0, 0)
+	filtered := snapshot.Filter(NewVisibility(viewer, wallSpace(walls), 0))
+	if len(filtered.Players) != 2 || len(filtered.Projectiles) != 1 || len(filtered.Coins) != 1 {
+		t.Errorf("Unexpected filtered snapshot %d players, %d projectiles, %d coins",
+			len(filtered.Players), len(filtered.Projectiles), len(filtered.Coins))
+	}
+}
+
+// wallSpace indexes walls in a grid covering a map of 40 units.
+func wallSpace(walls []*Collider) *SpatialGrid {
+	space := NewSpatialGrid(40, 10)
+	space.SetWalls(walls)
+	return space
+}
@@ -2,21 +2,185 @@ package model
 
 import (
 	"math"
+	"sort"
 
 	"github.com/capucinoxx/jdis-games-2024/consts"
-	"github.com/capucinoxx/jdis-games-2024/pkg/utils"
+	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
 )
 
+// Weapon is a weapon carried by a player. Every player carries one weapon of each type
+// registered, and fires the one it holds.
+type Weapon interface {
+	// Fire uses the weapon with the controls of its owner, who holds it. The controls
+	// used are consumed.
+	Fire(controls *Controls)
+
+	// Update moves what the weapon fired during dt and damages the players hit.
+	Update(space *SpatialGrid, dt float64)
+
+	// Fired returns the projectiles fired by the weapon which are still in the game.
+	Fired() []*Projectile
+
+	// Encode writes the section of the weapon in MessageGameState, with only what is
+	// visible to v.
+	Encode(w codec.Writer, v *Visibility) error
+}
+
+// WeaponType describes a kind of weapon.
+type WeaponType struct {
+	ID PlayerWeapon
+
+	// Name is the name of the weapon in the logs.
+	Name string
+
+	// New creates the weapon carried by owner.
+	New func(owner *Player) Weapon
+}
+
+// weaponTypes holds the weapons carried by the players, ordered by id. It only changes
+// during the initialization of the program (see RegisterWeapon).
+var weaponTypes = []WeaponType{
+	{ID: PlayerWeaponCanon, Name: "projectile", New: func(owner *Player) Weapon { return NewCanon(owner) }},
+	{ID: PlayerWeaponBlade, Name: "blade", New: func(owner *Player) Weapon { return NewBlade(owner) }},
+	{ID: PlayerWeaponShotgun, Name: "shotgun", New: func(owner *Player) Weapon { return NewShotgun(owner) }},
+	{ID: PlayerWeaponMine, Name: "mine", New: func(owner *Player) Weapon { return NewMineLayer(owner) }},
+	{ID: PlayerWeaponGrenade, Name: "grenade", New: func(owner *Player) Weapon { return NewGrenadeLauncher(owner) }},
+}
+
+// RegisterWeapon makes a kind of weapon available to the players created afterwards. A
+// weapon registered with the id of another one replaces it. The cannon and the blade
+// keep their own sections in MessageGameState, so a weapon replacing them must encode
+// the same sections.
+//
+// The game loops of every room read the weapon types without any lock, so RegisterWeapon
+// must only be called from an init function, before any game runs.
+func RegisterWeapon(t WeaponType) {
+	for i := range weaponTypes {
+		if weaponTypes[i].ID == t.ID {
+			weaponTypes[i] = t
+			return
+		}
+	}
+
+	weaponTypes = append(weaponTypes, t)
+	sort.Slice(weaponTypes, func(i, j int) bool { return weaponTypes[i].ID < weaponTypes[j].ID })
+}
+
+// Triggered is implemented by the weapons whose rate of fire is limited by a trigger.
+type Triggered interface {
+	Trigger() *Trigger
+}
+
+// Trigger limits the rate of fire of a weapon. The weapon fires at most once per
+// cooldown and, when it has a magazine, reloads once the magazine is empty.
+type Trigger struct {
+	cooldown   float64
+	magazine   int
+	reloadTime float64
+
+	// wait is the time (in seconds) before the weapon can fire again, and ammo the
+	// number of rounds left in the magazine.
+	wait float64
+	ammo int
+}
+
+// NewTrigger creates a trigger firing once per cooldown (in seconds). A magazine of 0
+// means no magazine, and therefore no reload.
+func NewTrigger(cooldown float64, magazine int, reloadTime float64) *Trigger {
+	return &Trigger{cooldown: cooldown, magazine: magazine, reloadTime: reloadTime, ammo: magazine}
+}
+
+// triggerEpsilon absorbs the rounding errors of the time elapsed, so that a cooldown of
+// n ticks lasts n ticks.
+const triggerEpsilon = 1e-9
+
+// Ready returns true if the weapon can fire.
+func (t *Trigger) Ready() bool {
+	return t.wait <= triggerEpsilon
+}
+
+// pull fires the weapon if it is ready and returns whether it fired. Firing the last
+// round of the magazine starts the reload. The cooldown and the reload are divided by
+// rate.
+func (t *Trigger) pull(rate float64) bool {
+	if !t.Ready() {
+		return false
+	}
+
+	t.wait = t.cooldown / rate
+	if t.magazine > 0 {
+		t.ammo--
+		if t.ammo <= 0 {
+			t.wait = math.Max(t.wait, t.reloadTime/rate)
+		}
+	}
+	return true
+}
+
+// update lets dt elapse, refilling the magazine once reloaded.
+func (t *Trigger) update(dt float64) {
+	t.wait -= dt
+	if !t.Ready() {
+		return
+	}
+
+	t.wait = 0
+	if t.magazine > 0 && t.ammo <= 0 {
+		t.ammo = t.magazine
+	}
+}
+
+// State returns the state of the trigger sent to the clients.
+func (t *Trigger) State(weapon PlayerWeapon, tickrate int) TriggerState {
+	state := TriggerState{
+		Weapon:   weapon,
+		Cooldown: uint16(math.Min(math.Ceil(t.wait*float64(tickrate)-triggerEpsilon), math.MaxUint16)),
+		Ammo:     -1,
+	}
+	if t.magazine > 0 {
+		state.Ammo = int16(t.ammo)
+	}
+	return state
+}
+
+// fire pulls the trigger of a weapon of owner about to fire count projectiles, unless
+// the owner would have more projectiles in the game than allowed.
+func fire(owner *Player, t *Trigger, count int) bool {
+	if owner.liveProjectiles()+count > owner.rules.MaxProjectiles {
+		return false
+	}
+	return t.pull(owner.fireRate())
+}
+
+// weaponType returns the registered weapon with the given id.
+func weaponType(id PlayerWeapon) (WeaponType, bool) {
+	for _, t := range weaponTypes {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return WeaponType{}, false
+}
+
+// weaponName returns the name of a weapon in the logs.
+func weaponName(id PlayerWeapon) string {
+	if t, ok := weaponType(id); ok {
+		return t.Name
+	}
+	return "none"
+}
+
 // Projectile represents a moving projectile in the game.
 type Projectile struct {
 	Object
 	ttl         float64
+	speed       float64
 	Destination *Point
 }
 
-func NewProjectile(pos *Point, dest *Point) *Projectile {
-	p := &Projectile{Destination: dest, ttl: consts.ProjectileTTL}
-	p.setup(pos, consts.ProjectileSize)
+func NewProjectile(pos *Point, dest *Point, rules *GameRules) *Projectile {
+	p := &Projectile{Destination: dest, ttl: rules.ProjectileTTL, speed: rules.ProjectileSpeed}
+	p.setup(pos, rules.ProjectileSize)
 
 	return p
 }
@@ -37,9 +201,9 @@ func (p *Projectile) moveToDestination(dt float64) {
 	dy := dest.Y - p.Position.Y
 	dist := math.Sqrt(dx*dx + dy*dy)
 
-	if dist > consts.ProjectileSpeed*dt {
-		nextX := p.Position.X + dx/dist*consts.ProjectileSpeed*dt
-		nextY := p.Position.Y + dy/dist*consts.ProjectileSpeed*dt
+	if dist > p.speed*dt {
+		nextX := p.Position.X + dx/dist*p.speed*dt
+		nextY := p.Position.Y + dy/dist*p.speed*dt
 
 		p.Position.X = nextX
 		p.Position.Y = nextY
@@ -56,46 +220,141 @@ func (p *Projectile) moveToDestination(dt float64) {
 type Cannon struct {
 	Projectiles []*Projectile
 	owner       *Player
+	trigger     *Trigger
 }
 
 // NewCanon creates a new cannon associated with a specific player.
 func NewCanon(owner *Player) *Cannon {
+	rules := owner.rules
 	return &Cannon{
-		owner: owner,
+		owner:   owner,
+		trigger: NewTrigger(rules.CannonCooldown, rules.CannonMagazine, rules.CannonReloadTime),
 	}
 }
 
+// Fire shoots at the target of the controls when the trigger allows it. The target is
+// dropped otherwise.
+func (c *Cannon) Fire(controls *Controls) {
+	if controls.Shoot == nil {
+		return
+	}
+
+	target := *controls.Shoot
+	controls.Shoot = nil
+
+	if fire(c.owner, c.trigger, 1) {
+		c.ShootAt(target)
+	}
+}
+
+func (c *Cannon) Trigger() *Trigger {
+	return c.trigger
+}
+
 // Update processes all projectiles for movement and collision detection.
-func (c *Cannon) Update(players []*Player, dt float64) {
-	for _, p := range c.Projectiles {
-		p.reduceTTL(dt)
-		p.moveToDestination(dt)
+func (c *Cannon) Update(space *SpatialGrid, dt float64) {
+	c.trigger.update(dt)
+	c.Projectiles = updateProjectiles(c.owner, c.Projectiles, space, dt, func(_ *Projectile, target *Player) {
+		if target != nil {
+			c.owner.hit(target, PlayerWeaponCanon, c.owner.rules.ProjectileDmg, c.owner.rules.ScoreOnHitWithProjectile)
+		}
+	})
+}
 
-		for _, enemy := range players {
-			if c.owner.Nickname == enemy.Nickname || !enemy.IsAlive() {
-				continue
-			}
+func (c *Cannon) Fired() []*Projectile {
+	return c.Projectiles
+}
 
-			if p.IsCollidingWithPlayer(enemy) {
-				enemy.TakeDmg(consts.ProjectileDmg)
-				c.owner.score += consts.ScoreOnHitWithProjectile
-				p.Remove()
+// Encode writes the projectiles of the cannon.
+func (c *Cannon) Encode(w codec.Writer, v *Visibility) error {
+	return encodeProjectiles(w, c.owner, c.Projectiles, v)
+}
 
-				utils.Log(c.owner.Nickname, "score", "hit %s with projectile +%d total: %d",
-					enemy.Nickname, consts.ScoreOnHitWithProjectile, c.owner.score)
-				continue
-			}
+// updateProjectiles moves the projectiles of owner during dt and returns the ones still
+// in the game. done is called with every projectile removed, and the player it hit if
+// any.
+func updateProjectiles(owner *Player, projectiles []*Projectile, space *SpatialGrid, dt float64, done func(p *Projectile, target *Player)) []*Projectile {
+	for _, p := range projectiles {
+		p.reduceTTL(dt)
+
+		from := *p.Position
+		p.moveToDestination(dt)
+		target := collide(owner, p, from, space)
+
+		if !p.IsAlive() {
+			done(p, target)
 		}
 	}
 
 	// Filters out projectiles that need to be cleaned up.
-	projectiles := make([]*Projectile, 0)
-	for _, p := range c.Projectiles {
+	alive := make([]*Projectile, 0)
+	for _, p := range projectiles {
 		if p.IsAlive() {
-			projectiles = append(projectiles, p)
+			alive = append(alive, p)
+		}
+	}
+	return alive
+}
+
+// collide stops a projectile of owner moved from `from` at the first wall or enemy on
+// its way, and returns the enemy hit.
+func collide(owner *Player, p *Projectile, from Point, space *SpatialGrid) *Player {
+	to := *p.Position
+	size := owner.rules.ProjectileSize
+	half := size / 2
+
+	first, hitWall := space.SweepWalls(from, to, size)
+
+	var target *Player
+	min, max := bounds([]*Point{&from, &to})
+	for _, enemy := range space.Players(min, max) {
+		if !owner.canHit(enemy) {
+			continue
+		}
+
+		low, high := enemy.collider.Bounds()
+		low, high = Point{X: low.X - half, Y: low.Y - half}, Point{X: high.X + half, Y: high.Y + half}
+		if t, ok := Sweep(from, to, low, high); ok && t <= first {
+			first, target = t, enemy
 		}
 	}
-	c.Projectiles = projectiles
+
+	if target == nil && !hitWall {
+		return nil
+	}
+
+	p.stopAt(from, to, first)
+	p.Remove()
+	return target
+}
+
+// encodeProjectiles writes the projectiles of owner visible to v.
+func encodeProjectiles(w codec.Writer, owner *Player, projectiles []*Projectile, v *Visibility) (err error) {
+	visible := make([]*Projectile, 0, len(projectiles))
+	for _, projectile := range projectiles {
+		if v.CanSeeProjectile(owner, projectile) {
+			visible = append(visible, projectile)
+		}
+	}
+
+	if err = w.WriteInt32(int32(len(visible))); err != nil {
+		return
+	}
+
+	for _, projectile := range visible {
+		if _, err = w.WriteBytes(projectile.uuid[:]); err != nil {
+			return
+		}
+
+		if err = projectile.Position.Encode(w); err != nil {
+			return
+		}
+
+		if err = projectile.Destination.Encode(w); err != nil {
+			return
+		}
+	}
+	return
 }
 
 // ShootAt creates a projectile at a specified position and calculates its direction.
@@ -104,12 +363,16 @@ func (c *Cannon) ShootAt(pos Point) {
 	c.Projectiles = append(c.Projectiles, NewProjectile(
 		&Point{X: collider.Pivot.X, Y: collider.Pivot.Y},
 		&Point{X: pos.X, Y: pos.Y},
+		c.owner.rules,
 	))
 }
 
 type Blade struct {
 	collider *RectCollider
 	owner    *Player
+
+	// target is the angle the blade turns toward, nil to keep the blade still.
+	target *float64
 }
 
 func NewBlade(owner *Player) *Blade {
@@ -120,25 +383,297 @@ func NewBlade(owner *Player) *Blade {
 	return blade
 }
 
-func (b *Blade) Update(players []*Player, rotation *float64) {
+// Rotation returns the angle (in radians) of the blade.
+func (b *Blade) Rotation() float64 {
+	return b.collider.Rotation
+}
+
+// Fire sets the angle (in radians) the blade turns toward.
+func (b *Blade) Fire(controls *Controls) {
+	if controls.RotateBlade != nil {
+		target := *controls.RotateBlade
+		b.target = &target
+	}
+}
+
+// Update turns the blade toward its target by the shortest way, at most at the rotation
+// speed of the rules.
+func (b *Blade) Update(space *SpatialGrid, dt float64) {
+	rotation := b.collider.Rotation
+	if b.target != nil {
+		step := b.owner.rules.BladeRotationSpeed * math.Pi / 180 * dt
+		rotation += math.Max(-step, math.Min(step, math.Remainder(*b.target-rotation, 2*math.Pi)))
+	}
+	b.swing(space, rotation)
+}
+
+// bladeSweepStep is the largest angle (in radians) the blade turns between two checks
+// of its hits, so that the tip of the blade moves less than half a player.
+const bladeSweepStep = consts.PlayerSize / 2.0 / consts.BladeSize
+
+// swing moves the blade with its owner, turns it to rotation and damages the enemies it
+// touches on the way. An enemy is hit once per swing.
+func (b *Blade) swing(space *SpatialGrid, rotation float64) {
 	pivot := b.owner.Collider().Pivot
 	b.collider.ChangePosition(pivot.X, pivot.Y)
 
-	if rotation != nil {
-		b.collider.Rotate(*rotation)
+	from := b.collider.Rotation
+	steps := max(1, int(math.Ceil(math.Abs(rotation-from)/bladeSweepStep)))
+
+	hit := make(map[*Player]bool)
+	for i := 1; i <= steps; i++ {
+		b.collider.Rotate(from + (rotation-from)*float64(i)/float64(steps))
+
+		for _, enemy := range space.Players(b.collider.Bounds()) {
+			if hit[enemy] || !b.owner.canHit(enemy) {
+				continue
+			}
+
+			if PolygonsIntersect(b.collider.polygon(), enemy.Collider().polygon()) {
+				hit[enemy] = true
+				b.owner.hit(enemy, PlayerWeaponBlade, b.owner.rules.BladeDmg, b.owner.rules.ScoreOnHitWithBlade)
+			}
+		}
+	}
+}
+
+func (b *Blade) Fired() []*Projectile {
+	return nil
+}
+
+// Encode writes the ends and the rotation of the blade.
+func (b *Blade) Encode(w codec.Writer, _ *Visibility) (err error) {
+	if err = b.collider.rect.a.Encode(w); err != nil {
+		return
+	}
+
+	if err = b.collider.rect.b.Encode(w); err != nil {
+		return
+	}
+
+	return w.WriteFloat64(b.collider.Rotation)
+}
+
+// Shotgun fires a spread of short range pellets.
+type Shotgun struct {
+	Pellets []*Projectile
+	owner   *Player
+	trigger *Trigger
+}
+
+func NewShotgun(owner *Player) *Shotgun {
+	rules := owner.rules
+	return &Shotgun{
+		owner:   owner,
+		trigger: NewTrigger(rules.ShotgunCooldown, rules.ShotgunMagazine, rules.ShotgunReloadTime),
+	}
+}
+
+func (s *Shotgun) Trigger() *Trigger {
+	return s.trigger
+}
+
+// Fire shoots the pellets toward the target of the controls, spread evenly around it.
+func (s *Shotgun) Fire(controls *Controls) {
+	if controls.Shoot == nil {
+		return
+	}
+
+	target := *controls.Shoot
+	controls.Shoot = nil
+
+	rules := s.owner.rules
+	pivot := s.owner.Collider().Pivot
+	if target.X == pivot.X && target.Y == pivot.Y || !fire(s.owner, s.trigger, rules.ShotgunPellets) {
+		return
+	}
+
+	aim := math.Atan2(target.Y-pivot.Y, target.X-pivot.X)
+	spread := rules.ShotgunSpread * math.Pi / 180
+	for i := 0; i < rules.ShotgunPellets; i++ {
+		angle := aim
+		if rules.ShotgunPellets > 1 {
+			angle += spread * (float64(i)/float64(rules.ShotgunPellets-1) - 0.5)
+		}
+
+		s.Pellets = append(s.Pellets, NewProjectile(
+			&Point{X: pivot.X, Y: pivot.Y},
+			&Point{X: pivot.X + math.Cos(angle)*rules.ShotgunRange, Y: pivot.Y + math.Sin(angle)*rules.ShotgunRange},
+			rules,
+		))
+	}
+}
+
+func (s *Shotgun) Update(space *SpatialGrid, dt float64) {
+	s.trigger.update(dt)
+	s.Pellets = updateProjectiles(s.owner, s.Pellets, space, dt, func(_ *Projectile, target *Player) {
+		if target != nil {
+			s.owner.hit(target, PlayerWeaponShotgun, s.owner.rules.ShotgunDmg, s.owner.rules.ScoreOnHitWithShotgun)
+		}
+	})
+}
+
+func (s *Shotgun) Fired() []*Projectile {
+	return s.Pellets
+}
+
+// Encode writes the pellets of the shotgun.
+func (s *Shotgun) Encode(w codec.Writer, v *Visibility) error {
+	return encodeProjectiles(w, s.owner, s.Pellets, v)
+}
+
+// MineLayer drops mines which explode when an enemy comes close.
+type MineLayer struct {
+	Mines   []*Projectile
+	owner   *Player
+	trigger *Trigger
+}
+
+func NewMineLayer(owner *Player) *MineLayer {
+	return &MineLayer{owner: owner, trigger: NewTrigger(owner.rules.MineCooldown, 0, 0)}
+}
+
+func (m *MineLayer) Trigger() *Trigger {
+	return m.trigger
+}
+
+// Fire drops a mine where the owner stands, the oldest mine being removed when the owner
+// already has as many as allowed.
+func (m *MineLayer) Fire(controls *Controls) {
+	if controls.Shoot == nil {
+		return
+	}
+	controls.Shoot = nil
+
+	// Dropping a mine when the owner has them all replaces the oldest one.
+	rules := m.owner.rules
+	added := 1
+	if len(m.Mines) >= rules.MaxMines {
+		added = 0
 	}
+	if !fire(m.owner, m.trigger, added) {
+		return
+	}
+
+	pivot := m.owner.Collider().Pivot
+	mine := NewProjectile(&Point{X: pivot.X, Y: pivot.Y}, &Point{X: pivot.X, Y: pivot.Y}, rules)
+	mine.ttl = rules.MineTTL
+
+	m.Mines = append(m.Mines, mine)
+	if len(m.Mines) > rules.MaxMines {
+		m.Mines = m.Mines[len(m.Mines)-rules.MaxMines:]
+	}
+}
+
+// Update explodes the mines with an enemy within their radius.
+func (m *MineLayer) Update(space *SpatialGrid, dt float64) {
+	m.trigger.update(dt)
+	rules := m.owner.rules
 
-	for _, enemy := range players {
-		if b.owner.Nickname == enemy.Nickname || !enemy.IsAlive() {
+	mines := make([]*Projectile, 0, len(m.Mines))
+	for _, mine := range m.Mines {
+		mine.reduceTTL(dt)
+		if !mine.IsAlive() {
 			continue
 		}
 
-		if PolygonsIntersect(b.collider.polygon(), enemy.Collider().polygon()) {
-			enemy.TakeDmg(consts.BladeDmg)
-			b.owner.score += consts.ScoreOnHitWithBlade
+		if len(targetsAround(m.owner, *mine.Position, rules.MineRadius, space)) == 0 {
+			mines = append(mines, mine)
+			continue
+		}
+
+		mine.Remove()
+		explode(m.owner, PlayerWeaponMine, *mine.Position, rules.MineRadius, rules.MineDmg, space)
+	}
+	m.Mines = mines
+}
+
+func (m *MineLayer) Fired() []*Projectile {
+	return m.Mines
+}
+
+// Encode writes the mines, their destination being their position.
+func (m *MineLayer) Encode(w codec.Writer, v *Visibility) error {
+	return encodeProjectiles(w, m.owner, m.Mines, v)
+}
+
+// GrenadeLauncher throws grenades which explode where they land, or on the first wall
+// or enemy on their way.
+type GrenadeLauncher struct {
+	Grenades []*Projectile
+	owner    *Player
+	trigger  *Trigger
+}
+
+func NewGrenadeLauncher(owner *Player) *GrenadeLauncher {
+	return &GrenadeLauncher{owner: owner, trigger: NewTrigger(owner.rules.GrenadeCooldown, 0, 0)}
+}
+
+func (g *GrenadeLauncher) Trigger() *Trigger {
+	return g.trigger
+}
+
+// Fire throws a grenade toward the target of the controls, at most at the range of the
+// grenades.
+func (g *GrenadeLauncher) Fire(controls *Controls) {
+	if controls.Shoot == nil {
+		return
+	}
+
+	target := *controls.Shoot
+	controls.Shoot = nil
+
+	rules := g.owner.rules
+	if !fire(g.owner, g.trigger, 1) {
+		return
+	}
+
+	pivot := g.owner.Collider().Pivot
+	dx, dy := target.X-pivot.X, target.Y-pivot.Y
+	if dist := math.Sqrt(dx*dx + dy*dy); dist > rules.GrenadeRange {
+		target = Point{X: pivot.X + dx/dist*rules.GrenadeRange, Y: pivot.Y + dy/dist*rules.GrenadeRange}
+	}
+
+	g.Grenades = append(g.Grenades, NewProjectile(&Point{X: pivot.X, Y: pivot.Y}, &target, rules))
+}
 
-			utils.Log(b.owner.Nickname, "score", "hit %s with blade +%d total: %d",
-				enemy.Nickname, consts.ScoreOnHitWithBlade, b.owner.score)
+func (g *GrenadeLauncher) Update(space *SpatialGrid, dt float64) {
+	g.trigger.update(dt)
+	rules := g.owner.rules
+	g.Grenades = updateProjectiles(g.owner, g.Grenades, space, dt, func(p *Projectile, _ *Player) {
+		explode(g.owner, PlayerWeaponGrenade, *p.Position, rules.GrenadeRadius, rules.GrenadeDmg, space)
+	})
+}
+
+func (g *GrenadeLauncher) Fired() []*Projectile {
+	return g.Grenades
+}
+
+// Encode writes the grenades in flight.
+func (g *GrenadeLauncher) Encode(w codec.Writer, v *Visibility) error {
+	return encodeProjectiles(w, g.owner, g.Grenades, v)
+}
+
+// targetsAround returns the enemies of owner within radius of center and not behind a
+// wall.
+func targetsAround(owner *Player, center Point, radius float64, space *SpatialGrid) []*Player {
+	targets := []*Player{}
+	min, max := Point{X: center.X - radius, Y: center.Y - radius}, Point{X: center.X + radius, Y: center.Y + radius}
+	for _, enemy := range space.Players(min, max) {
+		if !owner.canHit(enemy) || !center.WithinDistanceOf(float32(radius), enemy.Position) {
+			continue
+		}
+
+		if _, hitWall := space.SweepWalls(center, *enemy.Position, 0); !hitWall {
+			targets = append(targets, enemy)
 		}
 	}
+	return targets
+}
+
+// explode damages the enemies of owner around center.
+func explode(owner *Player, weapon PlayerWeapon, center Point, radius float64, dmg int, space *SpatialGrid) {
+	for _, enemy := range targetsAround(owner, center, radius, space) {
+		owner.hit(enemy, weapon, dmg, owner.rules.ScoreOnHitWithExplosion)
+	}
 }
@@ -1,12 +1,14 @@
 package model
 
 import (
+	"encoding/binary"
 	"fmt"
 	"math"
 	"math/rand"
 	"testing"
 
 	"github.com/capucinoxx/jdis-games-2024/consts"
+	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
 )
 
 func TestCannonShootAt(t *testing.T) {
@@ -38,7 +40,7 @@ func TestCannonShootAt(t *testing.T) {
 
 			cannon.ShootAt(tt.projectileTarget)
 
-			cannon.Update([]*Player{}, 1.0)
+			cannon.Update(spaceOf(), 1.0)
 
 			pos := cannon.Projectiles[0].Position
 			expected := tt.expectedPosition
@@ -94,8 +96,9 @@ func TestCannonCollisionCases(t *testing.T) {
 
 			cannon.ShootAt(tt.projectileTarget)
 
+			space := spaceOf(enemies...)
 			for dt := 0.3; dt < 2.0; dt += 0.3 {
-				cannon.Update(enemies, dt)
+				cannon.Update(space, dt)
 			}
 
 			for i, enemy := range enemies {
@@ -134,10 +137,16 @@ func TestBladeIntersection(t *testing.T) {
 		},
 		"Blade does not intersect player": {
 			blade:          NewBlade(NewPlayer("0", 0, &Point{X: 0, Y: 0}, nil)),
-			otherPlayers:   []*Player{NewPlayer("1", 0, &Point{X: 1, Y: 0}, nil)},
+			otherPlayers:   []*Player{NewPlayer("1", 0, &Point{X: -1, Y: 0}, nil)},
 			rotation:       math.Pi / 2,
 			expectedHealth: []int{100},
 		},
+		"Blade sweeps through player": {
+			blade:          NewBlade(NewPlayer("0", 0, &Point{X: 0, Y: 0}, nil)),
+			otherPlayers:   []*Player{NewPlayer("1", 0, &Point{X: 0, Y: 1}, nil)},
+			rotation:       math.Pi,
+			expectedHealth: []int{100 - consts.BladeDmg},
+		},
 		"Blade intersects two players": {
 			blade:          NewBlade(NewPlayer("0", 0, &Point{X: 0, Y: 0}, nil)),
 			otherPlayers:   []*Player{NewPlayer("1", 0, &Point{X: 0, Y: 1}, nil), NewPlayer("2", 0, &Point{X: 0, Y: 1}, nil)},
@@ -148,7 +157,7 @@ func TestBladeIntersection(t *testing.T) {
 
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
-			tt.blade.Update(tt.otherPlayers, &tt.rotation)
+			tt.blade.swing(spaceOf(tt.otherPlayers...), tt.rotation)
 			for i := range tt.otherPlayers {
 				if tt.otherPlayers[i].health != tt.expectedHealth[i] {
 					t.Errorf("Player[%d] health mismatch: got %d, want %d", i, tt.otherPlayers[i].health, tt.expectedHealth[i])
@@ -158,6 +167,63 @@ func TestBladeIntersection(t *testing.T) {
 	}
 }
 
+func TestBladeRotationSpeed(t *testing.T) {
+	tests := map[string]struct {
+		targets  []float64
+		expected []float64
+	}{
+		"Turns at the rotation speed": {targets: []float64{math.Pi}, expected: []float64{math.Pi / 2, math.Pi, math.Pi}},
+		"Turns the shortest way":      {targets: []float64{-math.Pi / 2}, expected: []float64{-math.Pi / 2}},
+		"Target one turn away":        {targets: []float64{2*math.Pi + 0.5}, expected: []float64{0.5}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rules := DefaultGameRules()
+			rules.BladeRotationSpeed = 90
+
+			blade := NewBlade(newPlayer("owner", 0, &Point{X: 10, Y: 10}, nil, rules))
+			for i, want := range tt.expected {
+				controls := &Controls{}
+				if i < len(tt.targets) {
+					controls.RotateBlade = &tt.targets[i]
+				}
+
+				blade.Fire(controls)
+				blade.Update(spaceOf(), 1)
+				if math.Abs(blade.collider.Rotation-want) > 1e-9 {
+					t.Errorf("Update %d: rotation = %v, want %v", i, blade.collider.Rotation, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBladeTargetClearedOnSwitch(t *testing.T) {
+	rules := DefaultGameRules()
+	rules.BladeRotationSpeed = 45
+
+	p := newPlayer("owner", 0, &Point{X: 10, Y: 10}, nil, rules)
+	p.currentWeapon = PlayerWeaponBlade
+	space := spaceOf(p)
+
+	target := math.Pi
+	p.Controls.RotateBlade = &target
+	p.HandleWeapon(space, 1)
+
+	canon := PlayerWeaponCanon
+	p.Controls.SwitchWeapon = &canon
+	p.HandleWeapon(space, 1)
+
+	// The blade still turns during the tick of the switch, then stops.
+	p.Controls.SwitchWeapon = nil
+	p.HandleWeapon(space, 1)
+
+	if rotation := p.blade().Rotation(); math.Abs(rotation-math.Pi/2) > 1e-9 {
+		t.Errorf("Rotation = %v, want the blade to stop at %v after the switch", rotation, math.Pi/2)
+	}
+}
+
 func TestBladeIntersectoionFuzzing(t *testing.T) {
 	const numTests = 10_000
 
@@ -176,7 +242,7 @@ func TestBladeIntersectoionFuzzing(t *testing.T) {
 
 			rotation := rand.Float64() * 2 * math.Pi
 			rotation = math.Pi / 4.0
-			blade.Update([]*Player{enemy}, &rotation)
+			blade.swing(spaceOf(enemy), rotation)
 
 			if distance > ((consts.PlayerSize/2.0 + consts.BladeSize) + math.Cos(math.Pi/4.0)) {
 				if enemy.health != 100 {
@@ -186,3 +252,375 @@ func TestBladeIntersectoionFuzzing(t *testing.T) {
 		})
 	}
 }
+
+func TestCannonSweptCollisions(t *testing.T) {
+	rules := DefaultGameRules()
+	rules.ProjectileSpeed = 1000
+
+	tests := map[string]struct {
+		walls          []*Collider
+		enemyPosition  Point
+		expectedHealth int
+		stopX          float64
+	}{
+		"Thin player not tunneled": {
+			enemyPosition:  Point{X: 50, Y: 5},
+			expectedHealth: 100 - consts.ProjectileDmg,
+			stopX:          50 - consts.PlayerSize/2.0 - rules.ProjectileSize/2,
+		},
+		"Wall stops the projectile": {
+			walls:          []*Collider{{Points: []*Point{{X: 30, Y: 0}, {X: 30, Y: 10}}}},
+			enemyPosition:  Point{X: 50, Y: 5},
+			expectedHealth: 100,
+			stopX:          30 - rules.ProjectileSize/2,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			owner := NewPlayer("owner", 0, &Point{X: 5, Y: 5}, nil)
+			owner.rules = rules
+			enemy := NewPlayer("enemy", 0, &tt.enemyPosition, nil)
+
+			space := spaceOf(owner, enemy)
+			space.SetWalls(tt.walls)
+
+			cannon := NewCanon(owner)
+			cannon.ShootAt(Point{X: 95, Y: 5})
+			projectile := cannon.Projectiles[0]
+			cannon.Update(space, 1)
+
+			if enemy.health != tt.expectedHealth {
+				t.Errorf("Enemy health = %d, want %d", enemy.health, tt.expectedHealth)
+			}
+
+			if projectile.IsAlive() || math.Abs(projectile.Position.X-tt.stopX) > 1e-9 {
+				t.Errorf("Expected the projectile to stop at x = %v, got %v (alive: %v)", tt.stopX, projectile.Position.X, projectile.IsAlive())
+			}
+		})
+	}
+}
+
+func TestFriendlyFire(t *testing.T) {
+	tests := map[string]struct {
+		team           string
+		friendlyFire   bool
+		expectedHealth int
+		expectedScore  int
+	}{
+		"Enemy hit":                  {team: "blue", expectedHealth: 100 - consts.BladeDmg, expectedScore: consts.ScoreOnHitWithBlade},
+		"Teammate spared":            {team: "red", expectedHealth: 100},
+		"Teammate hit without score": {team: "red", friendlyFire: true, expectedHealth: 100 - consts.BladeDmg},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rules := DefaultGameRules()
+			rules.FriendlyFire = tt.friendlyFire
+
+			owner := newPlayer("owner", 0, &Point{X: 0, Y: 0}, nil, rules)
+			owner.Team = "red"
+			other := newPlayer("other", 0, &Point{X: 1, Y: 0}, nil, rules)
+			other.Team = tt.team
+
+			rotation := 0.0
+			NewBlade(owner).swing(spaceOf(other), rotation)
+
+			if other.health != tt.expectedHealth || owner.score != tt.expectedScore {
+				t.Errorf("Health and score = (%d, %d), want (%d, %d)", other.health, owner.score, tt.expectedHealth, tt.expectedScore)
+			}
+		})
+	}
+}
+
+func TestKillCredit(t *testing.T) {
+	tests := map[string]struct {
+		team          string
+		friendlyFire  bool
+		finishWithDmg bool
+		adminKill     bool
+		expectedScore int
+	}{
+		"Killing blow":               {team: "blue", expectedScore: consts.ScoreOnHitWithBlade + consts.ScoreOnKill},
+		"Last attacker credited":     {team: "blue", finishWithDmg: true, expectedScore: consts.ScoreOnHitWithBlade + consts.ScoreOnKill},
+		"Teammate killed for free":   {team: "red", friendlyFire: true},
+		"Teammate finished for free": {team: "red", friendlyFire: true, finishWithDmg: true},
+		"Admin kill not credited":    {team: "blue", adminKill: true, expectedScore: consts.ScoreOnHitWithBlade},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			rules := DefaultGameRules()
+			rules.FriendlyFire = tt.friendlyFire
+
+			owner := newPlayer("owner", 0, &Point{X: 0, Y: 0}, nil, rules)
+			owner.Team = "red"
+			other := newPlayer("other", 0, &Point{X: 1, Y: 0}, nil, rules)
+			other.Team = tt.team
+			other.health = consts.BladeDmg
+			if tt.finishWithDmg || tt.adminKill {
+				other.health++
+			}
+
+			rotation := 0.0
+			NewBlade(owner).swing(spaceOf(other), rotation)
+			if tt.finishWithDmg {
+				other.TakeDmg(1)
+			}
+			if tt.adminKill {
+				other.Kill()
+			}
+
+			if other.IsAlive() || owner.score != tt.expectedScore {
+				t.Errorf("Alive and score = (%v, %d), want (false, %d)", other.IsAlive(), owner.score, tt.expectedScore)
+			}
+		})
+	}
+}
+
+func TestCannonTrigger(t *testing.T) {
+	rules := DefaultGameRules()
+	rules.CannonCooldown = 0.5
+	rules.CannonMagazine = 2
+	rules.CannonReloadTime = 1
+	rules.Tickrate = 10
+
+	owner := newPlayer("owner", 0, &Point{X: 10, Y: 10}, nil, rules)
+	cannon := NewCanon(owner)
+	space := spaceOf(owner)
+
+	steps := []struct {
+		dt       float64
+		fires    bool
+		cooldown uint16
+		ammo     int16
+	}{
+		{dt: 0, fires: true, cooldown: 5, ammo: 1},
+		{dt: 0.2, fires: false, cooldown: 3, ammo: 1},
+		{dt: 0.3, fires: true, cooldown: 10, ammo: 0},
+		{dt: 0.5, fires: false, cooldown: 5, ammo: 0},
+		{dt: 0.5, fires: true, cooldown: 5, ammo: 1},
+	}
+
+	for i, step := range steps {
+		cannon.Update(space, step.dt)
+
+		before := len(cannon.Projectiles)
+		controls := &Controls{Shoot: &Point{X: 90, Y: 10}}
+		cannon.Fire(controls)
+
+		if fired := len(cannon.Projectiles) > before; fired != step.fires {
+			t.Errorf("Step %d: fired = %v, want %v", i, fired, step.fires)
+		}
+		if controls.Shoot != nil {
+			t.Errorf("Step %d: expected the target to be dropped", i)
+		}
+
+		want := TriggerState{Weapon: PlayerWeaponCanon, Cooldown: step.cooldown, Ammo: step.ammo}
+		if got := cannon.Trigger().State(PlayerWeaponCanon, rules.Tickrate); got != want {
+			t.Errorf("Step %d: state = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestProjectileCap(t *testing.T) {
+	rules := DefaultGameRules()
+	rules.MaxProjectiles = rules.ShotgunPellets
+	rules.CannonCooldown = 0
+
+	owner := newPlayer("owner", 0, &Point{X: 10, Y: 10}, nil, rules)
+	owner.arm()
+	cannon, shotgun := owner.cannon(), owner.Weapon(PlayerWeaponShotgun).(*Shotgun)
+
+	// The pellets of the shotgun would go over the cap with the projectile of the cannon.
+	cannon.Fire(&Controls{Shoot: &Point{X: 90, Y: 10}})
+	shotgun.Fire(&Controls{Shoot: &Point{X: 90, Y: 10}})
+	if len(shotgun.Pellets) != 0 || !shotgun.Trigger().Ready() {
+		t.Errorf("Expected the shotgun to be refused, %d pellets fired", len(shotgun.Pellets))
+	}
+
+	for i := 0; i < rules.MaxProjectiles; i++ {
+		cannon.Fire(&Controls{Shoot: &Point{X: 90, Y: 10}})
+	}
+	if owner.liveProjectiles() != rules.MaxProjectiles {
+		t.Errorf("Expected %d projectiles, got %d", rules.MaxProjectiles, owner.liveProjectiles())
+	}
+}
+
+func TestShotgunSpread(t *testing.T) {
+	owner := NewPlayer("owner", 0, &Point{X: 10, Y: 10}, nil)
+	enemy := NewPlayer("enemy", 0, &Point{X: 15, Y: 10}, nil)
+
+	shotgun := NewShotgun(owner)
+	shotgun.Fire(&Controls{Shoot: &Point{X: 50, Y: 10}})
+	if len(shotgun.Pellets) != consts.ShotgunPellets {
+		t.Fatalf("Expected %d pellets, got %d", consts.ShotgunPellets, len(shotgun.Pellets))
+	}
+
+	// The outer pellets leave at the edges of the spread, the others are evenly spaced.
+	for i, pellet := range shotgun.Pellets {
+		angle := math.Atan2(pellet.Destination.Y-10, pellet.Destination.X-10) * 180 / math.Pi
+		want := consts.ShotgunSpread * (float64(i)/float64(consts.ShotgunPellets-1) - 0.5)
+		if math.Abs(angle-want) > 1e-9 {
+			t.Errorf("Pellet %d fired at %v degrees, want %v", i, angle, want)
+		}
+	}
+
+	shotgun.Update(spaceOf(owner, enemy), consts.ShotgunRange/consts.ProjectileSpeed+1)
+
+	// At a distance of 5, only the three pellets in the middle hit the enemy.
+	if enemy.health != 100-3*consts.ShotgunDmg || owner.score != 3*consts.ScoreOnHitWithShotgun {
+		t.Errorf("Health and score = (%d, %d), want (%d, %d)", enemy.health, owner.score,
+			100-3*consts.ShotgunDmg, 3*consts.ScoreOnHitWithShotgun)
+	}
+
+	if len(shotgun.Pellets) != 0 {
+		t.Errorf("Expected the pellets to stop after their range, %d left", len(shotgun.Pellets))
+	}
+}
+
+func TestMineLayer(t *testing.T) {
+	owner := NewPlayer("owner", 0, &Point{X: 10, Y: 10}, nil)
+	enemy := NewPlayer("enemy", 0, &Point{X: 20, Y: 10}, nil)
+	bystander := NewPlayer("bystander", 0, &Point{X: 10, Y: 20}, nil)
+
+	mines := NewMineLayer(owner)
+	for i := 0; i < consts.MaxMines+1; i++ {
+		mines.Fire(&Controls{Shoot: &Point{}})
+		mines.Update(spaceOf(owner), consts.MineCooldown)
+	}
+	if len(mines.Mines) != consts.MaxMines {
+		t.Fatalf("Expected %d mines, got %d", consts.MaxMines, len(mines.Mines))
+	}
+
+	mines.Update(spaceOf(owner, enemy, bystander), 1)
+	if len(mines.Mines) != consts.MaxMines || owner.health != 100 {
+		t.Fatalf("Expected the mines to ignore their owner and the enemies out of reach")
+	}
+
+	enemy.SetPosition(Point{X: 11, Y: 10})
+	mines.Update(spaceOf(owner, enemy, bystander), 1)
+
+	// The mines were dropped at the same place, all of them go off.
+	if want := 100 - consts.MaxMines*consts.MineDmg; enemy.health != want || bystander.health != 100 {
+		t.Errorf("Health of the enemy and the bystander = (%d, %d), want (%d, 100)", enemy.health, bystander.health, want)
+	}
+
+	if len(mines.Mines) != 0 {
+		t.Errorf("Expected the mines to explode, %d left", len(mines.Mines))
+	}
+}
+
+func TestGrenadeLauncher(t *testing.T) {
+	tests := map[string]struct {
+		walls          []*Collider
+		expectedHealth int
+	}{
+		"Enemy caught in the explosion": {expectedHealth: 100 - consts.GrenadeDmg},
+		"Enemy behind a wall": {
+			walls:          []*Collider{{Points: []*Point{{X: 15, Y: 11}, {X: 25, Y: 11}}}},
+			expectedHealth: 100,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			owner := NewPlayer("owner", 0, &Point{X: 10, Y: 10}, nil)
+			near := NewPlayer("near", 0, &Point{X: 20, Y: 12}, nil)
+			far := NewPlayer("far", 0, &Point{X: 30, Y: 10}, nil)
+
+			space := spaceOf(owner, near, far)
+			space.SetWalls(tt.walls)
+
+			grenades := NewGrenadeLauncher(owner)
+			grenades.Fire(&Controls{Shoot: &Point{X: 90, Y: 10}})
+			if dest := *grenades.Grenades[0].Destination; dest != (Point{X: 10 + consts.GrenadeRange, Y: 10}) {
+				t.Fatalf("Expected the grenade to land at its range, got %v", dest)
+			}
+
+			grenades.Update(space, consts.GrenadeRange/consts.ProjectileSpeed+1)
+
+			if near.health != tt.expectedHealth || far.health != 100 {
+				t.Errorf("Health of the players = (%d, %d), want (%d, 100)", near.health, far.health, tt.expectedHealth)
+			}
+
+			if len(grenades.Grenades) != 0 {
+				t.Errorf("Expected the grenade to explode")
+			}
+		})
+	}
+}
+
+// fakeWeapon counts how many times it is fired.
+type fakeWeapon struct {
+	fired int
+}
+
+func (f *fakeWeapon) Fire(controls *Controls)                    { f.fired++ }
+func (f *fakeWeapon) Update(space *SpatialGrid, dt float64)      {}
+func (f *fakeWeapon) Fired() []*Projectile                       { return nil }
+func (f *fakeWeapon) Encode(w codec.Writer, v *Visibility) error { return w.WriteInt32(0) }
+
+func TestRegisterWeapon(t *testing.T) {
+	registered := append([]WeaponType{}, weaponTypes...)
+	t.Cleanup(func() { weaponTypes = registered })
+
+	const custom PlayerWeapon = 42
+	RegisterWeapon(WeaponType{ID: custom, Name: "custom", New: func(*Player) Weapon { return &fakeWeapon{} }})
+
+	p := NewPlayer("owner", 0, &Point{X: 10, Y: 10}, nil)
+	space := spaceOf(p)
+
+	tests := []struct {
+		weapon   PlayerWeapon
+		expected PlayerWeapon
+	}{
+		{weapon: custom, expected: custom},
+		{weapon: 99, expected: custom},
+		{weapon: PlayerWeaponNone, expected: PlayerWeaponNone},
+	}
+
+	for _, tt := range tests {
+		p.Controls = Controls{SwitchWeapon: &tt.weapon}
+		p.HandleWeapon(space, 0)
+		if p.CurrentWeapon() != tt.expected {
+			t.Errorf("Switching to %d: current weapon = %d, want %d", tt.weapon, p.CurrentWeapon(), tt.expected)
+		}
+	}
+
+	p.SetCurrentWeapon(custom)
+	p.Controls = Controls{}
+	p.HandleWeapon(space, 0)
+
+	if fired := p.Weapon(custom).(*fakeWeapon).fired; fired != 1 {
+		t.Errorf("Expected the weapon held to be fired once, got %d", fired)
+	}
+
+	if weaponName(custom) != "custom" {
+		t.Errorf("weaponName() = %q, want custom", weaponName(custom))
+	}
+}
+
+func TestEncodeWithoutCannonAndBlade(t *testing.T) {
+	p := NewPlayer("owner", 0, &Point{X: 10, Y: 10}, nil)
+	delete(p.weapons, PlayerWeaponCanon)
+	delete(p.weapons, PlayerWeaponBlade)
+
+	w := codec.NewByteWriter(binary.LittleEndian)
+	if err := p.Encode(w); err != nil {
+		t.Fatalf("Encode() error %v", err)
+	}
+
+	var decoded PlayerInfo
+	if err := decoded.Decode(codec.NewByteReader(w.Bytes(), binary.LittleEndian)); err != nil {
+		t.Fatalf("Decode() error %v", err)
+	}
+
+	if len(decoded.Projectiles) != 0 {
+		t.Errorf("Expected no projectiles, got %d", len(decoded.Projectiles))
+	}
+
+	if decoded.Blade.Start != *p.Position || decoded.Blade.End != *p.Position {
+		t.Errorf("Expected a blade without length at the player, got %+v", decoded.Blade)
+	}
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"math"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
+)
+
+// Zone is a safe zone closing on its center. Its radius shrinks linearly from the start
+// radius to the end radius over a number of ticks, then stays the same.
+type Zone struct {
+	Center Point
+
+	startRadius float64
+	endRadius   float64
+	ticks       int
+	elapsed     int
+}
+
+// NewZone creates a zone of startRadius around center, closing to endRadius in ticks.
+func NewZone(center Point, startRadius, endRadius float64, ticks int) *Zone {
+	return &Zone{Center: center, startRadius: startRadius, endRadius: endRadius, ticks: ticks}
+}
+
+// NewClosingZone creates the zone of the point rush stage: it covers the whole map
+// around center and closes to the end radius of the rules by the end of the round.
+func NewClosingZone(center Point, mapSize float64, rules *GameRules) *Zone {
+	start := 0.0
+	for _, corner := range []Point{{X: 0, Y: 0}, {X: mapSize, Y: 0}, {X: 0, Y: mapSize}, {X: mapSize, Y: mapSize}} {
+		start = math.Max(start, math.Hypot(corner.X-center.X, corner.Y-center.Y))
+	}
+
+	return NewZone(center, math.Max(start, rules.ZoneEndRadius), rules.ZoneEndRadius,
+		rules.TicksPerRound-rules.TicksPointRushStage)
+}
+
+// Radius returns the current radius of the zone.
+func (z *Zone) Radius() float64 {
+	if z.elapsed >= z.ticks {
+		return z.endRadius
+	}
+	return z.startRadius + (z.endRadius-z.startRadius)*float64(z.elapsed)/float64(z.ticks)
+}
+
+// Contains returns true if p is inside the zone.
+func (z *Zone) Contains(p Point) bool {
+	return math.Hypot(p.X-z.Center.X, p.Y-z.Center.Y) <= z.Radius()
+}
+
+// tick closes the zone by a tick.
+func (z *Zone) tick() {
+	z.elapsed = min(z.elapsed+1, z.ticks)
+}
+
+// Snapshot returns the state of the zone sent to the clients, nil for no zone.
+func (z *Zone) Snapshot() *ZoneSnapshot {
+	if z == nil {
+		return nil
+	}
+
+	return &ZoneSnapshot{
+		Center: Quantize(z.Center),
+		Radius: uint16(math.Min(math.Round(z.Radius()*PositionScale), math.MaxUint16)),
+	}
+}
+
+// encodeZone writes whether there is a zone, then its center and radius.
+func encodeZone(w codec.Writer, z *Zone) (err error) {
+	if err = w.WriteBool(z != nil); err != nil || z == nil {
+		return
+	}
+
+	if err = z.Center.Encode(w); err != nil {
+		return
+	}
+
+	return w.WriteFloat64(z.Radius())
+}
+
+// ZoneInfo is the safe zone of MessageGameState.
+type ZoneInfo struct {
+	Center Point
+	Radius float64
+}
+
+// decodeZone reads the zone written by encodeZone, nil for no zone.
+func decodeZone(r codec.Reader) (zone *ZoneInfo, err error) {
+	var ok bool
+	if ok, err = r.ReadBool(); err != nil || !ok {
+		return
+	}
+
+	zone = &ZoneInfo{}
+	if err = zone.Center.Decode(r); err != nil {
+		return
+	}
+
+	zone.Radius, err = r.ReadFloat64()
+	return
+}
+
+// ZoneSnapshot is the state of the safe zone sent to the clients.
+type ZoneSnapshot struct {
+	Center QuantizedPoint
+
+	// Radius is the radius of the zone in 1/PositionScale of a unit.
+	Radius uint16
+}
+
+// encodeZoneSnapshot writes whether there is a zone, then its center and radius.
+func encodeZoneSnapshot(w codec.Writer, z *ZoneSnapshot) (err error) {
+	if err = w.WriteBool(z != nil); err != nil || z == nil {
+		return
+	}
+
+	if err = z.Center.Encode(w); err != nil {
+		return
+	}
+
+	return w.WriteUint16(z.Radius)
+}
+
+// decodeZoneSnapshot reads the zone written by encodeZoneSnapshot, nil for no zone.
+func decodeZoneSnapshot(r codec.Reader) (zone *ZoneSnapshot, err error) {
+	var ok bool
+	if ok, err = r.ReadBool(); err != nil || !ok {
+		return
+	}
+
+	zone = &ZoneSnapshot{}
+	if err = zone.Center.Decode(r); err != nil {
+		return
+	}
+
+	zone.Radius, err = r.ReadUint16()
+	return
+}
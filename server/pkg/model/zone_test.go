@@ -0,0 +1,74 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func TestZoneRadius(t *testing.T) {
+	tests := map[string]struct {
+		ticks    int
+		expected float64
+	}{
+		"Start":        {ticks: 0, expected: 100},
+		"Halfway":      {ticks: 5, expected: 60},
+		"End":          {ticks: 10, expected: 20},
+		"Stays at end": {ticks: 15, expected: 20},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			zone := NewZone(Point{X: 50, Y: 50}, 100, 20, 10)
+			for i := 0; i < tt.ticks; i++ {
+				zone.tick()
+			}
+
+			if zone.Radius() != tt.expected {
+				t.Errorf("Radius() = %v, want %v", zone.Radius(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewClosingZone(t *testing.T) {
+	rules := DefaultGameRules()
+	zone := NewClosingZone(Point{X: 10, Y: 20}, 100, rules)
+
+	for _, corner := range []Point{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 0, Y: 100}, {X: 100, Y: 100}} {
+		if !zone.Contains(corner) {
+			t.Errorf("Expected the zone to cover the corner %v", corner)
+		}
+	}
+
+	if want := math.Hypot(90, 80); zone.Radius() != want {
+		t.Errorf("Radius() = %v, want %v", zone.Radius(), want)
+	}
+
+	if want := rules.TicksPerRound - rules.TicksPointRushStage; zone.ticks != want {
+		t.Errorf("Closes in %d ticks, want %d", zone.ticks, want)
+	}
+}
+
+func TestUpdateZone(t *testing.T) {
+	gs := NewGameState(nil)
+	inside := NewPlayer("inside", 0, &Point{X: 50, Y: 50}, nil)
+	outside := NewPlayer("outside", 0, &Point{X: 90, Y: 90}, nil)
+	shielded := NewPlayer("shielded", 0, &Point{X: 10, Y: 10}, nil)
+	shielded.applyEffect(EffectShield)
+	players := []*Player{inside, outside, shielded}
+
+	gs.UpdateZone(players)
+	if inside.health != inside.rules.PlayerHealth || outside.health != outside.rules.PlayerHealth {
+		t.Fatalf("Expected no damage without a zone")
+	}
+
+	gs.SetZone(NewZone(Point{X: 50, Y: 50}, 10, 10, 1))
+	gs.UpdateZone(players)
+
+	if inside.health != inside.rules.PlayerHealth {
+		t.Errorf("Health inside the zone = %d, want %d", inside.health, inside.rules.PlayerHealth)
+	}
+	if want := outside.rules.PlayerHealth - gs.rules.ZoneDmg; outside.health != want || shielded.health != want {
+		t.Errorf("Health outside the zone = (%d, %d shielded), want %d", outside.health, shielded.health, want)
+	}
+}
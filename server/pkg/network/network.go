@@ -13,12 +13,14 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/utils"
 	"github.com/gorilla/websocket"
 )
 
@@ -28,8 +30,9 @@ type Network struct {
 	// WebSocket connections.
 	upgrader websocket.Upgrader
 
-	// register is a function called when a new connection is established.
-	register func(conn model.Connection, token string) error
+	// register is a function called when a new connection is established, with the
+	// admin token and the room requested by the client.
+	register func(conn model.Connection, token string, room string) error
 
 	// uregister is a function called when a connection is closed.
 	uregister func(conn model.Connection)
@@ -42,6 +45,10 @@ type Network struct {
 
 	// connected is used to keep track of the currently used tokens.
 	connected sync.Map
+
+	// plainHTTP allows serving without TLS when no certificate is found, for local
+	// development only since the admin tokens travel in the query strings.
+	plainHTTP bool
 }
 
 // NewNetwork creates a new network server with the specified address and port.
@@ -68,7 +75,7 @@ func (n *Network) Address() string {
 }
 
 // SetRegisterFunc sets the function to be called when a new connection is established.
-func (n *Network) SetRegisterFunc(f func(conn model.Connection, token string) error) {
+func (n *Network) SetRegisterFunc(f func(conn model.Connection, token string, room string) error) {
 	n.register = f
 }
 
@@ -78,9 +85,9 @@ func (n *Network) SetUnregisterFunc(f func(conn model.Connection)) {
 }
 
 // Register registers a new connection by invoking the specified register function.
-func (n *Network) Register(conn model.Connection, token string) error {
+func (n *Network) Register(conn model.Connection, token string, room string) error {
 	if n.register != nil {
-		return n.register(conn, token)
+		return n.register(conn, token, room)
 	}
 
 	return nil
@@ -122,8 +129,9 @@ func (n *Network) Init() {
 			return
 		}
 
-		if err := n.register(NewConnection(ws, token), adminToken); err != nil {
-			ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Unhautorized"))
+		room := r.URL.Query().Get("room")
+		if err := n.register(NewConnection(ws, token), adminToken, room); err != nil {
+			ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()))
 			http.Error(w, "Unhautorized", http.StatusUnauthorized)
 			n.connected.Delete(token)
 			return
@@ -131,6 +139,12 @@ func (n *Network) Init() {
 	})
 }
 
+// AllowPlainHTTP lets the server fall back to plain HTTP when no certificate is found.
+// Without it, a missing certificate prevents the server from starting.
+func (n *Network) AllowPlainHTTP(allow bool) {
+	n.plainHTTP = allow
+}
+
 // Run starts the network server listening for incoming connections on the specified IP
 // address and port.
 // Returns an error if the server cannot start.
@@ -145,8 +159,13 @@ func (n *Network) Run() error {
 	certFile := fmt.Sprintf("%s/server.crt", certDir)
 	keyFile := fmt.Sprintf("%s/server.key", certDir)
 
-	return http.ServeTLS(listener, nil, certFile, keyFile)
+	// Without certificates, the server falls back to plain HTTP only when allowed.
+	if _, err := os.Stat(certFile); err != nil && n.plainHTTP {
+		utils.Log("network", "run", "no certificate found in %s, serving without TLS", certDir)
+		return http.Serve(listener, nil)
+	}
 
+	return http.ServeTLS(listener, nil, certFile, keyFile)
 }
 
 // Connection is an implementation of the model.Connection interface for WebSocket connections.
@@ -194,6 +213,14 @@ func (c *Connection) Close(writeWait time.Duration, graceful bool) {
 	c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 }
 
+// Reject closes the connection with a protocol error close message holding the reason,
+// then closes the underlying WebSocket connection.
+func (c *Connection) Reject(writeWait time.Duration, reason string) {
+	message := websocket.FormatCloseMessage(websocket.CloseProtocolError, reason)
+	_ = c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+	c.conn.Close()
+}
+
 // PrepareRead configures the connection for reading by setting the maximum message size,
 // the pong wait timeout, and the pong handler. This method should be called before reading
 // each message.
@@ -10,6 +10,10 @@ import (
 type BinaryProtocol struct {
 	EncodeHandlers map[model.MessageType]func(w *codec.ByteWriter, message *model.ClientMessage)
 	DecodeHandlers map[model.MessageType]func(r *codec.ByteReader, message *model.ClientMessage)
+
+	// BinaryActionHandlers replace DecodeHandlers for the clients which negotiated
+	// ActionEncodingBinary.
+	BinaryActionHandlers map[model.MessageType]func(r *codec.ByteReader, message *model.ClientMessage)
 }
 
 func (b BinaryProtocol) Encode(message *model.ClientMessage) []byte {
@@ -25,13 +29,25 @@ func (b BinaryProtocol) Encode(message *model.ClientMessage) []byte {
 }
 
 func (b BinaryProtocol) Decode(data []byte) model.ClientMessage {
+	return b.DecodeWith(data, model.ActionEncodingJSON)
+}
+
+// DecodeWith decodes a message sent by a client whose actions use the given encoding.
+func (b BinaryProtocol) DecodeWith(data []byte, encoding model.ActionEncoding) model.ClientMessage {
 	reader := codec.NewByteReader(data[1:], binary.LittleEndian)
 
 	msg := model.ClientMessage{
 		MessageType: model.MessageType(data[0]),
 	}
 
-	if handler, ok := b.DecodeHandlers[msg.MessageType]; ok {
+	handler, ok := b.DecodeHandlers[msg.MessageType]
+	if encoding == model.ActionEncodingBinary {
+		if binaryHandler, found := b.BinaryActionHandlers[msg.MessageType]; found {
+			handler, ok = binaryHandler, true
+		}
+	}
+
+	if ok {
 		handler(reader, &msg)
 	}
 
@@ -0,0 +1,528 @@
+package replay
+
+// Package replay provides the file format used to record matches and play them back.
+// A replay stores the game seed and rules, the players present when the game started
+// and, for every tick, the players who joined, the actions applied to each player and the
+// commands changing the game outside of those actions, such as admin kills or
+// disconnections. Since the simulation is fully determined by the seed and the inputs,
+// re-running it from a replay produces the same game, map included.
+//
+// +-------------------+------------------------------------------+
+// |          Binary Representation                               |
+// +-------------------+------------------------------------------+
+// | 6 bytes           | magic "JDISRP"                           |
+// | 1 byte  (uint8)   | version                                  |
+// | 8 bytes (int64)   | game seed                                |
+// | n bytes (json)    | game rules (4 bytes size + content)      |
+// | 4 bytes (int32)   | number of players at game start          |
+// +-------------------+------------------------------------------+
+// | For each player at game start                                |
+// +-------------------+------------------------------------------+
+// | n bytes (string)  | player name (read until \0)              |
+// | 4 bytes (int32)   | player color                             |
+// | n bytes (string)  | player team                              |
+// | 1 byte  (uint8)   | player current weapon                    |
+// | n bytes (json)    | player controls (4 bytes size + content) |
+// +-------------------+------------------------------------------+
+// | For each tick until the end of the file                      |
+// +-------------------+------------------------------------------+
+// | 4 bytes (int32)   | tick                                     |
+// | 4 bytes (int32)   | number of joins                          |
+// | For each join: name, color, team and position                |
+// | 4 bytes (int32)   | number of actions                        |
+// | For each action: player name and controls (json)             |
+// | 4 bytes (int32)   | number of commands                       |
+// | For each command: kind (uint8) and player name               |
+// +-------------------+------------------------------------------+
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/codec"
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+)
+
+// Version is the version of the replay format written by the Recorder.
+const Version uint8 = 1
+
+// Extension is the file extension of replay files.
+const Extension = ".replay"
+
+var magic = []byte("JDISRP")
+
+// Minimum encoded sizes of the entries of a replay, used to bound the counts read from a
+// file before anything is allocated.
+const (
+	playerMinSize  = 1 + 4 + 1 + 1 + 4
+	joinMinSize    = 1 + 4 + 1 + 16
+	actionMinSize  = 1 + 4
+	commandMinSize = 1 + 1
+)
+
+// ErrInvalidReplay is returned when a file is not a replay or was written with an
+// unsupported version.
+var ErrInvalidReplay = errors.New("invalid replay file")
+
+// Player describes a player present when the game started.
+type Player struct {
+	Nickname string
+	Color    int
+	Team     string
+	Weapon   model.PlayerWeapon
+	Controls model.Controls
+}
+
+// Join describes a player who joined while the game was in progress.
+type Join struct {
+	Nickname string
+	Color    int
+	Team     string
+	Position model.Point
+}
+
+// Action is a player action applied during a tick.
+type Action struct {
+	Nickname string
+	Controls model.Controls
+}
+
+// CommandKind is the kind of a Command.
+type CommandKind uint8
+
+const (
+	// CommandKill is an admin killing a player.
+	CommandKill CommandKind = iota + 1
+
+	// CommandFreeze and CommandUnfreeze are an admin freezing and unfreezing the game.
+	CommandFreeze
+	CommandUnfreeze
+
+	// CommandDisconnect and CommandReconnect are a player losing its connection and
+	// connecting again.
+	CommandDisconnect
+	CommandReconnect
+)
+
+// Command is a change of the game made outside of the player actions during a tick.
+// Nickname is empty for the commands which do not target a player.
+type Command struct {
+	Kind     CommandKind
+	Nickname string
+}
+
+// Frame holds the inputs of a single tick.
+type Frame struct {
+	Tick     int32
+	Joins    []Join
+	Actions  []Action
+	Commands []Command
+}
+
+// Replay is a recorded match.
+type Replay struct {
+	Version uint8
+	Seed    int64
+	Rules   *model.GameRules
+	Players []Player
+	Frames  []Frame
+}
+
+// Recorder writes a replay file as the game progresses.
+type Recorder struct {
+	file  *os.File
+	w     *bufio.Writer
+	frame Frame
+	tick  int32
+}
+
+// Create creates the replay file at path and writes its header.
+func Create(path string, seed int64, rules *model.GameRules, players []Player) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{file: file, w: bufio.NewWriter(file)}
+
+	writer := codec.NewByteWriter(binary.LittleEndian)
+	if err = encodeHeader(writer, seed, rules, players); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if _, err = r.w.Write(writer.Bytes()); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Join records a player joining the game during the current tick.
+func (r *Recorder) Join(nickname string, color int, team string, pos model.Point) {
+	r.frame.Joins = append(r.frame.Joins, Join{Nickname: nickname, Color: color, Team: team, Position: pos})
+}
+
+// Action records an action applied to a player during the current tick.
+func (r *Recorder) Action(nickname string, controls model.Controls) {
+	r.frame.Actions = append(r.frame.Actions, Action{Nickname: nickname, Controls: controls})
+}
+
+// Command records a command applied during the current tick.
+func (r *Recorder) Command(kind CommandKind, nickname string) {
+	r.frame.Commands = append(r.frame.Commands, Command{Kind: kind, Nickname: nickname})
+}
+
+// EndTick writes the inputs recorded since the previous tick.
+func (r *Recorder) EndTick() error {
+	r.tick++
+	r.frame.Tick = r.tick
+
+	writer := codec.NewByteWriter(binary.LittleEndian)
+	err := r.frame.Encode(writer)
+	r.frame = Frame{}
+	if err != nil {
+		return err
+	}
+
+	// A frame which does not fit in the buffer is written in a single call, so that the
+	// file always ends with a complete frame.
+	data := writer.Bytes()
+	if len(data) > r.w.Available() {
+		if err = r.w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	_, err = r.w.Write(data)
+	return err
+}
+
+// Close flushes and closes the replay file.
+func (r *Recorder) Close() error {
+	if err := r.w.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// Open reads the replay file at path.
+func Open(path string) (*Replay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	replay := &Replay{}
+	if err = replay.Decode(fullReader{codec.NewByteReader(data, binary.LittleEndian)}); err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+func encodeHeader(w codec.Writer, seed int64, rules *model.GameRules, players []Player) (err error) {
+	if _, err = w.WriteBytes(magic); err != nil {
+		return
+	}
+
+	if err = w.WriteUint8(Version); err != nil {
+		return
+	}
+
+	if err = w.WriteInt64(seed); err != nil {
+		return
+	}
+
+	if err = writeJSON(w, rules); err != nil {
+		return
+	}
+
+	if err = w.WriteInt32(int32(len(players))); err != nil {
+		return
+	}
+
+	for _, p := range players {
+		if err = w.WriteString(p.Nickname); err != nil {
+			return
+		}
+
+		if err = w.WriteInt32(int32(p.Color)); err != nil {
+			return
+		}
+
+		if err = w.WriteString(p.Team); err != nil {
+			return
+		}
+
+		if err = w.WriteUint8(uint8(p.Weapon)); err != nil {
+			return
+		}
+
+		if err = writeJSON(w, p.Controls); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Decode reads a complete replay, header and frames.
+func (rp *Replay) Decode(r codec.Reader) (err error) {
+	var header []byte
+	if header, err = r.ReadBytes(len(magic)); err != nil || string(header) != string(magic) {
+		return ErrInvalidReplay
+	}
+
+	if rp.Version, err = r.ReadUint8(); err != nil {
+		return
+	}
+
+	if rp.Version != Version {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidReplay, rp.Version)
+	}
+
+	if rp.Seed, err = r.ReadInt64(); err != nil {
+		return
+	}
+
+	rp.Rules = model.DefaultGameRules()
+	if err = readJSON(r, rp.Rules); err != nil {
+		return
+	}
+
+	var size int
+	if size, err = model.ReadCount(r, playerMinSize); err != nil {
+		return
+	}
+
+	rp.Players = make([]Player, size)
+	for i := range rp.Players {
+		if rp.Players[i].Nickname, err = r.ReadString(); err != nil {
+			return
+		}
+
+		var color int32
+		if color, err = r.ReadInt32(); err != nil {
+			return
+		}
+		rp.Players[i].Color = int(color)
+
+		if rp.Players[i].Team, err = r.ReadString(); err != nil {
+			return
+		}
+
+		var weapon uint8
+		if weapon, err = r.ReadUint8(); err != nil {
+			return
+		}
+		rp.Players[i].Weapon = model.PlayerWeapon(weapon)
+
+		if err = readJSON(r, &rp.Players[i].Controls); err != nil {
+			return
+		}
+	}
+
+	// The Recorder never splits a frame, so even the file of a game still being recorded
+	// ends at a frame boundary and anything else is a corrupt file.
+	rp.Frames = []Frame{}
+	for r.Len() != 0 {
+		var frame Frame
+		if err = frame.Decode(r); err != nil {
+			return fmt.Errorf("%w: frame %d: %w", ErrInvalidReplay, len(rp.Frames)+1, err)
+		}
+		rp.Frames = append(rp.Frames, frame)
+	}
+	return nil
+}
+
+// Encode writes the inputs of a tick.
+func (f *Frame) Encode(w codec.Writer) (err error) {
+	if err = w.WriteInt32(f.Tick); err != nil {
+		return
+	}
+
+	if err = w.WriteInt32(int32(len(f.Joins))); err != nil {
+		return
+	}
+
+	for _, j := range f.Joins {
+		if err = w.WriteString(j.Nickname); err != nil {
+			return
+		}
+
+		if err = w.WriteInt32(int32(j.Color)); err != nil {
+			return
+		}
+
+		if err = w.WriteString(j.Team); err != nil {
+			return
+		}
+
+		if err = j.Position.Encode(w); err != nil {
+			return
+		}
+	}
+
+	if err = w.WriteInt32(int32(len(f.Actions))); err != nil {
+		return
+	}
+
+	for _, a := range f.Actions {
+		if err = w.WriteString(a.Nickname); err != nil {
+			return
+		}
+
+		if err = writeJSON(w, a.Controls); err != nil {
+			return
+		}
+	}
+
+	if err = w.WriteInt32(int32(len(f.Commands))); err != nil {
+		return
+	}
+
+	for _, c := range f.Commands {
+		if err = w.WriteUint8(uint8(c.Kind)); err != nil {
+			return
+		}
+
+		if err = w.WriteString(c.Nickname); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Decode reads the inputs of a tick.
+func (f *Frame) Decode(r codec.Reader) (err error) {
+	if f.Tick, err = r.ReadInt32(); err != nil {
+		return
+	}
+
+	var size int
+	if size, err = model.ReadCount(r, joinMinSize); err != nil {
+		return
+	}
+
+	f.Joins = make([]Join, size)
+	for i := range f.Joins {
+		if f.Joins[i].Nickname, err = r.ReadString(); err != nil {
+			return
+		}
+
+		var color int32
+		if color, err = r.ReadInt32(); err != nil {
+			return
+		}
+		f.Joins[i].Color = int(color)
+
+		if f.Joins[i].Team, err = r.ReadString(); err != nil {
+			return
+		}
+
+		if err = f.Joins[i].Position.Decode(r); err != nil {
+			return
+		}
+	}
+
+	if size, err = model.ReadCount(r, actionMinSize); err != nil {
+		return
+	}
+
+	f.Actions = make([]Action, size)
+	for i := range f.Actions {
+		if f.Actions[i].Nickname, err = r.ReadString(); err != nil {
+			return
+		}
+
+		if err = readJSON(r, &f.Actions[i].Controls); err != nil {
+			return
+		}
+	}
+
+	if size, err = model.ReadCount(r, commandMinSize); err != nil {
+		return
+	}
+
+	f.Commands = make([]Command, size)
+	for i := range f.Commands {
+		var kind uint8
+		if kind, err = r.ReadUint8(); err != nil {
+			return
+		}
+
+		f.Commands[i].Kind = CommandKind(kind)
+		if f.Commands[i].Kind < CommandKill || f.Commands[i].Kind > CommandReconnect {
+			return fmt.Errorf("unknown command kind %d", kind)
+		}
+
+		if f.Commands[i].Nickname, err = r.ReadString(); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// fullReader fails the reads of fixed-size values past the end of the data, which the
+// codec reader completes with zeros, so that a truncated frame is detected.
+type fullReader struct {
+	codec.Reader
+}
+
+func (r fullReader) ReadInt32() (int32, error) {
+	if r.Len() < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return r.Reader.ReadInt32()
+}
+
+func (r fullReader) ReadInt64() (int64, error) {
+	if r.Len() < 8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return r.Reader.ReadInt64()
+}
+
+func (r fullReader) ReadFloat64() (float64, error) {
+	if r.Len() < 8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return r.Reader.ReadFloat64()
+}
+
+func writeJSON(w codec.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if err = w.WriteInt32(int32(len(data))); err != nil {
+		return err
+	}
+
+	_, err = w.WriteBytes(data)
+	return err
+}
+
+func readJSON(r codec.Reader, v any) error {
+	size, err := model.ReadCount(r, 1)
+	if err != nil {
+		return err
+	}
+
+	data, err := r.ReadBytes(size)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
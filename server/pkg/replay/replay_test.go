@@ -0,0 +1,181 @@
+package replay_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/capucinoxx/jdis-games-2024/pkg/model"
+	"github.com/capucinoxx/jdis-games-2024/pkg/replay"
+)
+
+func TestRecordOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game"+replay.Extension)
+	weapon := model.PlayerWeaponBlade
+	rotation := 1.5
+
+	players := []replay.Player{
+		{Nickname: "alice", Color: 12, Weapon: model.PlayerWeaponCanon, Controls: model.Controls{Dest: &model.Point{X: 1, Y: 2}}},
+		{Nickname: "bob", Color: 34, Team: "blue"},
+	}
+	frames := []replay.Frame{
+		{
+			Tick:     1,
+			Joins:    []replay.Join{},
+			Actions:  []replay.Action{{Nickname: "alice", Controls: model.Controls{Shoot: &model.Point{X: 3, Y: 4}}}},
+			Commands: []replay.Command{},
+		},
+		{
+			Tick:  2,
+			Joins: []replay.Join{{Nickname: "carol", Color: 56, Team: "blue", Position: model.Point{X: 5, Y: 6}}},
+			Actions: []replay.Action{
+				{Nickname: "bob", Controls: model.Controls{SwitchWeapon: &weapon}},
+				{Nickname: "bob", Controls: model.Controls{RotateBlade: &rotation}},
+			},
+			Commands: []replay.Command{
+				{Kind: replay.CommandKill, Nickname: "alice"},
+				{Kind: replay.CommandFreeze},
+				{Kind: replay.CommandDisconnect, Nickname: "bob"},
+			},
+		},
+	}
+
+	rules := model.DefaultGameRules()
+	rules.MapWidth = 20
+
+	recorder, err := replay.Create(path, 42, rules, players)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	for _, frame := range frames {
+		for _, join := range frame.Joins {
+			recorder.Join(join.Nickname, join.Color, join.Team, join.Position)
+		}
+		for _, action := range frame.Actions {
+			recorder.Action(action.Nickname, action.Controls)
+		}
+		for _, command := range frame.Commands {
+			recorder.Command(command.Kind, command.Nickname)
+		}
+		if err = recorder.EndTick(); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+	}
+
+	if err = recorder.Close(); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	rep, err := replay.Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if rep.Version != replay.Version || rep.Seed != 42 {
+		t.Errorf("Expected version %d and seed 42, got %d and %d", replay.Version, rep.Version, rep.Seed)
+	}
+
+	if !reflect.DeepEqual(rep.Rules, rules) {
+		t.Errorf("Rules mismatch: got %+v, want %+v", rep.Rules, rules)
+	}
+
+	if !reflect.DeepEqual(rep.Players, players) {
+		t.Errorf("Players mismatch: got %+v, want %+v", rep.Players, players)
+	}
+
+	if !reflect.DeepEqual(rep.Frames, frames) {
+		t.Errorf("Frames mismatch: got %+v, want %+v", rep.Frames, frames)
+	}
+}
+
+func TestOpenInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game"+replay.Extension)
+	recorder, err := replay.Create(path, 0, model.DefaultGameRules(), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	recorder.Close()
+
+	if _, err := replay.Open(path); err != nil {
+		t.Errorf("Unexpected error for empty replay %v", err)
+	}
+
+	if _, err := replay.Open(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("Expected error for missing replay")
+	}
+
+	garbage := filepath.Join(t.TempDir(), "garbage"+replay.Extension)
+	if err := os.WriteFile(garbage, []byte("not a replay"), 0o644); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if _, err := replay.Open(garbage); !errors.Is(err, replay.ErrInvalidReplay) {
+		t.Errorf("Expected ErrInvalidReplay, got %v", err)
+	}
+}
+
+func TestOpenInvalidCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game"+replay.Extension)
+	recorder, err := replay.Create(path, 0, model.DefaultGameRules(), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	recorder.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	for _, count := range [][]byte{{0xff, 0xff, 0xff, 0xff}, {0xff, 0xff, 0xff, 0x7f}} {
+		// The number of players at game start ends the header.
+		copy(data[len(data)-4:], count)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+
+		if _, err := replay.Open(path); !errors.Is(err, model.ErrInvalidCount) {
+			t.Errorf("Expected ErrInvalidCount for count %v, got %v", count, err)
+		}
+	}
+}
+
+func TestOpenCorruptFrames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game"+replay.Extension)
+	recorder, err := replay.Create(path, 0, model.DefaultGameRules(), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	recorder.Action("alice", model.Controls{Dest: &model.Point{X: 1, Y: 2}})
+	if err = recorder.EndTick(); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	recorder.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if rep, err := replay.Open(path); err != nil || len(rep.Frames) != 1 {
+		t.Fatalf("Expected a single frame, got %v", err)
+	}
+
+	tests := map[string][]byte{
+		"truncated frame": data[:len(data)-1],
+		"trailing bytes":  append(append([]byte{}, data...), 1, 0),
+	}
+
+	for name, corrupt := range tests {
+		if err := os.WriteFile(path, corrupt, 0o644); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+
+		if _, err := replay.Open(path); !errors.Is(err, replay.ErrInvalidReplay) {
+			t.Errorf("%s: expected ErrInvalidReplay, got %v", name, err)
+		}
+	}
+}